@@ -1,6 +1,6 @@
 module github.com/assyrianic/SourceGo
 
-go 1.17
+go 1.22
 
 replace github.com/assyrianic/SourceGo/srcgo/ast_to_sp => ./srcgo/ast_to_sp
 
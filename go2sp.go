@@ -14,8 +14,11 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/importer"
 	"go/parser"
 	"go/scanner"
@@ -25,7 +28,10 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
-	"runtime"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	GoToSPGen "github.com/assyrianic/Go2SourcePawn/srcgo/ast_to_sp"
@@ -37,6 +43,12 @@ const (
 	OptFlagForce
 	OptFlagNoCompile
 	OptFlagVerbose
+	OptFlagSplit
+	OptFlagLineComments
+	OptFlagSourceMap
+	OptFlagRoundFloor
+
+	ManifestName string = "sourcego.json"
 
 	ErrStr string = "[ERROR]"
 	WrnStr string = "[WARNING]"
@@ -44,14 +56,21 @@ const (
 	//is64Bit = uint64(^uintptr(0)) == ^uint64(0)
 )
 
+// where the Go bindings are imported from, the working directory when it's empty.
+var BindingsDir string
+
 func DoImports(dir string, file *ast.File, fset *token.FileSet, pkgs map[string]*ast.File) []*ast.File {
 	var ast_files []*ast.File
 	ast_files = append(ast_files, file)
 	for _, imp := range file.Imports {
-		file_to_import := dir + "/" + strings.Replace(imp.Path.Value, `"`, "", -1) + ".go"
+		import_path := strings.Replace(imp.Path.Value, `"`, "", -1)
+		file_to_import := dir + "/" + import_path + ".go"
 		if _, ok := pkgs[file_to_import]; ok {
 			/// prevent multiple importing.
 			continue
+		} else if _, stat_err := os.Stat(file_to_import); os.IsNotExist(stat_err) && IsStdPkg(import_path) {
+			/// without bindings, a standard package like 'fmt' is left to the type-checker's importer.
+			continue
 		}
 
 		imp_ast, imp_err := parser.ParseFile(fset, file_to_import, nil, parser.DeclarationErrors)
@@ -75,136 +94,544 @@ func DoImports(dir string, file *ast.File, fset *token.FileSet, pkgs map[string]
 	return ast_files
 }
 
+// checks if an import path is a package of the Go standard library.
+func IsStdPkg(path string) bool {
+	pkg, err := build.Default.Import(path, "", build.FindOnly)
+	return err == nil && pkg.Goroot
+}
+
+// parses every Go file of a package directory and merges them into a single File AST Node.
+func ParsePackage(fset *token.FileSet, dir string) (*ast.File, []string, error) {
+	matches, glob_err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if glob_err != nil {
+		return nil, nil, glob_err
+	}
+
+	var filenames []string
+	files := make(map[string]*ast.File)
+	var errs scanner.ErrorList
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file_ast, parse_err := parser.ParseFile(fset, filename, nil, parser.AllErrors|parser.ParseComments)
+		if parse_err != nil {
+			if err_list, is_list := parse_err.(scanner.ErrorList); is_list {
+				errs = append(errs, err_list...)
+				continue
+			}
+			return nil, nil, parse_err
+		}
+		if len(files) > 0 && file_ast.Name.Name != files[filenames[0]].Name.Name {
+			return nil, nil, fmt.Errorf("%s: package %s in a package named %s", filename, file_ast.Name.Name, files[filenames[0]].Name.Name)
+		}
+		filenames = append(filenames, filename)
+		files[filename] = file_ast
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	} else if len(filenames) == 0 {
+		return nil, nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	pkg := &ast.Package{Name: files[filenames[0]].Name.Name, Files: files}
+	merged := ast.MergePackageFiles(pkg, ast.FilterImportDuplicates)
+	MergeImportDecls(merged)
+	return merged, filenames, nil
+}
+
+// merging only dedupes the file's import list, so every file's import decl is replaced by one with each import once.
+func MergeImportDecls(file *ast.File) {
+	decls := make([]ast.Decl, 0, len(file.Decls))
+	import_decl := &ast.GenDecl{Tok: token.IMPORT}
+	for _, imp := range file.Imports {
+		import_decl.Specs = append(import_decl.Specs, imp)
+	}
+	if len(import_decl.Specs) > 0 {
+		decls = append(decls, import_decl)
+	}
+	for _, decl := range file.Decls {
+		if gen_decl, is_gen := decl.(*ast.GenDecl); is_gen && gen_decl.Tok == token.IMPORT {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+}
+
 func main() {
 	srcgo_args := os.Args[1:]
 	ASTMod.AddSrcGoTypes()
+	if len(srcgo_args) > 0 && srcgo_args[0] == "build" {
+		if !BuildManifest(srcgo_args[1:]) {
+			os.Exit(1)
+		}
+		return
+	} else if len(srcgo_args) > 0 && srcgo_args[0] == "maperr" {
+		if !MapErrors(srcgo_args[1:]) {
+			os.Exit(1)
+		}
+		return
+	}
+	if !TranspileArgs(srcgo_args) {
+		os.Exit(1)
+	}
+}
+
+// 'go2sp [options] files...' transpiles and compiles each file, false if any of them failed.
+func TranspileArgs(srcgo_args []string) bool {
+	/// options apply to every file, wherever they're given.
 	var opts int
+	var files, includes []string
+	spcomp, output := "spcomp", ""
 	for _, argStr := range srcgo_args {
-		var bad_compile bool
 		switch argStr {
 		case "--debug", "-d":
 			opts |= OptFlagDebug
 		case "-f", "--force", "--force-gen":
 			opts |= OptFlagForce
 		case "--help", "-h":
-			fmt.Println("SourceGo Usage: " + os.Args[0] + " [options] files... | options: [--debug, --force, --help, --version, --no-spcomp, --verbose]")
+			fmt.Println("SourceGo Usage: " + os.Args[0] + " [options] files... | options: [--debug, --force, --help, --version, --no-spcomp, --verbose, --split, --line-comments, --source-map, --round-floor, --spcomp=path, --include=dir, --output=dir] | a directory transpiles every Go file of the package.")
+			fmt.Println("SourceGo Usage: " + os.Args[0] + " build [manifest] [plugins...] | builds the plugins of a '" + ManifestName + "' manifest.")
+			fmt.Println("SourceGo Usage: " + os.Args[0] + " maperr [maps...] [logs...] | gives the Go lines of SourceMod stack traces, reads stdin without logs.")
 		case "--version":
 			fmt.Println("SourceGo version: v1.4b")
 		case "--verbose", "-v":
 			opts |= OptFlagVerbose
 		case "--no-spcomp", "-n":
 			opts |= OptFlagNoCompile
+		case "--split", "-s":
+			opts |= OptFlagSplit
+		case "--line-comments", "-l":
+			opts |= OptFlagLineComments
+		case "--source-map", "-m":
+			opts |= OptFlagSourceMap
+		case "--round-floor", "-r":
+			opts |= OptFlagRoundFloor
 		default:
-			new_file_name := fmt.Sprintf("%s.sp", argStr)
-			fset := token.NewFileSet()
-			code, read_err := ioutil.ReadFile(argStr)
-			CheckErr(read_err)
-			/// parse the file and get a File AST Node.
-			file_ast, parse_err := parser.ParseFile(fset, argStr, code, parser.AllErrors)
-			if parse_err != nil {
-				for _, e := range parse_err.(scanner.ErrorList) {
-					fmt.Println(e)
-				}
-				bad_compile = true
+			if strings.HasPrefix(argStr, "--spcomp=") {
+				spcomp = argStr[len("--spcomp="):]
+			} else if strings.HasPrefix(argStr, "--include=") {
+				includes = append(includes, argStr[len("--include="):])
+			} else if strings.HasPrefix(argStr, "-i") {
+				includes = append(includes, argStr[len("-i"):])
+			} else if strings.HasPrefix(argStr, "--output=") {
+				output = argStr[len("--output="):]
+			} else if strings.HasPrefix(argStr, "-o") {
+				output = argStr[len("-o"):]
 			} else {
-				dir, _ := os.Getwd()
-				pkgs := make(map[string]*ast.File)
-				ast_files := DoImports(dir, file_ast, fset, pkgs)
-
-				var typeErrs, transpileErrs []error
-				conf := types.Config{
-					Importer:                 importer.Default(),
-					DisableUnusedImportCheck: true,
-					Error: func(err error) {
-						if strings.Contains(err.Error(), "could not import") {
-						} else if strings.Contains(err.Error(), "cannot convert") || strings.Contains(err.Error(), "variable of type") || strings.Contains(err.Error(), "value of type") || strings.Contains(err.Error(), "too few arguments in call") {
-							if opts&OptFlagVerbose > 0 {
-								fmt.Printf(FmtStr, err, WrnStr)
-							}
-						} else if strings.Contains(err.Error(), "declared but not used") {
-							fmt.Printf(FmtStr, err, WrnStr)
-						} else {
-							typeErrs = append(typeErrs, err)
-							bad_compile = true
-						}
-					},
-				}
-				info := &types.Info{
-					Types:     make(map[ast.Expr]types.TypeAndValue),
-					Defs:      make(map[*ast.Ident]types.Object),
-					Uses:      make(map[*ast.Ident]types.Object),
-					Implicits: make(map[ast.Node]types.Object),
-					//Scopes:     make(map[ast.Node]*types.Scope),
-					//Selections: make(map[*ast.SelectorExpr]*types.Selection),
-				}
+				files = append(files, argStr)
+			}
+		}
+	}
+	built := true
+	for _, argStr := range files {
+		new_file_name, generated, ok := Transpile(argStr, opts)
+		if !ok {
+			built = false
+			continue
+		} else if opts&OptFlagNoCompile > 0 {
+			continue
+		}
+		var smx_file string
+		if output != "" {
+			name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(new_file_name), ".sp"), ".go")
+			smx_file = filepath.Join(output, name+".smx")
+		}
+		built = RunSPComp(spcomp, MakeSPCompArgs(new_file_name, smx_file, includes), generated) && built
+	}
+	return built
+}
 
-				/// initialize our transpiler.
-				ASTMod.SetUpSrcGo(fset, info, func(err error) {
-					transpileErrs = append(transpileErrs, err)
+// transpiles a Go file or package directory, returns the SourcePawn file, the generated files and whether they were generated.
+func Transpile(argStr string, opts int) (string, map[string]GoToSPGen.GeneratedFile, bool) {
+	var bad_compile bool
+	var transpileErrs []error
+	/// natives and forwards are registered under the name of the file.
+	library := strings.TrimSuffix(filepath.Base(argStr), ".go")
+	/// the output file names without their extension.
+	out_base := strings.TrimSuffix(argStr, ".go")
+	new_file_name := fmt.Sprintf("%s.sp", argStr)
+	fset := token.NewFileSet()
+
+	var file_ast *ast.File
+	var parse_err error
+	/// the Go files of a package directory, nil when transpiling a single file.
+	var pkg_files []string
+	if stat, stat_err := os.Stat(argStr); stat_err == nil && stat.IsDir() {
+		library = filepath.Base(filepath.Clean(argStr))
+		out_base = filepath.Join(argStr, library)
+		new_file_name = out_base + ".sp"
+		file_ast, pkg_files, parse_err = ParsePackage(fset, argStr)
+	} else {
+		/// a missing file only fails this file, the others are still transpiled.
+		if code, read_err := ioutil.ReadFile(argStr); read_err != nil {
+			parse_err = read_err
+		} else {
+			/// parse the file and get a File AST Node.
+			file_ast, parse_err = parser.ParseFile(fset, argStr, code, parser.AllErrors|parser.ParseComments)
+		}
+	}
+	if parse_err != nil {
+		if err_list, is_list := parse_err.(scanner.ErrorList); is_list {
+			for _, e := range err_list {
+				fmt.Println(e)
+			}
+		} else {
+			fmt.Println(parse_err)
+		}
+		bad_compile = true
+	} else {
+		dir := BindingsDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		pkgs := make(map[string]*ast.File)
+		ast_files := DoImports(dir, file_ast, fset, pkgs)
+
+		var typeErrs []error
+		conf := types.Config{
+			Importer:                 importer.Default(),
+			DisableUnusedImportCheck: true,
+			Error: func(err error) {
+				if type_err, is_type_err := err.(types.Error); is_type_err && ASTMod.IsGeneratedTypeErr(type_err) {
+					/// code generated by the transpiler uses SourcePawn-only methods.
+				} else if strings.Contains(err.Error(), "could not import") {
+				} else if strings.Contains(err.Error(), "not enough arguments for delete(") {
+					/// 'delete(h)' deletes a handle.
+				} else if strings.Contains(err.Error(), "outside a type constraint") {
+					/// interfaces of func types are typesets.
+				} else if strings.Contains(err.Error(), "cannot convert") || strings.Contains(err.Error(), "variable of type") || strings.Contains(err.Error(), "value of type") || strings.Contains(err.Error(), "too few arguments in call") {
+					if opts&OptFlagVerbose > 0 {
+						fmt.Printf(FmtStr, err, WrnStr)
+					}
+				} else if strings.Contains(err.Error(), "declared but not used") {
+					fmt.Printf(FmtStr, err, WrnStr)
+				} else {
+					typeErrs = append(typeErrs, err)
 					bad_compile = true
-				})
+				}
+			},
+		}
+		info := &types.Info{
+			Types:     make(map[ast.Expr]types.TypeAndValue),
+			Defs:      make(map[*ast.Ident]types.Object),
+			Uses:      make(map[*ast.Ident]types.Object),
+			Implicits: make(map[ast.Node]types.Object),
+			//Scopes:     make(map[ast.Node]*types.Scope),
+			//Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
 
-				/// first step: Analyze for illegal golang constructs.
-				ASTMod.AnalyzeIllegalCode(file_ast)
+		/// initialize our transpiler.
+		ASTMod.SetUpSrcGo(fset, info, func(err error) {
+			transpileErrs = append(transpileErrs, err)
+			bad_compile = true
+		})
 
-				ASTMod.NameAnonFuncs(file_ast)
+		/// first step: Analyze for illegal golang constructs.
+		ASTMod.AnalyzeIllegalCode(file_ast)
 
-				/// Do initial type-check of the File AST Node so we can get type information.
-				if _, err := conf.Check(``, fset, ast_files, info); err != nil {
-					for _, e := range typeErrs {
-						fmt.Printf(FmtStr, e, ErrStr)
-					}
-				}
+		ASTMod.NameAnonFuncs(file_ast)
 
-				ASTMod.MergeRetVals(file_ast)
+		/// Do initial type-check of the File AST Node so we can get type information.
+		if _, err := conf.Check(``, fset, ast_files, info); err != nil {
+			for _, e := range typeErrs {
+				fmt.Printf(FmtStr, e, ErrStr)
+			}
+		}
 
-				ASTMod.ChangeRecvrNames(file_ast)
+		ASTMod.MutateEmbeds(file_ast)
 
-				ASTMod.MutateAndNotExpr(file_ast)
+		ASTMod.CheckTypeSets(file_ast)
 
-				ASTMod.MutateRets(file_ast)
+		ASTMod.MutateClosures(file_ast)
 
-				ASTMod.MutateAssignDefs(file_ast)
+		ASTMod.MutateDefers(file_ast)
 
-				ASTMod.MutateAssigns(file_ast)
+		ASTMod.MutateLabels(file_ast)
 
-				ASTMod.MutateRanges(file_ast)
+		ASTMod.MutateErrors(file_ast)
 
-				ASTMod.MutateNoRetCalls(file_ast)
+		ASTMod.MergeRetVals(file_ast)
 
-				/// TODO: for for-loop inits that have multiple vars.
-				//ASTMod.MutateForInits(file_ast)
+		ASTMod.ChangeRecvrNames(file_ast)
 
-				//ASTMod.MutateMaps(file_ast)
+		ASTMod.MutateAndNotExpr(file_ast)
 
-				for _, e := range transpileErrs {
-					fmt.Printf(FmtStr, e, ErrStr)
-				}
+		ASTMod.MutateRets(file_ast)
 
-				conf.Check(``, fset, ast_files, info)
-				if opts&OptFlagDebug > 0 {
-					WriteToFile(fmt.Sprintf("%s_AST.txt", argStr), ASTMod.PrintAST(file_ast))
-					WriteToFile(fmt.Sprintf("%s_output.go", argStr), ASTMod.PrettyPrintAST(file_ast))
-				}
-			}
+		ASTMod.MutateAssignDefs(file_ast)
 
-			if bad_compile && opts&OptFlagForce == 0 {
-				fmt.Println(fmt.Sprintf("SourceGo: file '%s' generation FAILED.", new_file_name))
-			} else {
-				final_code := GoToSPGen.GeneratePluginFile(file_ast)
-				WriteToFile(argStr+".sp", final_code)
-				if bad_compile {
-					fmt.Println("SourceGo: transpiled " + new_file_name + " but might need correction.")
-				} else {
-					fmt.Println("SourceGo: successfully transpiled " + new_file_name)
-				}
+		ASTMod.MutateAssigns(file_ast)
 
-				if opts&OptFlagNoCompile == 0 {
-					InvokeSPComp(argStr + ".sp")
-				}
+		ASTMod.MutateRanges(file_ast)
+
+		ASTMod.MutateNoRetCalls(file_ast)
+
+		/// TODO: for for-loop inits that have multiple vars.
+		//ASTMod.MutateForInits(file_ast)
+
+		ASTMod.MutateStructLits(file_ast)
+
+		ASTMod.MutateMaps(file_ast)
+
+		ASTMod.MutateSlices(file_ast)
+
+		ASTMod.MutateStrings(file_ast)
+
+		ASTMod.MutateNatives(file_ast, library)
+
+		for _, e := range transpileErrs {
+			fmt.Printf(FmtStr, e, ErrStr)
+		}
+
+		/// the mutated code is checked again to type the code made by the transpiler.
+		type_errs := len(typeErrs)
+		conf.Check(``, fset, ast_files, info)
+		for _, e := range typeErrs[type_errs:] {
+			fmt.Printf(FmtStr, e, ErrStr)
+		}
+		if opts&OptFlagDebug > 0 {
+			WriteToFile(fmt.Sprintf("%s_AST.txt", out_base), ASTMod.PrintAST(file_ast))
+			WriteToFile(fmt.Sprintf("%s_output.go", out_base), ASTMod.PrettyPrintAST(file_ast))
+		}
+	}
+
+	if file_ast == nil || (bad_compile && opts&OptFlagForce == 0) {
+		fmt.Println(fmt.Sprintf("SourceGo: file '%s' generation FAILED.", new_file_name))
+		return new_file_name, nil, false
+	}
+
+	/// float to int conversions truncate like Go unless flooring is asked for.
+	GoToSPGen.FloatToInt = "RoundToZero"
+	if opts&OptFlagRoundFloor > 0 {
+		GoToSPGen.FloatToInt = "RoundToFloor"
+	}
+
+	/// generated file name => generated code, to find the Go code of spcomp messages.
+	generated := make(map[string]GoToSPGen.GeneratedFile)
+	/// the generator reports what it can't translate while it writes the code.
+	transpile_errs := len(transpileErrs)
+	if pkg_files != nil && opts&OptFlagSplit > 0 {
+		final_code, includes := GoToSPGen.GenerateSplitPlugin(file_ast, pkg_files, library)
+		generated[new_file_name] = final_code
+		for i, include_code := range includes {
+			generated[filepath.Join(argStr, GoToSPGen.SplitIncludeName(library, pkg_files[i])+".inc")] = include_code
+		}
+	} else {
+		generated[new_file_name] = GoToSPGen.GeneratePluginFile(file_ast)
+	}
+	include_code := GoToSPGen.GenerateIncludeFile(library)
+	for _, e := range transpileErrs[transpile_errs:] {
+		fmt.Printf(FmtStr, e, ErrStr)
+	}
+	if bad_compile && opts&OptFlagForce == 0 {
+		fmt.Println(fmt.Sprintf("SourceGo: file '%s' generation FAILED.", new_file_name))
+		return new_file_name, nil, false
+	}
+
+	for filename, gen := range generated {
+		if opts&OptFlagLineComments > 0 {
+			gen = gen.WithLineComments()
+			generated[filename] = gen
+		}
+		WriteToFile(filename, gen.Code)
+		if opts&OptFlagSourceMap > 0 {
+			if source_map, json_err := json.MarshalIndent(gen.MakeSourceMap(filename), "", "\t"); json_err == nil {
+				WriteToFile(filename+".map", string(source_map))
 			}
 		}
 	}
+	if include_code != "" {
+		WriteToFile(out_base+".inc", include_code)
+	}
+	if bad_compile {
+		fmt.Println("SourceGo: transpiled " + new_file_name + " but might need correction.")
+	} else {
+		fmt.Println("SourceGo: successfully transpiled " + new_file_name)
+	}
+	return new_file_name, generated, true
+}
+
+// a manifest declares every plugin of a project, settings given for the project apply to each of its plugins.
+type Manifest struct {
+	SPComp   string            `json:"spcomp"`
+	Flags    []string          `json:"flags"`
+	Includes []string          `json:"includes"`
+	Output   string            `json:"output"`
+	Defines  map[string]string `json:"defines"`
+	Game     string            `json:"game"`
+	Bindings string            `json:"bindings"`
+	Plugins  []ManifestPlugin  `json:"plugins"`
+}
+
+type ManifestPlugin struct {
+	Name         string            `json:"name"`
+	Entry        string            `json:"entry"`
+	Flags        []string          `json:"flags"`
+	Includes     []string          `json:"includes"`
+	Output       string            `json:"output"`
+	Defines      map[string]string `json:"defines"`
+	Game         string            `json:"game"`
+	Split        bool              `json:"split"`
+	Force        bool              `json:"force"`
+	NoCompile    bool              `json:"no_spcomp"`
+	LineComments bool              `json:"line_comments"`
+	SourceMap    bool              `json:"source_map"`
+	RoundFloor   bool              `json:"round_floor"`
+}
+
+func ReadManifest(filename string) (*Manifest, error) {
+	data, read_err := ioutil.ReadFile(filename)
+	if read_err != nil {
+		return nil, read_err
+	}
+	manifest := &Manifest{SPComp: "spcomp", Output: "."}
+	if json_err := json.Unmarshal(data, manifest); json_err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, json_err)
+	}
+	for i, plugin := range manifest.Plugins {
+		/// a plugin without an entry fails on its own when it's built.
+		if plugin.Name == "" && plugin.Entry != "" {
+			manifest.Plugins[i].Name = strings.TrimSuffix(filepath.Base(filepath.Clean(plugin.Entry)), ".go")
+		}
+	}
+	return manifest, nil
+}
+
+// 'go2sp build [manifest] [plugins...]' builds the plugins of a manifest, all of them if none are named.
+func BuildManifest(args []string) bool {
+	manifest_file := ManifestName
+	var names []string
+	for _, arg := range args {
+		if stat, stat_err := os.Stat(arg); stat_err == nil && stat.IsDir() {
+			manifest_file = filepath.Join(arg, ManifestName)
+		} else if strings.HasSuffix(arg, ".json") {
+			manifest_file = arg
+		} else {
+			names = append(names, arg)
+		}
+	}
+
+	manifest, manifest_err := ReadManifest(manifest_file)
+	if manifest_err != nil {
+		fmt.Printf(FmtStr, manifest_err, ErrStr)
+		return false
+	}
+	/// the bindings stay where the build was started from unless the manifest says where they are.
+	wd, wd_err := os.Getwd()
+	if wd_err != nil {
+		fmt.Printf(FmtStr, wd_err, ErrStr)
+		return false
+	}
+	BindingsDir = wd
+	if manifest.Bindings != "" {
+		BindingsDir = manifest.Bindings
+		if !filepath.IsAbs(BindingsDir) {
+			BindingsDir = filepath.Join(filepath.Dir(manifest_file), BindingsDir)
+		}
+		if !filepath.IsAbs(BindingsDir) {
+			BindingsDir = filepath.Join(wd, BindingsDir)
+		}
+	}
+	defer func() { BindingsDir = "" }()
+
+	/// paths in the manifest are relative to it.
+	if dir := filepath.Dir(manifest_file); dir != "." {
+		if chdir_err := os.Chdir(dir); chdir_err != nil {
+			fmt.Printf(FmtStr, chdir_err, ErrStr)
+			return false
+		}
+	}
+
+	built := true
+	for _, name := range names {
+		found := false
+		for _, plugin := range manifest.Plugins {
+			found = found || plugin.Name == name
+		}
+		if !found {
+			fmt.Printf(FmtStr, fmt.Sprintf("%s: no plugin named '%s'", manifest_file, name), ErrStr)
+			built = false
+		}
+	}
+	for i, plugin := range manifest.Plugins {
+		if len(names) > 0 && !ContainsStr(names, plugin.Name) {
+			continue
+		} else if plugin.Entry == "" {
+			fmt.Printf(FmtStr, fmt.Sprintf("%s: plugin #%d has no entry", manifest_file, i+1), ErrStr)
+			built = false
+			continue
+		}
+		var opts int
+		if plugin.Split {
+			opts |= OptFlagSplit
+		}
+		if plugin.Force {
+			opts |= OptFlagForce
+		}
+		if plugin.LineComments {
+			opts |= OptFlagLineComments
+		}
+		if plugin.SourceMap {
+			opts |= OptFlagSourceMap
+		}
+		if plugin.RoundFloor {
+			opts |= OptFlagRoundFloor
+		}
+		new_file_name, generated, ok := Transpile(plugin.Entry, opts)
+		if !ok {
+			built = false
+		} else if !plugin.NoCompile {
+			built = RunSPComp(manifest.SPComp, manifest.GetSPCompArgs(plugin, new_file_name), generated) && built
+		}
+	}
+	return built
+}
+
+// the arguments of a plugin are the project's arguments followed by its own.
+func (manifest *Manifest) GetSPCompArgs(plugin ManifestPlugin, sp_file string) []string {
+	output := manifest.Output
+	if plugin.Output != "" {
+		output = plugin.Output
+	}
+	args := MakeSPCompArgs(sp_file, filepath.Join(output, plugin.Name+".smx"), append(append([]string{}, manifest.Includes...), plugin.Includes...))
+	args = append(args, manifest.Flags...)
+	args = append(args, plugin.Flags...)
+
+	defines := make(map[string]string)
+	for sym, val := range manifest.Defines {
+		defines[sym] = val
+	}
+	for sym, val := range plugin.Defines {
+		defines[sym] = val
+	}
+	game := manifest.Game
+	if plugin.Game != "" {
+		game = plugin.Game
+	}
+	if game != "" {
+		/// 'game = "tf2"' can be checked with '#if defined GAME_TF2'.
+		defines["GAME_"+strings.ToUpper(game)] = "1"
+	}
+	syms := make([]string, 0, len(defines))
+	for sym := range defines {
+		syms = append(syms, sym)
+	}
+	sort.Strings(syms)
+	for _, sym := range syms {
+		args = append(args, sym+"="+defines[sym])
+	}
+	return args
+}
+
+func ContainsStr(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
 }
 
 func CheckErr(e error) {
@@ -227,15 +654,174 @@ func WriteToFile(filename, data string) error {
 	return file.Sync()
 }
 
-func InvokeSPComp(file string) {
-	cmd_str := "compile"
-	if runtime.GOOS == "windows" {
-		cmd_str += ".bat"
-	} else {
-		cmd_str = fmt.Sprintf("./%s.sh", cmd_str)
+// 'spcomp file.sp -ofile.smx -idir...', without '-o' spcomp writes the .smx to the working directory.
+func MakeSPCompArgs(sp_file, smx_file string, includes []string) []string {
+	args := []string{sp_file}
+	if smx_file != "" {
+		args = append(args, "-o"+smx_file)
+	}
+	for _, include := range includes {
+		args = append(args, "-i"+include)
 	}
+	return args
+}
+
+// a message of spcomp, 'Line' is a line of the generated file.
+type SPCompDiag struct {
+	File, Kind, Code, Msg string
+	Line                  int
+}
+
+// 'file.sp(12) : error 017: undefined symbol "x"', statements over several lines are given as '(12 -- 14)'.
+var SPCompDiagRegex = regexp.MustCompile(`^(.+?)\((\d+)(?:\s*--\s*\d+)?\)\s*:\s*(fatal error|error|warning)\s+(\d+):\s*(.*)$`)
+
+func ParseSPCompOutput(output string) []SPCompDiag {
+	var diags []SPCompDiag
+	for _, line := range strings.Split(output, "\n") {
+		if match := SPCompDiagRegex.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			line_num, _ := strconv.Atoi(match[2])
+			diags = append(diags, SPCompDiag{File: match[1], Line: line_num, Kind: match[3], Code: match[4], Msg: match[5]})
+		}
+	}
+	return diags
+}
+
+// spcomp may name a file differently than it was given.
+func FindGenerated(filename string, generated map[string]GoToSPGen.GeneratedFile) (GoToSPGen.GeneratedFile, bool) {
+	abs_name, _ := filepath.Abs(filename)
+	for name, gen := range generated {
+		if abs_gen, _ := filepath.Abs(name); abs_gen == abs_name {
+			return gen, true
+		}
+	}
+	for name, gen := range generated {
+		if filepath.Base(name) == filepath.Base(filename) {
+			return gen, true
+		}
+	}
+	return GoToSPGen.GeneratedFile{}, false
+}
+
+// runs spcomp on a generated file, its messages are given with the Go code they came from.
+func RunSPComp(spcomp string, args []string, generated map[string]GoToSPGen.GeneratedFile) bool {
+	if output_dir := GetSPCompOutputDir(args); output_dir != "" {
+		os.MkdirAll(output_dir, 0755)
+	}
+	msg, err := exec.Command(spcomp, args...).CombinedOutput()
+	diags := ParseSPCompOutput(string(msg))
+	for _, diag := range diags {
+		sp_pos := fmt.Sprintf("%s:%d", diag.File, diag.Line)
+		diag_str := fmt.Sprintf("%s: %s %s: %s", sp_pos, diag.Kind, diag.Code, diag.Msg)
+		if gen, found := FindGenerated(diag.File, generated); found {
+			if go_pos, mapped := gen.GoPosition(diag.Line); mapped {
+				diag_str = fmt.Sprintf("%s: %s %s: %s (%s)", go_pos, diag.Kind, diag.Code, diag.Msg, sp_pos)
+			}
+		}
+		if diag.Kind == "warning" {
+			fmt.Printf(FmtStr, diag_str, WrnStr)
+		} else {
+			fmt.Printf(FmtStr, diag_str, ErrStr)
+		}
+	}
+
+	if err != nil {
+		if len(diags) == 0 {
+			/// spcomp wasn't found or failed without saying where.
+			fmt.Print(string(msg))
+		}
+		fmt.Printf(FmtStr, fmt.Sprintf("SourceGo::SPComp: %s %s: %v", spcomp, args[0], err), ErrStr)
+		return false
+	}
+	fmt.Println("SourceGo::SPComp: successfully compiled " + args[0])
+	return true
+}
 
-	if msg, err := exec.Command(cmd_str).Output(); err == nil {
-		fmt.Printf("SourceGo::SPComp Invoked:: %s\n", string(msg))
+// '[SM]   [1] Line 312, vsh2/vsh2.sp::OnPluginStart' of a SourceMod stack trace.
+var SMTraceRegex = regexp.MustCompile(`Line (\d+), (.+?)::(\w+)`)
+
+// 'go2sp maperr [maps...] [logs...]' gives the Go line of each line of a stack trace.
+// a map that isn't given is looked for next to its generated file, relative to the working directory.
+func MapErrors(args []string) bool {
+	maps := make(map[string]GoToSPGen.SourceMap)
+	var logs []string
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".map") {
+			source_map, map_err := ReadSourceMap(arg)
+			if map_err != nil {
+				fmt.Printf(FmtStr, map_err, ErrStr)
+				return false
+			}
+			maps[filepath.Base(source_map.File)] = source_map
+		} else {
+			logs = append(logs, arg)
+		}
+	}
+
+	map_line := func(line string) string {
+		match := SMTraceRegex.FindStringSubmatch(line)
+		if match == nil {
+			return line
+		}
+		/// SourceMod gives the path the plugin was compiled with.
+		sp_file := strings.Replace(match[2], `\`, "/", -1)
+		source_map, found := maps[filepath.Base(sp_file)]
+		if !found {
+			for _, filename := range []string{sp_file + ".map", filepath.Base(sp_file) + ".map"} {
+				if read_map, map_err := ReadSourceMap(filename); map_err == nil {
+					source_map, found = read_map, true
+					maps[filepath.Base(sp_file)] = read_map
+					break
+				}
+			}
+		}
+		if !found {
+			return line
+		}
+		line_num, _ := strconv.Atoi(match[1])
+		if go_line := source_map.FindLine(line_num); go_line != nil {
+			return fmt.Sprintf("%s => %s:%d", line, go_line.GoFile, go_line.GoLine)
+		}
+		return line
+	}
+
+	var inputs []io.Reader
+	if len(logs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+	for _, log := range logs {
+		log_file, open_err := os.Open(log)
+		if open_err != nil {
+			fmt.Printf(FmtStr, open_err, ErrStr)
+			return false
+		}
+		defer log_file.Close()
+		inputs = append(inputs, log_file)
+	}
+	for _, input := range inputs {
+		scan := bufio.NewScanner(input)
+		for scan.Scan() {
+			fmt.Println(map_line(scan.Text()))
+		}
+	}
+	return true
+}
+
+func ReadSourceMap(filename string) (GoToSPGen.SourceMap, error) {
+	var source_map GoToSPGen.SourceMap
+	data, read_err := ioutil.ReadFile(filename)
+	if read_err != nil {
+		return source_map, read_err
+	} else if json_err := json.Unmarshal(data, &source_map); json_err != nil {
+		return source_map, fmt.Errorf("%s: %v", filename, json_err)
+	}
+	return source_map, nil
+}
+
+func GetSPCompOutputDir(args []string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-o") {
+			return filepath.Dir(arg[len("-o"):])
+		}
 	}
+	return ""
 }
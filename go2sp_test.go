@@ -0,0 +1,404 @@
+/**
+ * go2sp_test.go
+ *
+ * Copyright 2020 Nirari Technologies, Alliedmodders LLC.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+/// the Go bindings share this directory, run the tests with 'go test go2sp.go go2sp_test.go'.
+/// the bindings are imported from the working directory, like they are when transpiling.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	ASTMod "github.com/assyrianic/Go2SourcePawn/srcgo/ast_transform"
+)
+
+func TestMain(m *testing.M) {
+	ASTMod.AddSrcGoTypes()
+	os.Exit(m.Run())
+}
+
+// writes the Go files of a plugin into 'dir'.
+func WriteTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// transpiles a file or package and returns the code of its plugin file.
+func TranspileTest(t *testing.T, path string) string {
+	t.Helper()
+	sp_file, generated, ok := Transpile(path, OptFlagNoCompile)
+	if !ok {
+		t.Fatalf("transpiling %s failed", path)
+	}
+	return generated[sp_file].Code
+}
+
+func ExpectCode(t *testing.T, code string, want ...string) {
+	t.Helper()
+	for _, line := range want {
+		if !strings.Contains(code, line) {
+			t.Errorf("generated code is missing %q:\n%s", line, code)
+		}
+	}
+}
+
+func TestPackageSharedImports(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "greeter")
+	WriteTestFiles(t, dir, map[string]string{
+		"greet.go": "package main\n\nimport \"sourcemod\"\n\nfunc Greet(name string) {\n\tPrintToServer(\"hi %s\", name)\n}\n",
+		"main.go":  "package main\n\nimport \"sourcemod\"\n\nfunc main() {\n\tGreet(\"bob\")\n\tPrintToServer(\"started\")\n}\n",
+	})
+	code := TranspileTest(t, dir)
+	ExpectCode(t, code, "#include <sourcemod>", "void Greet(const char[] name)", "public void OnPluginStart()", "Greet(\"bob\");")
+	if strings.Count(code, "#include <sourcemod>") != 1 {
+		t.Errorf("sourcemod is included more than once:\n%s", code)
+	}
+}
+
+// runs a manifest build and goes back to the working directory the build changes.
+func BuildManifestTest(t *testing.T, args ...string) bool {
+	t.Helper()
+	wd, wd_err := os.Getwd()
+	if wd_err != nil {
+		t.Fatal(wd_err)
+	}
+	defer os.Chdir(wd)
+	return BuildManifest(args)
+}
+
+func TestManifestBadEntries(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"good.go": "package main\n\nfunc main() {\n}\n",
+		ManifestName: `{
+	"plugins": [
+		{ "name": "missing", "entry": "missing.go", "no_spcomp": true },
+		{ "name": "empty", "no_spcomp": true },
+		{ "name": "good", "entry": "good.go", "no_spcomp": true }
+	]
+}`,
+	})
+	if BuildManifestTest(t, dir) {
+		t.Error("a build with bad entries succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "good.go.sp")); err != nil {
+		t.Errorf("the plugin after the bad entries wasn't built: %v", err)
+	}
+}
+
+func TestManifestBindings(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	WriteTestFiles(t, dir, map[string]string{
+		"hello.go":   "package main\n\nimport \"sourcemod\"\n\nfunc main() {\n\tPrintToServer(\"hello\")\n}\n",
+		ManifestName: `{ "plugins": [ { "name": "hello", "entry": "hello.go", "no_spcomp": true } ] }`,
+	})
+	/// the bindings are in the working directory, not the project's.
+	if !BuildManifestTest(t, dir) {
+		t.Fatal("the build didn't find the bindings of the working directory")
+	}
+	code, err := os.ReadFile(filepath.Join(dir, "hello.go.sp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExpectCode(t, string(code), "#include <sourcemod>", "PrintToServer(\"hello\");")
+}
+
+// runs 'f' and returns what it printed.
+func CaptureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, pipe_err := os.Pipe()
+	if pipe_err != nil {
+		t.Fatal(pipe_err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	writer.Close()
+	return <-output
+}
+
+func TestSPCompStub(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"plugin.go": "package main\n\nimport \"sourcemod\"\n\nfunc main() {\n\tPrintToServer(\"started\")\n}\n",
+		/// fails on the line that calls PrintToServer, like spcomp would.
+		"fail.sh": "#!/bin/sh\nn=$(grep -n PrintToServer \"$1\" | head -1 | cut -d: -f1)\necho \"$1($n) : error 017: undefined symbol \\\"PrintToServer\\\"\"\nexit 1\n",
+		"pass.sh": "#!/bin/sh\necho \"Code size: 1024 bytes\"\nexit 0\n",
+	})
+	for _, stub := range []string{"fail.sh", "pass.sh"} {
+		if err := os.Chmod(filepath.Join(dir, stub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plugin := filepath.Join(dir, "plugin.go")
+
+	var built bool
+	output := CaptureStdout(t, func() {
+		built = TranspileArgs([]string{"--spcomp=" + filepath.Join(dir, "fail.sh"), plugin})
+	})
+	if built {
+		t.Error("a failed spcomp run was reported as built")
+	}
+	if go_line := plugin + ":6:"; !strings.Contains(output, go_line) || !strings.Contains(output, "error 017") {
+		t.Errorf("spcomp's error isn't mapped to %s:\n%s", go_line, output)
+	}
+
+	output = CaptureStdout(t, func() {
+		built = TranspileArgs([]string{"--spcomp=" + filepath.Join(dir, "pass.sh"), plugin})
+	})
+	if !built {
+		t.Errorf("a passing spcomp run was reported as failed:\n%s", output)
+	}
+}
+
+func TestMapRangeKey(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"admins.go": "package main\n\nimport \"sourcemod\"\n\nvar ranks map[string]int\n\nfunc CountAdmins() int {\n\tcount := 0\n\tfor name, rank := range ranks {\n\t\tif name == \"admin\" || rank > 1 {\n\t\t\tcount++\n\t\t}\n\t}\n\treturn count\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "admins.go"))
+	ExpectCode(t, code, "char[] name = new char[CountAdmins_keylen0];", "GetKey(CountAdmins_iter0, name, CountAdmins_keylen0);", "StrEqual(name, \"admin\")")
+}
+
+func TestArrayListMakes(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"lists.go": "package main\n\nimport \"sourcemod\"\n\ntype Boss struct {\n\thealth int\n}\n\nfunc Collect(n int) []int {\n\tnums := make([]int, 0)\n\tnums = append(nums, n)\n\treturn nums\n}\n\nfunc Bosses() int {\n\tcount := 2\n\tbosses := make([]Boss, count)\n\tvar b Boss\n\tbosses = append(bosses, b)\n\tif l := Collect(3); len(l) > 1 {\n\t\treturn len(l)\n\t}\n\treturn len(bosses)\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "lists.go"))
+	ExpectCode(t, code, "ArrayList nums = new ArrayList();", "ArrayList bosses = new ArrayList(sizeof(Boss), count);", "l = Collect(3);", "delete bosses;", "delete l;")
+	for _, bad := range []string{"new Boss[", "new int[", "l = new ArrayList", "delete nums;"} {
+		if strings.Contains(code, bad) {
+			t.Errorf("generated code has %q:\n%s", bad, code)
+		}
+	}
+}
+
+func TestNativeBuffersAndInclude(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"bosses.go": "package main\n\nimport \"sourcemod\"\n\ntype BaseBoss Handle\n\ntype Stats struct {\n\tkills int\n}\n\n//srcgo:native\nfunc VSH_GetName(boss BaseBoss, name []char, maxlen int) int {\n\treturn 0\n}\n\n//srcgo:forward\nfunc OnBossKilled(boss BaseBoss, stats Stats) Action\n\nfunc main() {\n\tOnBossKilled(BaseBoss(nil), Stats{})\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "bosses.go"))
+	ExpectCode(t, code, "char[] name = new char[maxlen];", "GetNativeString(2, name, maxlen);", "SetNativeString(2, name, maxlen);")
+	inc, err := os.ReadFile(filepath.Join(dir, "bosses.inc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExpectCode(t, string(inc), "methodmap BaseBoss < Handle {", "enum struct Stats {", "native int VSH_GetName(BaseBoss boss, char[] name, int maxlen);")
+	if strings.Index(string(inc), "methodmap BaseBoss") > strings.Index(string(inc), "native int VSH_GetName") {
+		t.Errorf("the include declares BaseBoss after using it:\n%s", inc)
+	}
+}
+
+func TestFmtCalls(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\nfunc Show(n int) {\n\tfmt.Printf(\"n = %d\", n)\n\tfmt.Println(\"done\", n)\n\ts := fmt.Sprintf(\"%v\", n)\n\tfmt.Println(s)\n}\n"
+	want := []string{"PrintToServer(\"n = %d\", n);", "PrintToServer(\"%s %d\", \"done\", n);", "FormatEx(s, sizeof(s), \"%d\", n);"}
+
+	/// the fmt bindings of this directory.
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{"show.go": code})
+	ExpectCode(t, TranspileTest(t, filepath.Join(dir, "show.go")), want...)
+
+	/// the real fmt package, its calls return '(int, error)'.
+	wd, wd_err := os.Getwd()
+	if wd_err != nil {
+		t.Fatal(wd_err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	generated := TranspileTest(t, "show.go")
+	ExpectCode(t, generated, want...)
+	if strings.Contains(generated, "fn_temp") {
+		t.Errorf("the results of a fmt call are passed to it:\n%s", generated)
+	}
+}
+
+func TestFuncStorage(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"crits.go": "package main\n\nimport \"sourcemod\"\n\nfunc main() {\n\tCreateTimer(1.0, Tick, 0, TIMER_REPEAT)\n}\n\nfunc Tick(timer Timer, data any) Action {\n\treturn Plugin_Continue\n}\n\nfunc TF2_CalcIsAttackCritical(client, weapon int, name string, result *bool) Action {\n\treturn countCrits(client)\n}\n\nfunc countCrits(client int) Action {\n\treturn CountAlive()\n}\n\nfunc CountAlive() Action {\n\treturn Plugin_Continue\n}\n\nfunc GetTag(client int) string {\n\treturn \"crit\"\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "crits.go"))
+	ExpectCode(t, code, "public void OnPluginStart()", "public Action Tick(", "public Action TF2_CalcIsAttackCritical(", "static Action countCrits(int client)", "stock Action CountAlive()", "stock char[] GetTag(int client)")
+}
+
+func TestTypesetSample(t *testing.T) {
+	/// the mantreads sample, with the size and element args of GetEntProp given.
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"mantreads.go": "package main\n\nimport (\n\t\"sourcemod\"\n\t\"sdkhooks\"\n)\n\nfunc main() {\n\tfor i := 1; i<=MaxClients; i++ {\n\t\tif IsClientInGame(i) {\n\t\t\tOnClientPutInServer(i)\n\t\t}\n\t}\n}\n\nfunc OnClientPutInServer(client Entity) {\n\tSDKHook(client, SDKHook_OnTakeDamage, func (victim int, attacker, inflictor *int, damage *float, damagetype, weapon *int, damageForce, damagePosition *Vec3, damagecustom int) Action {\n\t\tif IsValidEntity(*weapon) && GetEntProp(*weapon, Prop_Send, \"m_iItemDefinitionIndex\", 4, 0)==444 {\n\t\t\t*damage *= 5.0\n\t\t\treturn Plugin_Changed\n\t\t}\n\t\treturn Plugin_Continue\n\t})\n}\n",
+	})
+	ExpectCode(t, TranspileTest(t, filepath.Join(dir, "mantreads.go")), "public void OnClientPutInServer(int client)", "int& weapon, float damageForce[3], float damagePosition[3], int damagecustom)")
+}
+
+func TestStructCompare(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"players.go": "package main\n\nimport \"sourcemod\"\n\ntype Pos struct {\n\tX, Y float\n}\n\ntype Player struct {\n\tName string\n\tPos  Pos\n}\n\nfunc Moved(p, q Player) bool {\n\tif p == q {\n\t\treturn false\n\t}\n\treturn p.Pos != q.Pos\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "players.go"))
+	ExpectCode(t, code, "StrEqual(p.Name, q.Name) && p.Pos.X == q.Pos.X && p.Pos.Y == q.Pos.Y", "p.Pos.X != q.Pos.X || p.Pos.Y != q.Pos.Y")
+}
+
+func TestErrorBuffers(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"cfg.go": "package main\n\nimport (\n\t\"sourcemod\"\n\t\"errors\"\n)\n\nfunc Load(path string) error {\n\tif path == \"\" {\n\t\treturn New(\"empty path\")\n\t}\n\treturn nil\n}\n\nfunc Reload(path string) error {\n\treturn Load(path)\n}\n\nfunc main() {\n\tif err := Reload(\"plugin.cfg\"); err != nil {\n\t\tpanic(err)\n\t}\n\tpanic(\"100% done\")\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "cfg.go"))
+	ExpectCode(t, code, "void Load(const char[] path, char[] Load_param0, int Load_param0_maxlen)", "strcopy(Load_param0, Load_param0_maxlen, \"empty path\");", "Load(path, Reload_param0, Reload_param0_maxlen);", "Reload(\"plugin.cfg\", err, sizeof(err));", "SetFailState(\"%s\", err);", "SetFailState(\"%s\", \"100% done\");")
+	if strings.Contains(code, "sizeof(Load_param0)") {
+		t.Errorf("an unsized error param is measured with sizeof:\n%s", code)
+	}
+}
+
+func TestStrSwitchCallTag(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"tags.go": "package main\n\nimport \"sourcemod\"\n\nfunc Full(s string) string {\n\treturn s + \"ylophone\"\n}\n\nfunc Count() int {\n\tn := 0\n\tfor i := 0; i < 3; i++ {\n\t\tswitch Full(\"x\") {\n\t\tcase \"x\":\n\t\t\tcontinue\n\t\tcase \"xylophone\":\n\t\t\tbreak\n\t\t}\n\t\tn++\n\t}\n\treturn n\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "tags.go"))
+	/// temporaries are numbered across plugins, find the tag's.
+	tag := regexp.MustCompile(`char (switch_tag\d+)\[256\];`).FindStringSubmatch(code)
+	if tag == nil {
+		t.Fatalf("the switch tag isn't a whole string buffer:\n%s", code)
+	}
+	ExpectCode(t, code, "strcopy("+tag[1]+", sizeof("+tag[1]+"), Full(\"x\"));", "if (StrEqual("+tag[1]+", \"x\"))", "str_switch0_continue = true;", "if (str_switch0_continue)\n\t\t\tcontinue;")
+	if strings.Count(code, "Full(\"x\")") != 1 {
+		t.Errorf("the switch tag is called more than once:\n%s", code)
+	}
+}
+
+func TestMapStringGet(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"names.go": "package main\n\nimport \"sourcemod\"\n\nvar names map[string]string\n\nfunc ShowName() {\n\tvar s string\n\ts = names[\"a\"]\n\tPrintToServer(s)\n}\n",
+	})
+	ExpectCode(t, TranspileTest(t, filepath.Join(dir, "names.go")), "names.GetString(\"a\", s, sizeof(s));")
+}
+
+func TestSliceCopyAndGet(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"tags.go": "package main\n\nimport \"sourcemod\"\n\nfunc Copy(tags []string, n int) int {\n\tfor _, tag := range tags {\n\t\tPrintToServer(tag)\n\t}\n\ttags = append(tags, \"new\")\n\tlist := make([]int, 0)\n\tlist = append(list, 1)\n\tdst := make([]int, 4)\n\tcopy(dst, list)\n\tdyn := make([]int, n)\n\tn = 0\n\tcopy(dyn, list)\n\treturn dst[0] + dyn[0]\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "tags.go"))
+	ExpectCode(t, code, "tags.GetString(Copy_iter0, tag, sizeof(tag));", "int[] dst = new int[4];", "< 4 && ", "int[] dyn = new int[dyn_len", "< dyn_len")
+	if strings.Contains(code, "sizeof(dst)") || strings.Contains(code, "sizeof(dyn)") {
+		t.Errorf("a dynamic array is measured with sizeof:\n%s", code)
+	}
+}
+
+func TestSplitIncludes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hooks")
+	files := map[string]string{
+		"a.go": "package main\n\nimport \"sourcemod\"\n\nvar Count int\n\nfunc Hook(client int) Action {\n\tCount++\n\treturn Plugin_Continue\n}\n",
+		"b.go": "package main\n\nimport \"sourcemod\"\n\nvar Total int\n\nfunc main() {\n\tTotal = Count\n\tHook(1)\n}\n",
+	}
+	WriteTestFiles(t, dir, files)
+	sp_file, generated, ok := Transpile(dir, OptFlagNoCompile|OptFlagSplit)
+	if !ok {
+		t.Fatalf("transpiling %s failed", dir)
+	}
+	ExpectCode(t, generated[sp_file].Code, "#include <sourcemod>\n#include \"hooks_a.inc\"\n#include \"hooks_b.inc\"\n")
+	ExpectCode(t, generated[filepath.Join(dir, "hooks_b.inc")].Code, "#include \"hooks_a.inc\"")
+
+	/// a.go now uses a global of b.go too.
+	files["a.go"] += "\nfunc Peek() int {\n\treturn Total\n}\n"
+	WriteTestFiles(t, dir, files)
+	if _, _, ok := Transpile(dir, OptFlagNoCompile|OptFlagSplit); ok {
+		t.Error("files that include each other were split")
+	}
+}
+
+func TestMapReadAfterInit(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"cfg.go": "package main\n\nimport \"sourcemod\"\n\nvar cfg map[string]int\n\nfunc Check() int {\n\tn := 0\n\tif k := \"q\"; cfg[k] > 0 {\n\t\tn++\n\t}\n\tswitch k := \"s\"; cfg[k] {\n\tcase 1:\n\t\tn = 2\n\t}\n\treturn n\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "cfg.go"))
+	/// the reads come after the inits they use and before the statements they're read for.
+	order := []string{"char k[] = \"q\";", "cfg.GetValue(k, ", "if (map_value", "char k[] = \"s\";", "cfg.GetValue(k, ", "switch (map_value"}
+	at := 0
+	for _, line := range order {
+		found := strings.Index(code[at:], line)
+		if found < 0 {
+			t.Fatalf("generated code is missing %q after the lines before it:\n%s", line, code)
+		}
+		at += found + len(line)
+	}
+}
+
+func TestWrittenStrParams(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"shout.go": "package main\n\nimport \"sourcemod\"\n\nfunc Shout(client int, msg string) {\n\tmsg += \"!\"\n\tPrintToChat(client, msg)\n}\n\nfunc Keep(s string) {\n\tPrintToServer(s)\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "shout.go"))
+	ExpectCode(t, code, "void Shout(int client, const char[] msg_param)", "char msg[256];", "strcopy(msg, sizeof(msg), msg_param);", "StrCat(msg, sizeof(msg), \"!\");", "void Keep(const char[] s)")
+}
+
+func TestStructArrayLits(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"spawns.go": "package main\n\nimport \"sourcemod\"\n\ntype Pos struct {\n\tX, Y float\n}\n\nvar spawns = [2]Pos{{1.0, 2.0}, {X: 3.0}}\n\nfunc Take(ps [2]Pos) float {\n\treturn ps[1].X\n}\n\nfunc Use() float {\n\tps := [2]Pos{{1.0, 2.0}, {X: 3.0}}\n\treturn Take(ps) + Take([2]Pos{{Y: 5.0}})\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "spawns.go"))
+	ExpectCode(t, code, "Pos spawns[2] = {\n\t{ 1.0, 2.0 },\n\t{ 3.0, 0.0 }\n};", "Pos ps[2];", "ps[0].X = 1.0;", "ps[0].Y = 2.0;", "ps[1].X = 3.0;", "[0].Y = 5.0;")
+	if strings.Contains(code, "{ , }") || strings.Contains(code, "= {\n\t\t,") {
+		t.Errorf("struct literals of an array are left out:\n%s", code)
+	}
+}
+
+func TestClosureClients(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"announce.go": "package main\n\nimport (\n\t\"sourcemod\"\n\t\"datapack\"\n)\n\nfunc Announce(client, maxclient int, msg string) {\n\tCreateTimer(2.0, func(timer Timer, data any) Action {\n\t\tPrintToChat(client, \"%s %d\", msg, maxclient)\n\t\treturn Plugin_Stop\n\t}, nil, 0)\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "announce.go"))
+	ExpectCode(t, code, "if (client > 0 && IsClientInGame(client))", "WriteCell(GetClientUserId(client), false);", "WriteCell(0, false);", "WriteCell(maxclient, false);", "int client = GetClientOfUserId(closure_pack.ReadCell());", "int maxclient = closure_pack.ReadCell();")
+}
+
+func TestMethodMapMemberOrder(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, map[string]string{
+		"boss.go": "package main\n\nimport \"sourcemod\"\n\ntype BaseBoss Handle\n\nfunc NewBaseBoss(userid int) BaseBoss {\n\treturn BaseBoss(userid)\n}\n\nfunc (b BaseBoss) Kill(by int) bool {\n\treturn b.Health() > by\n}\n\nfunc (b BaseBoss) Health() int {\n\treturn GetClientHealth(b.Client())\n}\n\nfunc (b BaseBoss) SetHealth(hp int) {\n\tSetEntityHealth(b.Client(), hp)\n}\n\nfunc (b BaseBoss) Client() int {\n\treturn GetClientOfUserId(int(b))\n}\n",
+	})
+	code := TranspileTest(t, filepath.Join(dir, "boss.go"))
+	ExpectCode(t, code, "methodmap BaseBoss < Handle {", "public BaseBoss(int userid)", "property int Health {", "return this.Health > by;")
+	ctor, client, health, kill := strings.Index(code, "public BaseBoss("), strings.Index(code, "public int Client()"), strings.Index(code, "property int Health {"), strings.Index(code, "public bool Kill(")
+	if !(ctor < client && client < health && health < kill) {
+		t.Errorf("methodmap members are declared after members that use them:\n%s", code)
+	}
+}
@@ -121,11 +121,65 @@ const (
 )
 
 
-func SDKHook(entity Entity, hook SDKHookType, callback any)
-func SDKHookEx(entity Entity, hook SDKHookType, callback any) bool
+type SDKHookCB interface {
+	/// PreThink/Post, PostThink/Post, GroundEntChanged, Spawn/Post, Think/Post, VPhysicsUpdate/Post
+	func(client Entity) | func(entity Entity) Action
+	
+	/// EndTouch, StartTouch, Touch, Blocked
+	func(entity, other Entity) Action | func(entity, other Entity)
+	
+	/// SetTransmit
+	func(entity, client Entity) Action
+	
+	/// WeaponCanSwitchTo, WeaponCanUse, WeaponDrop, WeaponEquip, WeaponSwitch
+	func(client, weapon Entity) Action | func(client, weapon Entity)
+	
+	/// GetMaxHealth
+	func(entity Entity, maxhealth *int) Action
+	
+	/// OnTakeDamage, OnTakeDamageAlive
+	func(victim Entity, attacker, inflictor *Entity, damage *float, damagetype *int) Action |
+	func(victim Entity, attacker, inflictor *Entity, damage *float, damagetype, weapon *int, damageForce, damagePosition *Vec3) Action |
+	func(victim Entity, attacker, inflictor *Entity, damage *float, damagetype, weapon *int, damageForce, damagePosition *Vec3, damagecustom int) Action
+	
+	/// OnTakeDamagePost, OnTakeDamageAlivePost
+	func(victim, attacker, inflictor Entity, damage float, damagetype int) |
+	func(victim, attacker, inflictor Entity, damage float, damagetype, weapon int, damageForce, damagePosition Vec3) |
+	func(victim, attacker, inflictor Entity, damage float, damagetype, weapon int, damageForce, damagePosition Vec3, damagecustom int)
+	
+	/// FireBulletsPost
+	func(client Entity, shots int, weaponname string)
+	
+	/// TraceAttack
+	func(victim Entity, attacker, inflictor *Entity, damage *float, damagetype, ammotype *int, hitbox, hitgroup int) Action
+	
+	/// TraceAttackPost
+	func(victim, attacker, inflictor Entity, damage float, damagetype, ammotype, hitbox, hitgroup int)
+	
+	/// ShouldCollide
+	func(entity Entity, collisiongroup, contentsmask int, originalResult bool) bool
+	
+	/// Use
+	func(entity, activator, caller Entity, use_type UseType, value float) Action
+	
+	/// UsePost
+	func(entity, activator, caller Entity, use_type UseType, value float)
+	
+	/// Reload
+	func(weapon Entity) Action
+	
+	/// Reload post
+	func(weapon Entity, bSuccessful bool)
+	
+	/// CanBeAutobalanced
+	func(client Entity, origRet bool) bool
+}
+
+func SDKHook(entity Entity, hook SDKHookType, callback SDKHookCB)
+func SDKHookEx(entity Entity, hook SDKHookType, callback SDKHookCB) bool
 
-func SDKUnhook(entity Entity, hook SDKHookType, callback any)
-func SDKUnhookEx(entity Entity, hook SDKHookType, callback any) bool
+func SDKUnhook(entity Entity, hook SDKHookType, callback SDKHookCB)
+func SDKUnhookEx(entity Entity, hook SDKHookType, callback SDKHookCB) bool
 
 func SDKHooks_TakeDamage(entity, inflictor, attacker Entity, damage float, damageType, weapon int, damageForce, damagePosition Vec3)
 
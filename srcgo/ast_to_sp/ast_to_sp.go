@@ -15,16 +15,18 @@ package GoToSPGen
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"unicode"
 
 	//"bytes"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
 
 	//"go/format"
-	//"go/constant"
 	ASTMod "github.com/assyrianic/Go2SourcePawn/srcgo/ast_transform"
 )
 
@@ -37,6 +39,72 @@ var (
 	IdenNames = map[string]string{
 		"nil": "null",
 	}
+
+	NoIncludes = map[string]bool{
+		"fmt":    true,
+		"errors": true,
+	}
+
+	/// type declarations of the file being generated.
+	LocalTypes = make(map[string]*ast.TypeSpec)
+
+	/// methodmap name => parent name.
+	MethodMapParents = make(map[string]string)
+
+	/// constructor function name => methodmap name.
+	MethodMapCtors = make(map[string]string)
+
+	/// "Methodmap.Method" => property name, for getter and setter methods.
+	MethodMapProps = make(map[string]string)
+
+	/// struct name => its methods, to promote the methods of embedded structs.
+	StructMethods = make(map[string][]*ast.FuncDecl)
+
+	/// free functions that are referenced without being called, they have to be public.
+	PublicFuncs = make(map[string]bool)
+
+	/// forwards of SourceMod and the extensions of the bindings, SourceMod calls them by name so they're public.
+	KnownForwards = map[string]bool{
+		/// sourcemod
+		"AskPluginLoad2": true, "OnPluginStart": true, "OnPluginEnd": true, "OnPluginPauseChange": true,
+		"OnAllPluginsLoaded": true, "OnLibraryAdded": true, "OnLibraryRemoved": true, "OnNotifyPluginUnloaded": true,
+		"OnGameFrame": true, "OnMapInit": true, "OnMapStart": true, "OnMapEnd": true, "OnMapTimeLeftChanged": true,
+		"OnConfigsExecuted": true, "OnAutoConfigsBuffered": true, "OnServerCfg": true,
+		"OnClientFloodCheck": true, "OnClientFloodResult": true, "OnRebuildAdminCache": true, "OnLogAction": true,
+		"OnBanClient": true, "OnBanIdentity": true, "OnRemoveBan": true,
+		/// clients and console
+		"OnClientConnect": true, "OnClientConnected": true, "OnClientPutInServer": true,
+		"OnClientDisconnect": true, "OnClientDisconnect_Post": true, "OnClientAuthorized": true,
+		"OnClientPreAdminCheck": true, "OnClientPostAdminFilter": true, "OnClientPostAdminCheck": true,
+		"OnClientSettingsChanged": true, "OnClientLanguageChanged": true,
+		"OnClientCommand": true, "OnClientCommandKeyValues": true, "OnClientCommandKeyValues_Post": true,
+		"OnClientSayCommand": true, "OnClientSayCommand_Post": true,
+		/// sdktools and sdkhooks
+		"OnPlayerRunCmd": true, "OnPlayerRunCmdPost": true, "OnFileSend": true, "OnFileReceive": true,
+		"OnEntityCreated": true, "OnEntityDestroyed": true, "OnGetGameDescription": true, "OnLevelInit": true,
+		/// clientprefs
+		"OnClientCookiesCached": true,
+		/// cstrike
+		"CS_OnBuyCommand": true, "CS_OnCSWeaponDrop": true, "CS_OnGetWeaponPrice": true, "CS_OnTerminateRound": true,
+		/// tf2
+		"TF2_CalcIsAttackCritical": true, "TF2_OnGetHoliday": true, "TF2_OnIsHolidayActive": true,
+		"TF2_OnConditionAdded": true, "TF2_OnConditionRemoved": true, "TF2_OnPlayerTeleport": true,
+		"TF2_OnWaitingForPlayersStart": true, "TF2_OnWaitingForPlayersEnd": true,
+		/// tf2items
+		"TF2Items_OnGiveNamedItem": true, "TF2Items_OnGiveNamedItem_Post": true,
+	}
+
+	/// set when each Go file of a package gets its own include, static functions would only be visible in their include.
+	SplitPackage bool
+
+	/// comments of the file being generated, for directives on statements.
+	Comments ast.CommentMap
+
+	/// the native that converts floats to ints, 'RoundToZero' truncates like Go does.
+	FloatToInt = "RoundToZero"
+
+	/// what 'panic' becomes in the function being generated.
+	PanicFunc = "ThrowError"
 )
 
 const (
@@ -65,10 +133,19 @@ func WriteTabStr(count uint) string {
 
 type (
 	FuncBlock struct {
-		Body                   strings.Builder
+		Body                   CodeBuilder
 		Tabs                   uint
 		Params                 []string
 		Storage, RetType, Name string
+
+		/// the innermost string switch wrapped in a do-while(false), nil inside loops.
+		StrSwitch   *StrSwitchFlags
+		StrSwitches uint
+	}
+
+	/// flags that carry a break or continue to the loops around a wrapped string switch.
+	StrSwitchFlags struct {
+		Break, Continue string
 	}
 
 	EStruct struct {
@@ -76,19 +153,164 @@ type (
 		Fields  []string
 	}
 
+	MethodMapProp struct {
+		Getter, Setter FuncBlock
+		TypeName, Name string
+	}
+
 	MethodMap struct {
-		Methods, Props []FuncBlock
-		Name           string
+		Methods      []FuncBlock
+		Props        []MethodMapProp
+		Name, Parent string
+	}
+
+	/// generated code and the Go code its lines came from.
+	CodeBuilder struct {
+		strings.Builder
+		Lines []SourceLine
+	}
+
+	/// 'Line' counts from 0, 'Pos' is invalid for lines that don't come from Go code.
+	SourceLine struct {
+		Line int
+		Pos  token.Pos
+	}
+
+	GeneratedFile struct {
+		Code  string
+		Lines []SourceLine
+	}
+
+	/// the Go code each line of a generated file came from, lines count from 1.
+	SourceMap struct {
+		File  string          `json:"file"`
+		Lines []SourceMapLine `json:"lines"`
+	}
+
+	SourceMapLine struct {
+		Line   int    `json:"line"`
+		GoFile string `json:"go_file"`
+		GoLine int    `json:"go_line"`
 	}
 
 	SMPlugin struct {
 		Includes, Globals []string
+		Enums, Consts     []string
+		TypeDefs          map[string]string
 		Structs           map[string]EStruct
-		//MethodMaps []MethodMap
-		Funcs []FuncBlock
+		MethodMaps        []MethodMap
+		Funcs             []FuncBlock
 	}
 )
 
+// the line that's being written, counting from 0.
+func (code *CodeBuilder) CurrLine() int {
+	return strings.Count(code.String(), "\n")
+}
+
+// maps the line that's being written to Go code.
+func (code *CodeBuilder) MarkPos(pos token.Pos) {
+	if pos.IsValid() {
+		code.Lines = append(code.Lines, SourceLine{Line: code.CurrLine(), Pos: pos})
+	}
+}
+
+// the lines after the one that's being written don't come from Go code.
+func (code *CodeBuilder) EndPos() {
+	code.Lines = append(code.Lines, SourceLine{Line: code.CurrLine() + 1})
+}
+
+// writes a block of code along with the Go code its lines came from.
+func (code *CodeBuilder) WriteCode(block *CodeBuilder) {
+	line := code.CurrLine()
+	for _, l := range block.Lines {
+		code.Lines = append(code.Lines, SourceLine{Line: line + l.Line, Pos: l.Pos})
+	}
+	code.WriteString(block.String())
+	code.EndPos()
+}
+
+func (code *CodeBuilder) Generated() GeneratedFile {
+	return GeneratedFile{Code: code.String(), Lines: code.Lines}
+}
+
+// inserts code after the header, the lines after it are moved down.
+func (gen GeneratedFile) InsertAfterHeader(code string) GeneratedFile {
+	return gen.insertAt(0, code)
+}
+
+// library includes declare what the inserted code uses, so it goes after them.
+func (gen GeneratedFile) InsertAfterIncludes(code string) GeneratedFile {
+	body := strings.TrimPrefix(gen.Code, Header)
+	end := 0
+	for strings.HasPrefix(body[end:], "#include") {
+		end += strings.Index(body[end:], "\n") + 1
+	}
+	return gen.insertAt(end, code)
+}
+
+// the mapped lines all come after 'at', which is an offset past the header.
+func (gen GeneratedFile) insertAt(at int, code string) GeneratedFile {
+	moved := strings.Count(code, "\n")
+	lines := make([]SourceLine, len(gen.Lines))
+	for i, l := range gen.Lines {
+		lines[i] = SourceLine{Line: l.Line + moved, Pos: l.Pos}
+	}
+	body := strings.TrimPrefix(gen.Code, Header)
+	return GeneratedFile{Code: Header + body[:at] + code + body[at:], Lines: lines}
+}
+
+// finds the Go code a line of the generated code came from, 'line' counts from 1 like compiler messages.
+// a line that has no mapping of its own belongs to the statement before it.
+func (gen GeneratedFile) GoPosition(line int) (token.Position, bool) {
+	found := -1
+	for i, l := range gen.Lines {
+		if l.Line > line-1 {
+			break
+		} else if found < 0 || gen.Lines[found].Line != l.Line {
+			found = i
+		}
+	}
+	if found < 0 || !gen.Lines[found].Pos.IsValid() {
+		return token.Position{}, false
+	}
+	return ASTMod.ASTCtxt.FSet.Position(gen.Lines[found].Pos), true
+}
+
+// every line of the generated code that came from Go code.
+func (gen GeneratedFile) MakeSourceMap(filename string) SourceMap {
+	source_map := SourceMap{File: filename, Lines: make([]SourceMapLine, 0)}
+	for line := 1; line <= strings.Count(gen.Code, "\n")+1; line++ {
+		if go_pos, mapped := gen.GoPosition(line); mapped {
+			source_map.Lines = append(source_map.Lines, SourceMapLine{Line: line, GoFile: go_pos.Filename, GoLine: go_pos.Line})
+		}
+	}
+	return source_map
+}
+
+func (source_map SourceMap) FindLine(line int) *SourceMapLine {
+	for i := range source_map.Lines {
+		if source_map.Lines[i].Line == line {
+			return &source_map.Lines[i]
+		}
+	}
+	return nil
+}
+
+// puts '/* file.go:123 */' after the lines that start Go statements.
+func (gen GeneratedFile) WithLineComments() GeneratedFile {
+	lines := strings.Split(gen.Code, "\n")
+	for i := len(gen.Lines) - 1; i >= 0; i-- {
+		l := gen.Lines[i]
+		if !l.Pos.IsValid() || l.Line >= len(lines) || (i > 0 && gen.Lines[i-1].Line == l.Line) {
+			continue
+		}
+		pos := ASTMod.ASTCtxt.FSet.Position(l.Pos)
+		lines[l.Line] += fmt.Sprintf(" /* %s:%d */", filepath.Base(pos.Filename), pos.Line)
+	}
+	return GeneratedFile{Code: strings.Join(lines, "\n"), Lines: gen.Lines}
+}
+
 func ReplaceName(s *string, typ, rep string) bool {
 	if strings.Contains(*s, typ) {
 		*s = strings.Replace(*s, typ, rep, -1)
@@ -126,83 +348,309 @@ func (ts TypeString) Join(param, is_ref, is_array bool) string {
 	return total.String()
 }
 
+// where a type is written decides where its brackets go and how big its strings are.
+type TypePos int
+
+const (
+	TypePosReturn TypePos = iota
+	TypePosParam
+	TypePosVar
+	TypePosField
+)
+
 /**
- * SP params -> [const] TypeName ([]... | &) VarName ([N]...)
+ * SP params -> [const] TypeName ([]... | &) VarName   when every dimension is unsized
+ *              [const] TypeName VarName [N]...        otherwise
+ *              char[] VarName                         for '[]char' buffers that the function writes to
  * SP vars   ->         TypeName VarName ([(N)]...)
- * SP ret    ->         TypeName ([])
+ * SP fields ->         TypeName VarName ([N]...)
+ * SP ret    ->         TypeName ([N]...)
+ *
+ * strings are the last dimension, they're only unsized when they're given a size some other way:
+ * by the caller for params and returns, or by an initializer or 'StrBufferSizes' for variables.
  */
 func GetTypeString(expr ast.Expr, name string, param bool) string {
-	ts := TypeString{Name: name}
-	var is_ref, is_array bool
-	if typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(expr); typ != nil {
-	recheck:
-		original := typ.String()
-		type_name := strings.Replace(original, "untyped ", "", -1)
-		if strings.HasPrefix(type_name, "func(") {
-			ts.TypeName = "Function"
-		} else if type_name == "invalid type" {
-			ts.TypeName = "any"
-		} else {
-			//if n, found := TypeNames[type_name]; found {
-			//	type_name = n
-			//}
-			type_name = strings.TrimFunc(type_name, func(r rune) bool {
-				return !unicode.IsLetter(r)
-			})
-			ts.TypeName = type_name
-		}
+	pos := TypePosVar
+	if param {
+		pos = TypePosParam
+	} else if name == "" {
+		pos = TypePosReturn
+	}
+	return MakeTypeString(ASTMod.ASTCtxt.TypeInfo.TypeOf(expr), name, pos, expr.Pos())
+}
 
+func MakeTypeString(typ types.Type, name string, pos TypePos, err_pos token.Pos) string {
+	ts := TypeString{Name: name}
+	var dims []string
+	var is_ref, is_array, is_buffer bool
+	for typ != nil && ts.TypeName == "" {
 		switch t := typ.(type) {
+		case *types.Alias:
+			/// aliases like 'Entity' and 'Vec3' are written as what they stand for, except the builtin 'any'.
+			if t.Obj().Pkg() == nil {
+				ts.TypeName = "any"
+			} else {
+				typ = types.Unalias(t)
+			}
+		case *types.Pointer:
+			if is_ref {
+				ASTMod.PrintSrcGoErr(err_pos, "Multi-Pointers are Illegal.")
+			}
+			is_ref = true
+			typ = t.Elem()
 		case *types.Array:
-			//fmt.Printf("Array::type_name: %s\n", type_name)
-			ts.RhsBracks += fmt.Sprintf("[%d]", t.Len())
+			dims = append(dims, fmt.Sprintf("[%d]", t.Len()))
 			is_array = true
 			typ = t.Elem()
-			goto recheck
-		case *types.Named:
-			//fmt.Printf("Named::type_name: %s\n", type_name)
-			if _, is_struct := t.Underlying().(*types.Struct); is_struct {
-				is_array = true
-			} /*else {
-				typ = t.Underlying()
-				goto recheck
-			}*/
-		//case *types.Map:
-		//	ts.TypeName = "StringMap"
 		case *types.Slice:
-			//fmt.Printf("Slice::type_name: %s\n", type_name)
-			typ = t.Elem()
-			if param {
-				ts.LhsBracks += "[]"
-			} else {
-				ts.RhsBracks += "[]"
-			}
+			/// unlike strings, a '[]char' shares its chars with the caller.
+			is_buffer = !is_array && ASTMod.IsCharType(t.Elem())
+			dims = append(dims, "[]")
 			is_array = true
-			goto recheck
-		case *types.Pointer:
-			//fmt.Printf("Pointer::type_name: %s\n", type_name)
-			if !is_ref {
-				is_ref = true
-			} else {
-				ASTMod.PrintSrcGoErr(expr.Pos(), "Multi-Pointers are Illegal.")
-			}
 			typ = t.Elem()
-			goto recheck
+		case *types.Map:
+			ts.TypeName = "StringMap"
 		case *types.Basic:
-			//fmt.Printf("Basic::type_name: %s\n", type_name)
-			if type_name == "string" {
+			if t.Info()&types.IsString > 0 {
+				ts.TypeName = "char"
+				if pos == TypePosField || (pos == TypePosVar && len(dims) > 0) {
+					dims = append(dims, fmt.Sprintf("[%d]", ASTMod.StrBufferLen))
+				} else {
+					dims = append(dims, "[]")
+				}
+				is_array = true
+			} else {
+				ts.TypeName = GetTypeName(t)
+			}
+		case *types.Named:
+			if ASTMod.IsErrorType(t) {
+				/// errors are strings that are written after they're made, so their variables always need a buffer.
 				ts.TypeName = "char"
-				if param {
-					ts.LhsBracks += "[]"
+				if pos == TypePosField || pos == TypePosVar {
+					dims = append(dims, fmt.Sprintf("[%d]", ASTMod.StrBufferLen))
 				} else {
-					ts.RhsBracks += "[]"
+					dims = append(dims, "[]")
 				}
 				is_array = true
+				continue
+			}
+			/// enum structs are passed like arrays.
+			if _, is_struct := t.Underlying().(*types.Struct); is_struct && !IsMethodMapType(t) {
+				is_array = true
+			}
+			ts.TypeName = GetTypeName(t)
+		default:
+			ts.TypeName = GetTypeName(t)
+		}
+	}
+
+	brackets := strings.Join(dims, "")
+	switch pos {
+	case TypePosReturn:
+		ts.LhsBracks = brackets
+	case TypePosParam:
+		if strings.Count(brackets, "[]")*2 == len(brackets) {
+			ts.LhsBracks = brackets
+		} else {
+			ts.RhsBracks = brackets
+		}
+	default:
+		ts.RhsBracks = brackets
+	}
+	return ts.Join(pos == TypePosParam && !is_buffer, is_ref, is_array)
+}
+
+// the SourcePawn name of a type whose arrays, pointers and strings are already written.
+// Go's sized numbers are cells, so they're all 'int' or 'float', except bytes which are what strings are made of.
+func GetTypeName(typ types.Type) string {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Basic:
+		switch {
+		case t.Kind() == types.Byte:
+			return "char"
+		case t.Info()&types.IsBoolean > 0:
+			return "bool"
+		case t.Info()&types.IsInteger > 0:
+			return "int"
+		case t.Info()&types.IsFloat > 0:
+			return "float"
+		case t.Kind() == types.Invalid || t.Kind() == types.UntypedNil:
+			return "any"
+		}
+		return strings.Replace(t.Name(), "untyped ", "", -1)
+	case *types.Named:
+		return t.Obj().Name()
+	case *types.Signature:
+		return "Function"
+	case *types.Interface:
+		return "any"
+	}
+	return strings.TrimLeft(typ.String(), "[]*")
+}
+
+// slices that grow or escape are ArrayLists, which are handles instead of arrays.
+func GetVarTypeString(name *ast.Ident, typ ast.Expr, param bool) string {
+	if ASTMod.IsArrayList(name) {
+		return "ArrayList " + name.Name
+	}
+	return GetTypeString(typ, name.Name, param)
+}
+
+// Handle, methodmaps from includes, and types derived from them are methodmaps.
+func IsMethodMapType(typ types.Type) bool {
+	named, is_named := typ.(*types.Named)
+	if !is_named {
+		return false
+	}
+	name := named.Obj().Name()
+	if name == "Handle" {
+		return true
+	} else if _, found := MethodMapParents[name]; found {
+		return true
+	} else if _, local := LocalTypes[name]; local {
+		return false
+	}
+	/// methodmaps from includes are declared as structs.
+	_, is_struct := named.Underlying().(*types.Struct)
+	return is_struct
+}
+
+// type BaseBoss Handle => methodmap BaseBoss < Handle
+func GetMethodMapParent(type_spec *ast.TypeSpec) string {
+	if type_spec.Assign.IsValid() {
+		return ""
+	}
+	parent, is_named := ASTMod.ASTCtxt.TypeInfo.TypeOf(type_spec.Type).(*types.Named)
+	if !is_named {
+		return ""
+	}
+	name := parent.Obj().Name()
+	if name == "Handle" {
+		return name
+	} else if parent_spec, local := LocalTypes[name]; local {
+		if parent_spec == type_spec || GetMethodMapParent(parent_spec) == "" {
+			return ""
+		}
+		return name
+	} else if IsMethodMapType(parent) {
+		return name
+	}
+	return ""
+}
+
+/**
+ * func (b BaseBoss) Health() int
+ * func (b BaseBoss) SetHealth(v int)
+ *
+ * Becomes:
+ * property int Health {
+ *     public get() {}
+ *     public set(int v) {}
+ * }
+ */
+func FindMethodMapProps(file *ast.File) {
+	getters := make(map[string]*ast.FuncDecl)
+	for _, d := range file.Decls {
+		if f, is_func := d.(*ast.FuncDecl); is_func && f.Recv != nil && f.Type.Params.NumFields() == 0 && f.Type.Results.NumFields() == 1 {
+			if recv := GetRecvTypeName(f.Recv); MethodMapParents[recv] != "" {
+				getters[recv+"."+f.Name.Name] = f
 			}
 		}
 	}
-	//fmt.Printf("\n")
-	return ts.Join(param, is_ref, is_array)
+	for _, d := range file.Decls {
+		f, is_func := d.(*ast.FuncDecl)
+		if !is_func || f.Recv == nil || !strings.HasPrefix(f.Name.Name, "Set") || f.Type.Params.NumFields() != 1 || f.Type.Results.NumFields() != 0 {
+			continue
+		}
+		recv, prop := GetRecvTypeName(f.Recv), f.Name.Name[len("Set"):]
+		if getter, found := getters[recv+"."+prop]; found {
+			get_type := ASTMod.ASTCtxt.TypeInfo.TypeOf(getter.Type.Results.List[0].Type)
+			set_type := ASTMod.ASTCtxt.TypeInfo.TypeOf(f.Type.Params.List[0].Type)
+			if get_type != nil && set_type != nil && types.Identical(get_type, set_type) {
+				MethodMapProps[recv+"."+prop] = prop
+				MethodMapProps[recv+"."+f.Name.Name] = prop
+			}
+		}
+	}
+}
+
+// b.Health() => b.Health, b.SetHealth(v) => b.Health = v
+func GetPropAccess(call *ast.CallExpr) string {
+	sel, is_sel := call.Fun.(*ast.SelectorExpr)
+	if !is_sel {
+		return ""
+	}
+	typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(sel.X)
+	if ptr, is_ptr := typ.(*types.Pointer); is_ptr {
+		typ = ptr.Elem()
+	}
+	named, is_named := typ.(*types.Named)
+	if !is_named {
+		return ""
+	}
+	prop, found := MethodMapProps[named.Obj().Name()+"."+sel.Sel.Name]
+	if !found {
+		return ""
+	} else if len(call.Args) == 1 {
+		return GetExprString(sel.X) + "." + prop + " = " + GetExprString(call.Args[0])
+	}
+	return GetExprString(sel.X) + "." + prop
+}
+
+func (methodmap *MethodMap) FindProp(name string) *MethodMapProp {
+	for i := range methodmap.Props {
+		if methodmap.Props[i].Name == name {
+			return &methodmap.Props[i]
+		}
+	}
+	methodmap.Props = append(methodmap.Props, MethodMapProp{Name: name})
+	return &methodmap.Props[len(methodmap.Props)-1]
+}
+
+// func NewBaseBoss(...) BaseBoss => public BaseBoss(...)
+func GetMethodMapCtor(f *ast.FuncDecl) string {
+	if f.Recv != nil || f.Body == nil || !strings.HasPrefix(f.Name.Name, "New") || f.Type.Results == nil || len(f.Type.Results.List) != 1 {
+		return ""
+	}
+	methodmap := f.Name.Name[len("New"):]
+	if ret, is_ident := f.Type.Results.List[0].Type.(*ast.Ident); is_ident && ret.Name == methodmap {
+		if _, found := MethodMapParents[methodmap]; found {
+			return methodmap
+		}
+	}
+	return ""
+}
+
+func GetRecvTypeName(recv *ast.FieldList) string {
+	typ := recv.List[0].Type
+	if ptr, is_ptr := typ.(*ast.StarExpr); is_ptr {
+		typ = ptr.X
+	}
+	if iden, is_ident := typ.(*ast.Ident); is_ident {
+		return iden.Name
+	}
+	return ""
+}
+
+// public [native] [RetType] Name(params)
+func (fn *FuncBlock) MethodMapHeader() string {
+	header := "public "
+	if fn.Storage == "native" {
+		header += "native "
+	}
+	if fn.RetType != "" {
+		header += fn.RetType + " "
+	}
+	return header + fn.Name + "(" + strings.Join(fn.Params, ", ") + ")"
+}
+
+func (plugin *SMPlugin) FindMethodMap(name string) *MethodMap {
+	for i := range plugin.MethodMaps {
+		if plugin.MethodMaps[i].Name == name {
+			return &plugin.MethodMaps[i]
+		}
+	}
+	return nil
 }
 
 func IsSameType(a ast.Expr, b []ast.Expr, param bool) bool {
@@ -219,7 +667,7 @@ func WriteParams(flist *ast.FieldList) []string {
 	param_list := make([]string, 0)
 	for _, parm := range flist.List {
 		for _, name := range parm.Names {
-			param_list = append(param_list, GetTypeString(parm.Type, name.Name, true))
+			param_list = append(param_list, GetVarTypeString(name, parm.Type, true))
 		}
 	}
 	return param_list
@@ -228,22 +676,265 @@ func WriteParams(flist *ast.FieldList) []string {
 func WriteStructMembs(flist *ast.FieldList) []string {
 	field_list := make([]string, 0)
 	for _, field := range flist.List {
+		if field.Names == nil {
+			/// embedded structs are flattened into the struct embedding them.
+			if embedded := GetEmbeddedStruct(field); embedded != nil {
+				field_list = append(field_list, WriteStructMembs(embedded.Fields)...)
+			}
+			continue
+		}
 		for _, member_name := range field.Names {
-			field_str := GetTypeString(field.Type, member_name.Name, false)
+			field_str := "ArrayList " + member_name.Name
+			if !ASTMod.IsArrayList(member_name) {
+				field_str = MakeTypeString(ASTMod.ASTCtxt.TypeInfo.TypeOf(field.Type), member_name.Name, TypePosField, field.Pos())
+			}
 			field_list = append(field_list, field_str)
 		}
 	}
 	return field_list
 }
 
-func GeneratePluginFile(file *ast.File) string {
-	var plugin_src_code strings.Builder
-	plugin := SMPlugin{Structs: make(map[string]EStruct)}
+func GetEmbeddedStruct(field *ast.Field) *ast.StructType {
+	if iden, is_ident := field.Type.(*ast.Ident); is_ident {
+		if type_spec, local := LocalTypes[iden.Name]; local {
+			if struct_type, is_struct := type_spec.Type.(*ast.StructType); is_struct {
+				return struct_type
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * type Player struct {
+ *     BaseEntityData
+ * }
+ * func (b BaseEntityData) IsAlive() bool
+ *
+ * Becomes:
+ * enum struct Player {
+ *     bool IsAlive() {}
+ * }
+ *
+ * the fields are flattened, so the method's code works the same in the struct embedding it.
+ * a method the struct overrides is promoted as 'BaseEntityData_IsAlive' for 'p.BaseEntityData.IsAlive()'.
+ */
+func (plugin *SMPlugin) PromoteMethods(type_spec *ast.TypeSpec) {
+	struct_type, is_struct := type_spec.Type.(*ast.StructType)
+	if !is_struct {
+		return
+	}
+	defined := make(map[string]bool)
+	for _, f := range StructMethods[type_spec.Name.Name] {
+		defined[f.Name.Name] = true
+	}
+	for _, f := range GetPromotedMethods(struct_type) {
+		promoted := *f
+		promoted.Recv = &ast.FieldList{List: []*ast.Field{{Names: f.Recv.List[0].Names, Type: type_spec.Name}}}
+		if defined[f.Name.Name] {
+			promoted.Name = ast.NewIdent(GetRecvTypeName(f.Recv) + "_" + f.Name.Name)
+		}
+		defined[promoted.Name.Name] = true
+		plugin.MakeFuncDecl(&promoted)
+	}
+}
+
+// the methods of embedded structs, embedded structs of embedded structs included.
+func GetPromotedMethods(struct_type *ast.StructType) []*ast.FuncDecl {
+	var promoted []*ast.FuncDecl
+	for _, field := range struct_type.Fields.List {
+		embedded := GetEmbeddedStruct(field)
+		if field.Names != nil || embedded == nil {
+			continue
+		}
+		promoted = append(promoted, StructMethods[field.Type.(*ast.Ident).Name]...)
+		promoted = append(promoted, GetPromotedMethods(embedded)...)
+	}
+	return promoted
+}
+
+func GeneratePluginFile(file *ast.File) GeneratedFile {
+	FindPluginInfo(file)
+	return GenerateCode(file)
+}
+
+// every Go file of a package gets its own include with an include guard and the plugin file includes them.
+// code made by the transpiler that doesn't belong to a file goes into the plugin file.
+func GenerateSplitPlugin(file *ast.File, filenames []string, library string) (GeneratedFile, []GeneratedFile) {
+	FindPluginInfo(file)
+	SplitPackage = true
+	defer func() { SplitPackage = false }()
+
+	file_decls := make([][]ast.Decl, len(filenames))
+	var plugin_decls []ast.Decl
+	for _, d := range file.Decls {
+		index := -1
+		if pos := GetDeclPos(d); pos.IsValid() {
+			filename := ASTMod.ASTCtxt.FSet.Position(pos).Filename
+			for i := range filenames {
+				if filenames[i] == filename {
+					index = i
+				}
+			}
+		}
+		if index < 0 {
+			plugin_decls = append(plugin_decls, d)
+		} else {
+			file_decls[index] = append(file_decls[index], d)
+		}
+	}
+
+	includes := make([]GeneratedFile, len(filenames))
+	file_deps := make([][]string, len(filenames))
+	for i, filename := range filenames {
+		file_deps[i] = GetFileDeps(file_decls[i], filename, filenames)
+	}
+	CheckFileDepCycles(file_decls, file_deps, filenames)
+	var plugin_includes strings.Builder
+	for i, filename := range filenames {
+		name := SplitIncludeName(library, filename)
+		guard := "_" + name + "_included"
+		var deps strings.Builder
+		for _, dep := range file_deps[i] {
+			deps.WriteString(`#include "` + SplitIncludeName(library, dep) + `.inc"` + "\n")
+		}
+		code := GenerateCode(&ast.File{Name: file.Name, Decls: file_decls[i]})
+		includes[i] = code.InsertAfterHeader(fmt.Sprintf("#if defined %s\n\t#endinput\n#endif\n#define %s\n\n", guard, guard) + deps.String())
+		plugin_includes.WriteString(`#include "` + name + `.inc"` + "\n")
+	}
+	plugin_code := GenerateCode(&ast.File{Name: file.Name, Decls: plugin_decls})
+	return plugin_code.InsertAfterIncludes(plugin_includes.String()), includes
+}
+
+// include guards skip an include that's already being read, so files using each other's types or globals can't be ordered.
+func CheckFileDepCycles(file_decls [][]ast.Decl, file_deps [][]string, filenames []string) {
+	index := make(map[string]int)
+	for i, filename := range filenames {
+		index[filename] = i
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(filenames))
+	var visit func(i int, path []string)
+	visit = func(i int, path []string) {
+		states[i] = visiting
+		path = append(path, filepath.Base(filenames[i]))
+		for _, dep := range file_deps[i] {
+			switch j := index[dep]; states[j] {
+			case unvisited:
+				visit(j, path)
+			case visiting:
+				if len(file_decls[i]) > 0 {
+					ASTMod.PrintSrcGoErr(file_decls[i][0].Pos(), fmt.Sprintf("Split includes can't be ordered, these files use each other's types or globals: %s -> %s.", strings.Join(path, " -> "), filepath.Base(dep)))
+				}
+			}
+		}
+		states[i] = visited
+	}
+	for i := range filenames {
+		if states[i] == unvisited {
+			visit(i, nil)
+		}
+	}
+}
+
+// types and globals have to be declared before they're used, so an include first includes the files declaring the ones it uses.
+func GetFileDeps(decls []ast.Decl, filename string, filenames []string) []string {
+	used := make(map[string]bool)
+	for _, d := range decls {
+		ast.Inspect(d, func(n ast.Node) bool {
+			if ident, is_ident := n.(*ast.Ident); is_ident {
+				obj := ASTMod.ASTCtxt.TypeInfo.Uses[ident]
+				if obj == nil || !obj.Pos().IsValid() || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+					return true
+				} else if _, is_func := obj.(*types.Func); !is_func {
+					used[ASTMod.ASTCtxt.FSet.Position(obj.Pos()).Filename] = true
+				}
+			}
+			return true
+		})
+	}
+	var deps []string
+	for _, dep := range filenames {
+		if dep != filename && used[dep] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// the includes are prefixed with the library so they don't clash with the include of its natives.
+func SplitIncludeName(library, filename string) string {
+	return library + "_" + strings.TrimSuffix(filepath.Base(filename), ".go")
+}
+
+// methods go with the type they belong to, so they're generated inside its enum struct or methodmap.
+func GetDeclPos(d ast.Decl) token.Pos {
+	if f, is_func := d.(*ast.FuncDecl); is_func {
+		if f.Recv != nil {
+			if type_spec := LocalTypes[GetRecvTypeName(f.Recv)]; type_spec != nil {
+				return type_spec.Pos()
+			}
+		} else if type_spec := LocalTypes[MethodMapCtors[f.Name.Name]]; type_spec != nil {
+			return type_spec.Pos()
+		}
+	}
+	return d.Pos()
+}
+
+// finds what the declarations of a file need to know about each other before they're generated.
+func FindPluginInfo(file *ast.File) {
+	LocalTypes = make(map[string]*ast.TypeSpec)
+	MethodMapParents = make(map[string]string)
+	MethodMapCtors = make(map[string]string)
+	MethodMapProps = make(map[string]string)
+	PublicFuncs = FindPublicFuncs(file)
+	Comments = ast.NewCommentMap(ASTMod.ASTCtxt.FSet, file, file.Comments)
+	for _, d := range file.Decls {
+		if decl, is_gendecl := d.(*ast.GenDecl); is_gendecl && decl.Tok == token.TYPE {
+			for _, spec := range decl.Specs {
+				type_spec := spec.(*ast.TypeSpec)
+				LocalTypes[type_spec.Name.Name] = type_spec
+			}
+		}
+	}
+	for _, type_spec := range LocalTypes {
+		if parent := GetMethodMapParent(type_spec); parent != "" {
+			MethodMapParents[type_spec.Name.Name] = parent
+		}
+	}
+	StructMethods = make(map[string][]*ast.FuncDecl)
+	for _, d := range file.Decls {
+		if f, is_func := d.(*ast.FuncDecl); is_func {
+			if methodmap := GetMethodMapCtor(f); methodmap != "" {
+				MethodMapCtors[f.Name.Name] = methodmap
+			}
+			if f.Recv != nil {
+				if type_spec, local := LocalTypes[GetRecvTypeName(f.Recv)]; local {
+					if _, is_struct := type_spec.Type.(*ast.StructType); is_struct {
+						StructMethods[type_spec.Name.Name] = append(StructMethods[type_spec.Name.Name], f)
+					}
+				}
+			}
+		}
+	}
+	FindMethodMapProps(file)
+}
+
+func GenerateCode(file *ast.File) GeneratedFile {
+	var plugin_src_code CodeBuilder
+	plugin := SMPlugin{Structs: make(map[string]EStruct), TypeDefs: make(map[string]string)}
+
 	/// read imports.
 	ast.Inspect(file, func(n ast.Node) bool {
 		if n != nil {
 			if imp, is_import := n.(*ast.ImportSpec); is_import {
-				if imp.Path.Value[1] == '.' {
+				if NoIncludes[imp.Path.Value[1:len(imp.Path.Value)-1]] {
+					/// bindings that only exist for the transpiler.
+				} else if imp.Path.Value[1] == '.' {
 					plugin.Includes = append(plugin.Includes, `#include "`+imp.Path.Value[2:]+`"`)
 				} else {
 					plugin.Includes = append(plugin.Includes, "#include <"+imp.Path.Value[1:len(imp.Path.Value)-1]+">")
@@ -253,19 +944,25 @@ func GeneratePluginFile(file *ast.File) string {
 		return true
 	})
 
+	var const_decls []*ast.GenDecl
+	var type_specs []*ast.TypeSpec
 	ast.Inspect(file, func(n ast.Node) bool {
 		if n != nil {
 			if decl, is_gendecl := n.(*ast.GenDecl); is_gendecl {
 				switch decl.Tok {
 				case token.CONST:
-					for _, spec := range decl.Specs {
-						plugin.Globals = append(plugin.Globals, MakeConstSpec(spec.(*ast.ValueSpec), 0))
+					if IsIotaGroup(decl) {
+						if enum := MakeEnum(decl); enum != "" {
+							plugin.Enums = append(plugin.Enums, enum)
+						}
+						break
 					}
-					plugin.Globals = append(plugin.Globals, "\n")
+					const_decls = append(const_decls, decl)
 
 				case token.TYPE:
 					for _, spec := range decl.Specs {
 						plugin.MakeTypeSpec(spec.(*ast.TypeSpec))
+						type_specs = append(type_specs, spec.(*ast.TypeSpec))
 					}
 				}
 			}
@@ -273,45 +970,61 @@ func GeneratePluginFile(file *ast.File) string {
 		return true
 	})
 
+	/// constants are written before the types that might use them as array sizes.
+	for _, i := range SortConstDecls(const_decls) {
+		for _, spec := range const_decls[i].Specs {
+			plugin.Consts = append(plugin.Consts, MakeConstSpec(spec.(*ast.ValueSpec), 0))
+		}
+		plugin.Consts = append(plugin.Consts, "\n")
+	}
+
 	plugin_src_code.WriteString(Header)
 	for _, inc := range plugin.Includes {
 		plugin_src_code.WriteString(inc + "\n")
 	}
 	plugin_src_code.WriteString("\n")
+	for _, enum := range plugin.Enums {
+		plugin_src_code.WriteString(enum + "\n\n")
+	}
+	for _, constant := range plugin.Consts {
+		plugin_src_code.WriteString(constant + "\n")
+	}
 
-	single_tab := WriteTabStr(1)
-	for name, struc := range plugin.Structs {
-		plugin_src_code.WriteString(fmt.Sprintf("enum struct %s {", name))
-		for _, field := range struc.Fields {
-			plugin_src_code.WriteString("\n" + single_tab + field + ";")
+	for _, d := range file.Decls {
+		if f, is_func := d.(*ast.FuncDecl); is_func {
+			plugin.MakeFuncDecl(f)
 		}
-		if struc.Methods != nil {
-			plugin_src_code.WriteString("\n\n")
-			for i, method := range struc.Methods {
-				plugin_src_code.WriteString(single_tab + method.RetType + " " + method.Name + "(")
-				plugin_src_code.WriteString(strings.Join(method.Params, ", "))
-				plugin_src_code.WriteString(")" + method.Body.String())
-				if i+1 != len(struc.Methods) {
-					plugin_src_code.WriteString("\n")
-				}
-			}
+	}
+	for _, type_spec := range type_specs {
+		plugin.PromoteMethods(type_spec)
+	}
+
+	for _, i := range SortTypeSpecs(type_specs) {
+		name := type_specs[i].Name.Name
+		if typedef, found := plugin.TypeDefs[name]; found {
+			plugin_src_code.WriteString(typedef + "\n\n")
+		} else if struc, found := plugin.Structs[name]; found {
+			struc.Write(&plugin_src_code, name)
+		} else if methodmap := plugin.FindMethodMap(name); methodmap != nil {
+			methodmap.Write(&plugin_src_code)
 		}
-		plugin_src_code.WriteString("\n}\n\n")
 	}
 
+	var var_specs []*ast.ValueSpec
 	for _, d := range file.Decls {
 		switch decl := d.(type) {
 		case *ast.GenDecl:
 			switch decl.Tok {
 			case token.VAR:
 				for _, spec := range decl.Specs {
-					plugin.Globals = append(plugin.Globals, MakeVarSpec(spec.(*ast.ValueSpec), 0))
+					var_specs = append(var_specs, spec.(*ast.ValueSpec))
 				}
 			}
-		case *ast.FuncDecl:
-			plugin.MakeFuncDecl(decl)
 		}
 	}
+	for _, i := range SortVarSpecs(var_specs) {
+		plugin.Globals = append(plugin.Globals, MakeVarSpec(var_specs[i], 0))
+	}
 
 	plugin_src_code.WriteString("\n")
 	for _, global := range plugin.Globals {
@@ -322,12 +1035,197 @@ func GeneratePluginFile(file *ast.File) string {
 		plugin_src_code.WriteString(fn.Storage + " " + fn.RetType + " " + fn.Name + "(")
 		plugin_src_code.WriteString(strings.Join(fn.Params, ", "))
 		plugin_src_code.WriteString(")")
-		plugin_src_code.WriteString(fn.Body.String())
+		plugin_src_code.WriteCode(&fn.Body)
 		if i+1 != len(plugin.Funcs) {
 			plugin_src_code.WriteString("\n\n")
 		}
 	}
-	return plugin_src_code.String()
+	return plugin_src_code.Generated()
+}
+
+func (struc *EStruct) Write(plugin_src_code *CodeBuilder, name string) {
+	single_tab := WriteTabStr(1)
+	plugin_src_code.WriteString(fmt.Sprintf("enum struct %s {", name))
+	for _, field := range struc.Fields {
+		plugin_src_code.WriteString("\n" + single_tab + field + ";")
+	}
+	if struc.Methods != nil {
+		plugin_src_code.WriteString("\n\n")
+		for i, method := range struc.Methods {
+			plugin_src_code.WriteString(single_tab + method.RetType + " " + method.Name + "(")
+			plugin_src_code.WriteString(strings.Join(method.Params, ", "))
+			plugin_src_code.WriteString(")")
+			plugin_src_code.WriteCode(&method.Body)
+			if i+1 != len(struc.Methods) {
+				plugin_src_code.WriteString("\n")
+			}
+		}
+	}
+	plugin_src_code.WriteString("\n}\n\n")
+}
+
+func (methodmap *MethodMap) Write(plugin_src_code *CodeBuilder) {
+	single_tab := WriteTabStr(1)
+	double_tab := WriteTabStr(2)
+	plugin_src_code.WriteString(fmt.Sprintf("methodmap %s < %s {", methodmap.Name, methodmap.Parent))
+	order := methodmap.MemberOrder()
+	for n, i := range order {
+		if i < len(methodmap.Methods) {
+			method := methodmap.Methods[i]
+			plugin_src_code.WriteString("\n" + single_tab + method.MethodMapHeader())
+			plugin_src_code.WriteCode(&method.Body)
+		} else {
+			prop := methodmap.Props[i-len(methodmap.Methods)]
+			plugin_src_code.WriteString("\n" + single_tab + "property " + prop.TypeName + " " + prop.Name + " {")
+			plugin_src_code.WriteString("\n" + double_tab + prop.Getter.MethodMapHeader())
+			plugin_src_code.WriteCode(&prop.Getter.Body)
+			plugin_src_code.WriteString("\n" + double_tab + prop.Setter.MethodMapHeader())
+			plugin_src_code.WriteCode(&prop.Setter.Body)
+			plugin_src_code.WriteString("\n" + single_tab + "}")
+		}
+		if n+1 != len(order) {
+			plugin_src_code.WriteString("\n")
+		}
+	}
+	plugin_src_code.WriteString("\n}\n\n")
+}
+
+// spcomp only knows the members of a methodmap that are declared before the one it's reading,
+// so methods and properties come after the members they use. the indexes of properties follow the methods.
+func (methodmap *MethodMap) MemberOrder() []int {
+	var names, bodies []string
+	for _, method := range methodmap.Methods {
+		names = append(names, method.Name)
+		bodies = append(bodies, method.Body.String())
+	}
+	for _, prop := range methodmap.Props {
+		names = append(names, prop.Name)
+		bodies = append(bodies, prop.Getter.Body.String()+prop.Setter.Body.String())
+	}
+	deps := make([][]int, len(names))
+	for i, body := range bodies {
+		for j, name := range names {
+			if i != j && name != methodmap.Name && UsesMember(body, name) {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return SortByDeps(deps)
+}
+
+// checks for '.name' in generated code that isn't the start of a longer name.
+func UsesMember(code, name string) bool {
+	for at := strings.Index(code, "."+name); at >= 0; {
+		end := at + 1 + len(name)
+		if end == len(code) || !(code[end] == '_' || unicode.IsLetter(rune(code[end])) || unicode.IsDigit(rune(code[end]))) {
+			return true
+		}
+		next := strings.Index(code[end:], "."+name)
+		if next < 0 {
+			break
+		}
+		at = end + next
+	}
+	return false
+}
+
+// orders declarations so each comes after the ones it uses, ties and cycles keep the order they're declared in.
+func SortByDeps(deps [][]int) []int {
+	order := make([]int, 0, len(deps))
+	/// 0 = not visited, 1 = visiting, 2 = placed.
+	state := make([]int, len(deps))
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			return
+		}
+		state[i] = 1
+		for _, dep := range deps[i] {
+			visit(dep)
+		}
+		state[i] = 2
+		order = append(order, i)
+	}
+	for i := range deps {
+		visit(i)
+	}
+	return order
+}
+
+// the package level declarations a node uses, 'decls' has the index of each declaration by name.
+func GetUsedDecls(n ast.Node, decls map[string]int) []int {
+	var used []int
+	if n == nil {
+		return used
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		if ident, is_ident := n.(*ast.Ident); is_ident {
+			obj := ASTMod.ASTCtxt.TypeInfo.Uses[ident]
+			if i, found := decls[ident.Name]; found && obj != nil && obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+				used = append(used, i)
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// enum structs need the types of their fields first, methodmaps need their parent first.
+func SortTypeSpecs(type_specs []*ast.TypeSpec) []int {
+	decls := make(map[string]int)
+	for i, type_spec := range type_specs {
+		decls[type_spec.Name.Name] = i
+	}
+	deps := make([][]int, len(type_specs))
+	for i, type_spec := range type_specs {
+		if parent, found := MethodMapParents[type_spec.Name.Name]; found {
+			if parent_index, local := decls[parent]; local {
+				deps[i] = append(deps[i], parent_index)
+			}
+			continue
+		}
+		switch t := type_spec.Type.(type) {
+		case *ast.StructType:
+			deps[i] = GetUsedDecls(t.Fields, decls)
+		case *ast.FuncType, *ast.InterfaceType:
+			deps[i] = GetUsedDecls(t, decls)
+		}
+	}
+	return SortByDeps(deps)
+}
+
+// Go constants can use constants declared after them.
+func SortConstDecls(const_decls []*ast.GenDecl) []int {
+	decls := make(map[string]int)
+	for i, decl := range const_decls {
+		for _, spec := range decl.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				decls[name.Name] = i
+			}
+		}
+	}
+	deps := make([][]int, len(const_decls))
+	for i, decl := range const_decls {
+		deps[i] = GetUsedDecls(decl, decls)
+	}
+	return SortByDeps(deps)
+}
+
+// Go globals can use globals declared after them.
+func SortVarSpecs(var_specs []*ast.ValueSpec) []int {
+	decls := make(map[string]int)
+	for i, spec := range var_specs {
+		for _, name := range spec.Names {
+			decls[name.Name] = i
+		}
+	}
+	deps := make([][]int, len(var_specs))
+	for i, spec := range var_specs {
+		for _, value := range spec.Values {
+			deps[i] = append(deps[i], GetUsedDecls(value, decls)...)
+		}
+	}
+	return SortByDeps(deps)
 }
 
 func MakeConstSpec(const_spec *ast.ValueSpec, tabs uint) string {
@@ -335,7 +1233,14 @@ func MakeConstSpec(const_spec *ast.ValueSpec, tabs uint) string {
 	var const_str strings.Builder
 	tabstrone := WriteTabStr(tabs + 1)
 	tabstr := WriteTabStr(tabs)
-	if const_spec.Type != nil {
+	if len(const_spec.Values) == 0 {
+		/// const ( A = 1; B ) repeats the value of the spec before it.
+		for _, name := range const_spec.Names {
+			if obj, is_const := ASTMod.ASTCtxt.TypeInfo.Defs[name].(*types.Const); is_const && name.Name != "_" {
+				const_str.WriteString(tabstr + GetTypeString(name, name.Name, false) + " = " + obj.Val().ExactString() + ";\n")
+			}
+		}
+	} else if const_spec.Type != nil {
 		for _, name := range const_spec.Names {
 			for _, value := range const_spec.Values {
 				switch val := value.(type) {
@@ -377,6 +1282,142 @@ func MakeConstSpec(const_spec *ast.ValueSpec, tabs uint) string {
 	return const_str.String()
 }
 
+// functions that SourceMod or other plugins call by name have to be public:
+// forwards, and functions whose address is taken like callbacks and native wrappers.
+func FindPublicFuncs(file *ast.File) map[string]bool {
+	funcs := make(map[string]bool)
+	for _, d := range file.Decls {
+		if f, is_func := d.(*ast.FuncDecl); is_func && f.Recv == nil {
+			funcs[f.Name.Name] = true
+		}
+	}
+	public_funcs := make(map[string]bool)
+	var find_refs func(n ast.Node) bool
+	find_refs = func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			/// calling a function directly doesn't need it to be public.
+			if iden, is_ident := x.Fun.(*ast.Ident); is_ident && funcs[iden.Name] {
+				for _, arg := range x.Args {
+					ast.Inspect(arg, find_refs)
+				}
+				return false
+			}
+		case *ast.Ident:
+			if funcs[x.Name] {
+				public_funcs[x.Name] = true
+			}
+		}
+		return true
+	}
+	for _, d := range file.Decls {
+		switch decl := d.(type) {
+		case *ast.FuncDecl:
+			/// the name of a declaration isn't a use of it.
+			if decl.Body != nil {
+				ast.Inspect(decl.Body, find_refs)
+			}
+		case *ast.GenDecl:
+			ast.Inspect(decl, find_refs)
+		}
+	}
+	return public_funcs
+}
+
+// public for known forwards and callbacks, static for unexported helpers and stock for exported ones.
+// unexported helpers are stock too when split, so the other includes can still call them.
+func GetFuncStorage(name string) string {
+	if PublicFuncs[name] || KnownForwards[name] {
+		return "public"
+	} else if (unicode.IsLower(rune(name[0])) || name[0] == '_') && !SplitPackage {
+		return "static"
+	}
+	return "stock"
+}
+
+// const groups that use 'iota' become enums.
+func IsIotaGroup(decl *ast.GenDecl) bool {
+	found := false
+	for _, spec := range decl.Specs {
+		for _, value := range spec.(*ast.ValueSpec).Values {
+			ast.Inspect(value, func(n ast.Node) bool {
+				if iden, is_ident := n.(*ast.Ident); is_ident && iden.Name == "iota" {
+					found = true
+				}
+				return !found
+			})
+		}
+	}
+	return found
+}
+
+// const ( A T = iota; B; C )       => enum T { A, B, C }
+// const ( A T = 1 << iota; B; C )  => enum T (<<= 1) { A = 1, B, C }
+// the enum is only tagged when every constant has the same local type.
+func MakeEnum(decl *ast.GenDecl) string {
+	var names []string
+	var values []int64
+	var tag types.Type
+	same_type := true
+	for _, spec := range decl.Specs {
+		for _, name := range spec.(*ast.ValueSpec).Names {
+			obj, is_const := ASTMod.ASTCtxt.TypeInfo.Defs[name].(*types.Const)
+			if !is_const || name.Name == "_" {
+				continue
+			}
+			value, exact := constant.Int64Val(constant.ToInt(obj.Val()))
+			if !exact {
+				ASTMod.PrintSrcGoErr(name.Pos(), fmt.Sprintf("Enum constant '%s' has to be an integer.", name.Name))
+				return ""
+			}
+			if tag == nil {
+				tag = obj.Type()
+			} else if !types.Identical(tag, obj.Type()) {
+				same_type = false
+			}
+			names = append(names, name.Name)
+			values = append(values, value)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	var enum strings.Builder
+	enum.WriteString("enum ")
+	if named, is_named := tag.(*types.Named); is_named && same_type && LocalTypes[named.Obj().Name()] != nil {
+		enum.WriteString(named.Obj().Name() + " ")
+	}
+	bit_flags := len(values) > 1
+	for i, value := range values {
+		if value <= 0 || value&(value-1) != 0 || (i > 0 && value != values[i-1]<<1) {
+			bit_flags = false
+		}
+	}
+	if bit_flags {
+		enum.WriteString("(<<= 1) ")
+	}
+	enum.WriteString("{")
+	/// values that follow from the one before don't need to be written.
+	next := int64(0)
+	for i, name := range names {
+		enum.WriteString("\n" + WriteTabStr(1) + name)
+		if values[i] != next || (bit_flags && i == 0) {
+			enum.WriteString(fmt.Sprintf(" = %d", values[i]))
+		}
+		if bit_flags {
+			next = values[i] << 1
+		} else {
+			next = values[i] + 1
+		}
+		if i+1 != len(names) {
+			enum.WriteString(",")
+		}
+	}
+	enum.WriteString("\n};")
+	return enum.String()
+}
+
 func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 	/// if a constant is untyped, it can have different names and associating values.
 	tabstrone := WriteTabStr(tabs + 1)
@@ -384,10 +1425,28 @@ func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 	var var_str strings.Builder
 	if var_spec.Type != nil {
 		for i, name := range var_spec.Names {
-			var_str.WriteString(tabstr + GetTypeString(var_spec.Type, name.Name, false))
+			if len(var_spec.Values) == 0 && IsStringType(var_spec.Type) && !IsFixedArray(var_spec.Type) {
+				/// strings need a buffer size, char arrays already have one.
+				size := fmt.Sprintf("%d", ASTMod.StrBufferLen)
+				if size_expr, found := ASTMod.ASTCtxt.StrBufferSizes[name]; found {
+					if _, is_lit := size_expr.(*ast.BasicLit); !is_lit {
+						/// fixed arrays need a constant size.
+						var_str.WriteString(tabstr + fmt.Sprintf("char[] %s = new char[%s];\n", name.Name, GetExprString(size_expr)))
+						continue
+					}
+					size = GetExprString(size_expr)
+				}
+				var_str.WriteString(tabstr + fmt.Sprintf("char %s[%s];\n", name.Name, size))
+				continue
+			}
+			var_str.WriteString(tabstr + GetVarTypeString(name, var_spec.Type, false))
 			if var_spec.Values != nil && i < len(var_spec.Values) {
 				switch val := var_spec.Values[i].(type) {
 				case *ast.CompositeLit:
+					if ASTMod.GetStructType(val) != nil {
+						var_str.WriteString(" = " + MakeStructInit(val, ASTMod.GetStructType(val)))
+						break
+					}
 					var_str.WriteString(" = {")
 					for n, expr := range val.Elts {
 						var_str.WriteString(tabstrone + GetExprString(expr))
@@ -406,12 +1465,17 @@ func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 	} else {
 		for _, name := range var_spec.Names {
 			for _, value := range var_spec.Values {
-				var_str.WriteString(tabstr + GetTypeString(value, name.Name, false))
+				var_str.WriteString(tabstr + GetVarTypeString(name, value, false))
 				switch val := value.(type) {
 				case *ast.CompositeLit:
+					if ASTMod.GetStructType(val) != nil {
+						var_str.WriteString(" = " + MakeStructInit(val, ASTMod.GetStructType(val)))
+						break
+					}
 					var_str.WriteString(" = {\n")
 					for n, expr := range val.Elts {
-						var_str.WriteString(tabstrone + GetExprString(expr))
+						/// the struct literals of a global array are initializer lists too.
+						var_str.WriteString(tabstrone + GetInitValue(expr))
 						if n+1 != len(val.Elts) {
 							var_str.WriteString(",")
 						}
@@ -425,42 +1489,207 @@ func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 			var_str.WriteString(";\n")
 		}
 	}
-	return var_str.String()
+	return var_str.String()
+}
+
+// Player{Health: 10} => { 10, { 0.0, 0.0 }, "", false, { 0, ... } }
+// global enum structs keep their initializer list, so fields are written in declaration order with zeros for the ones left out.
+func MakeStructInit(lit *ast.CompositeLit, struct_type *types.Struct) string {
+	return "{ " + strings.Join(GetStructInitValues(lit, struct_type), ", ") + " }"
+}
+
+func GetStructInitValues(lit *ast.CompositeLit, struct_type *types.Struct) []string {
+	var inits []string
+	for i, value := range ASTMod.GetStructLitValues(lit, struct_type) {
+		field := struct_type.Field(i)
+		if field.Embedded() {
+			/// embedded structs are flattened, so are their values.
+			embedded := field.Type().Underlying().(*types.Struct)
+			nested, is_lit := value.(*ast.CompositeLit)
+			if value != nil && !is_lit {
+				ASTMod.PrintSrcGoErr(value.Pos(), "Embedded structs of a global struct literal have to be struct literals.")
+			}
+			inits = append(inits, GetStructInitValues(nested, embedded)...)
+		} else if value == nil {
+			inits = append(inits, GetZeroValue(field.Type()))
+		} else {
+			inits = append(inits, GetInitValue(value))
+		}
+	}
+	return inits
+}
+
+func GetInitValue(value ast.Expr) string {
+	lit, is_lit := value.(*ast.CompositeLit)
+	if !is_lit {
+		return GetExprString(value)
+	}
+	typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(lit)
+	if struct_type := ASTMod.GetStructType(lit); struct_type != nil {
+		return MakeStructInit(lit, struct_type)
+	} else if array_type, is_array := typ.Underlying().(*types.Array); is_array {
+		elems := make([]string, array_type.Len())
+		index := int64(0)
+		for _, elt := range lit.Elts {
+			if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+				if key := ASTMod.ASTCtxt.TypeInfo.Types[kv.Key].Value; key != nil {
+					index, _ = constant.Int64Val(key)
+				}
+				elt = kv.Value
+			}
+			elems[index] = GetInitValue(elt)
+			index++
+		}
+		for i := range elems {
+			if elems[i] == "" {
+				elems[i] = GetZeroValue(array_type.Elem())
+			}
+		}
+		return "{ " + strings.Join(elems, ", ") + " }"
+	}
+	return GetExprString(value)
+}
+
+// the initializer for a field a global struct literal leaves out.
+func GetZeroValue(typ types.Type) string {
+	if IsMethodMapType(typ) {
+		return "null"
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean > 0:
+			return "false"
+		case t.Info()&types.IsFloat > 0:
+			return "0.0"
+		case t.Info()&types.IsString > 0:
+			return `""`
+		}
+		if named, is_named := typ.(*types.Named); is_named && !ASTMod.IsCharType(typ) {
+			return "view_as<" + named.Obj().Name() + ">(0)"
+		}
+		return "0"
+	case *types.Struct:
+		return MakeStructInit(nil, t)
+	case *types.Array:
+		if ASTMod.IsCharType(t.Elem()) {
+			return `""`
+		}
+		return "{ " + GetZeroValue(t.Elem()) + ", ... }"
+	case *types.Signature:
+		return "INVALID_FUNCTION"
+	}
+	return "null"
+}
+
+func (plugin *SMPlugin) MakeTypeSpec(type_spec *ast.TypeSpec) {
+	if parent, found := MethodMapParents[type_spec.Name.Name]; found {
+		plugin.MethodMaps = append(plugin.MethodMaps, MethodMap{Name: type_spec.Name.Name, Parent: parent})
+		return
+	}
+	switch t := type_spec.Type.(type) {
+	case *ast.StructType:
+		plugin.Structs[type_spec.Name.Name] = EStruct{
+			Fields: WriteStructMembs(t.Fields),
+		}
+
+	case *ast.FuncType:
+		/// typedef Whatever = function type (params);
+		plugin.TypeDefs[type_spec.Name.Name] = "typedef " + type_spec.Name.Name + " = function " + MakeFuncSignature(t) + ";"
+
+	case *ast.InterfaceType:
+		/**
+		 * type SDKHookCB interface {
+		 *     Think(client int)
+		 *     func(entity, other int) Action | func(entity, other int)
+		 * }
+		 *
+		 * Becomes:
+		 * typeset SDKHookCB {
+		 *     function void (int client);
+		 *     function Action (int entity, int other);
+		 *     function void (int entity, int other);
+		 * }
+		 */
+		var signatures []string
+		for _, field := range t.Methods.List {
+			signatures = append(signatures, GetTypeSetSignatures(field.Type)...)
+		}
+		if len(signatures) == 0 {
+			/// 'interface{}' is any type.
+			break
+		}
+		var typeset strings.Builder
+		typeset.WriteString("typeset " + type_spec.Name.Name + " {")
+		for _, signature := range signatures {
+			typeset.WriteString("\n" + WriteTabStr(1) + "function " + signature + ";")
+		}
+		typeset.WriteString("\n}")
+		plugin.TypeDefs[type_spec.Name.Name] = typeset.String()
+	}
 }
 
-func (plugin *SMPlugin) MakeTypeSpec(type_spec *ast.TypeSpec) {
-	switch t := type_spec.Type.(type) {
-	case *ast.StructType:
-		plugin.Structs[type_spec.Name.Name] = EStruct{
-			Fields: WriteStructMembs(t.Fields),
+// 'type (params)' of a function type.
+func MakeFuncSignature(t *ast.FuncType) string {
+	var signature strings.Builder
+	if t.Results != nil {
+		type_str := GetTypeString(t.Results.List[0].Type, "", false)
+		type_str = strings.TrimSpace(type_str)
+		if strings.Count(type_str, "[") > 0 {
+			/// shoot error but continue.
+			ASTMod.PrintSrcGoErr(t.Pos(), "Typedef'd functions are not allowed to return arrays.")
 		}
+		signature.WriteString(type_str)
+	} else {
+		signature.WriteString("void")
+	}
+	signature.WriteString(" (")
+	signature.WriteString(strings.Join(WriteParams(t.Params), ", "))
+	signature.WriteString(")")
+	return signature.String()
+}
 
+// the signatures of an interface method or element, func types can be joined with '|'.
+func GetTypeSetSignatures(e ast.Expr) []string {
+	switch t := e.(type) {
 	case *ast.FuncType:
-		var func_type strings.Builder
-		/// typedef Whatever = function type (params);
-		func_type.WriteString("typedef " + type_spec.Name.Name + " = function ")
-		if t.Results != nil {
-			type_str := GetTypeString(t.Results.List[0].Type, "", false)
-			type_str = strings.TrimSpace(type_str)
-			if strings.Count(type_str, "[") > 0 {
-				/// shoot error but continue.
-				ASTMod.PrintSrcGoErr(t.Pos(), "Typedef'd functions are not allowed to return arrays.")
-			}
-			func_type.WriteString(type_str)
-		} else {
-			func_type.WriteString("void")
+		return []string{MakeFuncSignature(t)}
+	case *ast.BinaryExpr:
+		if t.Op == token.OR {
+			return append(GetTypeSetSignatures(t.X), GetTypeSetSignatures(t.Y)...)
+		}
+	case *ast.UnaryExpr:
+		if t.Op == token.TILDE {
+			return GetTypeSetSignatures(t.X)
+		}
+	case *ast.ParenExpr:
+		return GetTypeSetSignatures(t.X)
+	case *ast.Ident:
+		if type_spec, local := LocalTypes[t.Name]; local {
+			switch typ := type_spec.Type.(type) {
+			case *ast.FuncType:
+				return GetTypeSetSignatures(typ)
+			case *ast.InterfaceType:
+				var signatures []string
+				for _, field := range typ.Methods.List {
+					signatures = append(signatures, GetTypeSetSignatures(field.Type)...)
+				}
+				return signatures
+			}
 		}
-		func_type.WriteString(" (")
-		func_type.WriteString(strings.Join(WriteParams(t.Params), ", "))
-		func_type.WriteString(");")
-		plugin.Globals = append(plugin.Globals, func_type.String()+"\n")
 	}
+	ASTMod.PrintSrcGoErr(e.Pos(), fmt.Sprintf("Typesets can only have function signatures, '%s' isn't one.", types.ExprString(e)))
+	return nil
 }
 
 func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
 	fn := FuncBlock{}
 	if f.Type.Results != nil {
-		fn.RetType = GetTypeString(f.Type.Results.List[0].Type, "", false)
+		if ret_type := f.Type.Results.List[0].Type; ASTMod.GetSliceElem(ret_type) != nil {
+			fn.RetType = "ArrayList"
+		} else {
+			fn.RetType = GetTypeString(ret_type, "", false)
+		}
 	} else {
 		fn.RetType = "void"
 	}
@@ -472,23 +1701,57 @@ func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
 	fn.Name = f.Name.Name
 	fn.Params = WriteParams(f.Type.Params)
 
+	ctor_of := MethodMapCtors[f.Name.Name]
+	if ctor_of != "" {
+		fn.Name, fn.RetType = ctor_of, ""
+	}
+
+	var prop_of string
 	if f.Recv != nil {
+		prop_of = MethodMapProps[GetRecvTypeName(f.Recv)+"."+f.Name.Name]
+	}
+
+	if prop_of != "" {
+		fn.Tabs = 2
+	} else if f.Recv != nil || ctor_of != "" {
 		fn.Tabs = 1
 	} else {
 		fn.Tabs = 0
 	}
 
+	fn.Body.MarkPos(f.Pos())
 	if f.Body != nil {
 		fn.Storage = "public"
+		if f.Recv == nil && ctor_of == "" {
+			fn.Storage = GetFuncStorage(fn.Name)
+		}
+		PanicFunc = GetPanicFunc(f)
 		fn.MakeStmts(f.Body.List, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
 	} else {
 		fn.Storage = "native"
 		fn.Body.WriteString(";")
 	}
 
-	if f.Recv != nil {
+	if ctor_of != "" {
+		methodmap := plugin.FindMethodMap(ctor_of)
+		methodmap.Methods = append(methodmap.Methods, fn)
+	} else if f.Recv != nil {
 		struct_type := GetTypeString(f.Recv.List[0].Type, "", false)
-		if struc, ok := plugin.Structs[struct_type]; ok {
+		if methodmap := plugin.FindMethodMap(GetRecvTypeName(f.Recv)); methodmap != nil {
+			if prop_of != "" {
+				prop := methodmap.FindProp(prop_of)
+				if f.Type.Results != nil {
+					prop.TypeName = fn.RetType
+					fn.Name, fn.RetType = "get", ""
+					prop.Getter = fn
+				} else {
+					fn.Name, fn.RetType = "set", ""
+					prop.Setter = fn
+				}
+			} else {
+				methodmap.Methods = append(methodmap.Methods, fn)
+			}
+		} else if struc, ok := plugin.Structs[struct_type]; ok {
 			struc.Methods = append(struc.Methods, fn)
 			plugin.Structs[struct_type] = struc
 		}
@@ -497,6 +1760,19 @@ func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
 	}
 }
 
+// panics fail the plugin while it's starting, natives throw to their caller and everything else throws a plain error.
+func GetPanicFunc(f *ast.FuncDecl) string {
+	if f.Recv == nil && f.Name.Name == "OnPluginStart" {
+		return "SetFailState"
+	}
+	for _, native := range ASTMod.ASTCtxt.Natives {
+		if native == f {
+			return "ThrowNativeError"
+		}
+	}
+	return "ThrowError"
+}
+
 func (cb *FuncBlock) MakeStmts(stmts []ast.Stmt, flags int) {
 	tabstr := WriteTabStr(cb.Tabs)
 	cb.Body.WriteString("\n" + tabstr + "{")
@@ -508,10 +1784,21 @@ func (cb *FuncBlock) MakeStmts(stmts []ast.Stmt, flags int) {
 	cb.Body.WriteString("\n" + tabstr + "}")
 }
 
+// SourcePawn has no init statements, so the init and its statement are put in their own block.
+func (cb *FuncBlock) MakeInitBlock(init, stmt ast.Stmt, tabstr string) {
+	cb.Body.WriteString(tabstr + "{")
+	cb.Tabs++
+	cb.MakeStmt(init, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+	cb.MakeStmt(stmt, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+	cb.Tabs--
+	cb.Body.WriteString("\n" + tabstr + "}")
+}
+
 func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 	if flags&GENFLAG_NEWLINE > 0 {
 		cb.Body.WriteString("\n")
 	}
+	cb.Body.MarkPos(stmt.Pos())
 	tabstr := WriteTabStr(cb.Tabs)
 	switch n := stmt.(type) {
 	case *ast.BlockStmt:
@@ -521,7 +1808,7 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 		left_len, rite_len := len(n.Lhs), len(n.Rhs)
 		if n.Tok == token.DEFINE {
 			if fn, is_func := n.Rhs[0].(*ast.CallExpr); is_func {
-				if iden, is_ident := fn.Fun.(*ast.Ident); is_ident && iden.Name == "make" {
+				if iden, is_ident := fn.Fun.(*ast.Ident); is_ident && iden.Name == "make" && !IsMakeMap(fn) && !ASTMod.ASTCtxt.ArrayListMakes[fn] {
 					typ_str := GetTypeString(fn.Args[0], "", false)
 					cb.Body.WriteString(tabstr + typ_str + " " + GetExprString(n.Lhs[0]) + " = new " + typ_str[:len(typ_str)-1] + fmt.Sprintf("%s]", GetExprString(fn.Args[1])))
 					/// + fmt.Sprintf("%s[%s]", GetExprString(fn.Args[1]))
@@ -547,7 +1834,7 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 					}
 					cb.Body.WriteString(tabstr + "}")
 				default:
-					cb.Body.WriteString(tabstr + GetTypeString(n.Lhs[i], var_name.Name, false) + " = " + GetExprString(n.Rhs[i]))
+					cb.Body.WriteString(tabstr + GetVarTypeString(var_name, n.Lhs[i], false) + " = " + GetExprString(n.Rhs[i]))
 				}
 				if flags&GENFLAG_SEMICOLON > 0 {
 					cb.Body.WriteString(";")
@@ -558,6 +1845,11 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 					}
 				}
 			}
+		} else if str_op := MakeStrAssign(n, tabstr); str_op != "" {
+			cb.Body.WriteString(tabstr + str_op)
+			if flags&GENFLAG_SEMICOLON > 0 {
+				cb.Body.WriteString(";")
+			}
 		} else {
 			if left_len == rite_len {
 				for i := range n.Lhs {
@@ -603,7 +1895,12 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 				}
 			}
 		}
-		cb.Body.WriteString(tabstr + GetExprString(n.X))
+		if fn_call, is_call := n.X.(*ast.CallExpr); is_call && len(fn_call.Args) == 1 && GetExprString(fn_call.Fun) == "delete" {
+			/// delete(handle) => delete handle;
+			cb.Body.WriteString(tabstr + "delete " + GetExprString(fn_call.Args[0]))
+		} else {
+			cb.Body.WriteString(tabstr + GetExprString(n.X))
+		}
 		if flags&GENFLAG_SEMICOLON > 0 {
 			cb.Body.WriteString(";")
 		}
@@ -657,9 +1954,15 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 			cb.Tabs = old
 		}
 		cb.Body.WriteString(")")
-		cb.MakeStmts(n.Body.List, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+		cb.MakeLoopBody(n.Body.List)
 
 	case *ast.IfStmt:
+		if n.Init != nil {
+			no_init := *n
+			no_init.Init = nil
+			cb.MakeInitBlock(n.Init, &no_init, tabstr)
+			return
+		}
 		if_stmt := n
 		cb.Body.WriteString(tabstr)
 	re_if:
@@ -669,6 +1972,10 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 			cb.Body.WriteString("\n" + tabstr + "else ")
 			switch s := if_stmt.Else.(type) {
 			case *ast.IfStmt:
+				if s.Init != nil {
+					cb.MakeStmts([]ast.Stmt{s}, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+					break
+				}
 				if_stmt = s
 				goto re_if
 			default:
@@ -678,6 +1985,11 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 		}
 
 	case *ast.BranchStmt:
+		/// continues and generated breaks go to a loop, not to the string switch they're in.
+		if cb.StrSwitch != nil && (n.Tok == token.CONTINUE || !n.Pos().IsValid()) {
+			cb.WriteBranchOut(n.Tok, tabstr)
+			return
+		}
 		cb.Body.WriteString(tabstr + n.Tok.String())
 		if flags&GENFLAG_SEMICOLON > 0 {
 			cb.Body.WriteString(";")
@@ -687,17 +1999,29 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 	/// for (int a; a < sizeof(array); a++) { type b = array[a]; }
 	case *ast.RangeStmt:
 		key_str := GetExprString(n.Key)
-		cb.Body.WriteString(tabstr + fmt.Sprintf("for (int %s; %s < sizeof(%s); %s++)", key_str, key_str, GetExprString(n.X), key_str))
-		cb.MakeStmts(n.Body.List, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+		if ASTMod.IsArrayList(n.X) {
+			cb.Body.WriteString(tabstr + fmt.Sprintf("for (int %s; %s < %s.Length; %s++)", key_str, key_str, GetExprString(n.X), key_str))
+		} else {
+			cb.Body.WriteString(tabstr + fmt.Sprintf("for (int %s; %s < sizeof(%s); %s++)", key_str, key_str, GetExprString(n.X), key_str))
+		}
+		cb.MakeLoopBody(n.Body.List)
 
 	case *ast.SwitchStmt:
+		if n.Init != nil {
+			no_init := *n
+			no_init.Init = nil
+			cb.MakeInitBlock(n.Init, &no_init, tabstr)
+			return
+		}
 		/// if no tag expression, make it an if-else-if series.
-		if n.Tag != nil {
+		if n.Tag != nil && IsStringType(n.Tag) {
+			cb.MakeStrSwitch(n)
+		} else if n.Tag != nil {
 			cb.Body.WriteString(tabstr + "switch (" + GetExprString(n.Tag) + ")")
-			cb.MakeStmts(n.Body.List, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+			cb.MakeStmts(ExpandFallthroughs(n.Body.List), GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
 		} else {
 			var case_list []*ast.CaseClause
-			for _, stmt := range n.Body.List {
+			for _, stmt := range ExpandFallthroughs(n.Body.List) {
 				case_list = append(case_list, stmt.(*ast.CaseClause))
 			}
 			cases := len(case_list)
@@ -757,9 +2081,558 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 	}
 }
 
+// SourcePawn cases can't fall through, so a case ending in 'fallthrough' gets the next case's body added to it.
+func ExpandFallthroughs(clauses []ast.Stmt) []ast.Stmt {
+	expanded := make([]ast.Stmt, len(clauses))
+	for i := len(clauses) - 1; i >= 0; i-- {
+		clause := clauses[i].(*ast.CaseClause)
+		body := clause.Body
+		if last := len(body) - 1; last >= 0 {
+			if branch, is_branch := body[last].(*ast.BranchStmt); is_branch && branch.Tok == token.FALLTHROUGH && i+1 < len(clauses) {
+				body = append(append([]ast.Stmt{}, body[:last]...), expanded[i+1].(*ast.CaseClause).Body...)
+			}
+		}
+		expanded[i] = &ast.CaseClause{Case: clause.Case, List: clause.List, Colon: clause.Colon, Body: body}
+	}
+	return expanded
+}
+
+// checks for a '//srcgo:name' directive on the lines before a statement.
+func HasStmtDirective(stmt ast.Stmt, name string) bool {
+	for _, comment_group := range Comments[stmt] {
+		if ASTMod.HasDirective(comment_group, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * SourcePawn can't switch on strings so they become an if-else-if series.
+ * A '//srcgo:nocase' directive before the switch compares them case-insensitively.
+ * The tag is only read, a tag with calls in it is put into a buffer before the switch by 'MutateStrings'.
+ *
+ * switch cmd {
+ * case "a", "b":
+ * default:
+ * }
+ *
+ * Becomes:
+ * if (StrEqual(cmd, "a") || StrEqual(cmd, "b")) {}
+ * else {}
+ *
+ * A 'break' leaves the series through a do-while(false) around it,
+ * then a 'continue' or a break to an outer loop sets a flag that's checked after the do-while:
+ *
+ * do {
+ *     if (StrEqual(cmd, "a")) { str_switch0_continue = true; break; }
+ * } while (false);
+ * if (str_switch0_continue) continue;
+ */
+func (cb *FuncBlock) MakeStrSwitch(n *ast.SwitchStmt) {
+	tabstr := WriteTabStr(cb.Tabs)
+	tag := GetExprString(n.Tag)
+	case_sensitive := ""
+	if HasStmtDirective(n, "nocase") {
+		case_sensitive = ", false"
+	}
+
+	var default_case *ast.CaseClause
+	var cases []*ast.CaseClause
+	for _, stmt := range ExpandFallthroughs(n.Body.List) {
+		if case_ := stmt.(*ast.CaseClause); case_.List == nil {
+			default_case = case_
+		} else {
+			cases = append(cases, case_)
+		}
+	}
+
+	outer := cb.StrSwitch
+	var wrap *StrSwitchFlags
+	if HasSwitchBreak(n.Body) {
+		wrap = new(StrSwitchFlags)
+		has_break, has_continue := HasBranchOut(n.Body)
+		if has_break {
+			wrap.Break = fmt.Sprintf("str_switch%d_break", cb.StrSwitches)
+			cb.Body.WriteString(tabstr + "bool " + wrap.Break + ";\n")
+		}
+		if has_continue {
+			wrap.Continue = fmt.Sprintf("str_switch%d_continue", cb.StrSwitches)
+			cb.Body.WriteString(tabstr + "bool " + wrap.Continue + ";\n")
+		}
+		cb.StrSwitches++
+		cb.StrSwitch = wrap
+		cb.Body.WriteString(tabstr + "do\n" + tabstr + "{\n")
+		cb.Tabs++
+		tabstr = WriteTabStr(cb.Tabs)
+	}
+
+	for i, case_ := range cases {
+		if i == 0 {
+			cb.Body.WriteString(tabstr + "if (")
+		} else {
+			cb.Body.WriteString("\n" + tabstr + "else if (")
+		}
+		for n, expr := range case_.List {
+			cb.Body.WriteString("StrEqual(" + tag + ", " + GetExprString(expr) + case_sensitive + ")")
+			if n+1 != len(case_.List) {
+				cb.Body.WriteString(" || ")
+			}
+		}
+		cb.Body.WriteString(")")
+		cb.MakeStmts(case_.Body, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+	}
+
+	if default_case != nil {
+		/// only a default case always runs.
+		if cases != nil {
+			cb.Body.WriteString("\n" + tabstr + "else")
+		}
+		cb.MakeStmts(default_case.Body, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+	}
+
+	if wrap != nil {
+		cb.Tabs--
+		tabstr = WriteTabStr(cb.Tabs)
+		cb.Body.WriteString("\n" + tabstr + "} while (false);")
+		cb.StrSwitch = outer
+		if wrap.Break != "" {
+			cb.Body.WriteString("\n" + tabstr + "if (" + wrap.Break + ")\n")
+			cb.WriteBranchOut(token.BREAK, tabstr+"\t")
+		}
+		if wrap.Continue != "" {
+			cb.Body.WriteString("\n" + tabstr + "if (" + wrap.Continue + ")\n")
+			cb.WriteBranchOut(token.CONTINUE, tabstr+"\t")
+		}
+	}
+}
+
+// a break or continue to a loop, set through the flags of the string switch it has to leave first.
+func (cb *FuncBlock) WriteBranchOut(tok token.Token, tabstr string) {
+	if cb.StrSwitch != nil {
+		flag := cb.StrSwitch.Break
+		if tok == token.CONTINUE {
+			flag = cb.StrSwitch.Continue
+		}
+		cb.Body.WriteString(tabstr + flag + " = true;\n")
+		tok = token.BREAK
+	}
+	cb.Body.WriteString(tabstr + tok.String() + ";")
+}
+
+// branches in a loop body target that loop, not the string switch around it.
+func (cb *FuncBlock) MakeLoopBody(stmts []ast.Stmt) {
+	outer := cb.StrSwitch
+	cb.StrSwitch = nil
+	cb.MakeStmts(stmts, GENFLAG_NEWLINE|GENFLAG_SEMICOLON)
+	cb.StrSwitch = outer
+}
+
+// checks for a 'break' that leaves the switch itself.
+func HasSwitchBreak(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt, *ast.FuncLit:
+			return false
+		case *ast.BranchStmt:
+			/// generated breaks are leaving a loop for a labeled break or continue.
+			if x.Tok == token.BREAK && x.Label == nil && x.Pos().IsValid() {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// checks for breaks and continues that go to the loops around a switch, nested switches pass them on.
+func HasBranchOut(body *ast.BlockStmt) (has_break, has_continue bool) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.FuncLit:
+			return false
+		case *ast.BranchStmt:
+			if x.Tok == token.CONTINUE {
+				has_continue = true
+			} else if x.Tok == token.BREAK && !x.Pos().IsValid() {
+				has_break = true
+			}
+		}
+		return true
+	})
+	return
+}
+
+// make(map[string]T) => new StringMap()
+func IsMakeMap(call *ast.CallExpr) bool {
+	if iden, is_ident := call.Fun.(*ast.Ident); is_ident && iden.Name == "make" && len(call.Args) > 0 {
+		return ASTMod.GetMapType(call.Args[0]) != nil
+	}
+	return false
+}
+
+// strings and char arrays.
+func IsStringType(e ast.Expr) bool {
+	typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(e)
+	return typ != nil && ASTMod.IsStrType(typ)
+}
+
+func IsFixedArray(e ast.Expr) bool {
+	typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(e)
+	if typ == nil {
+		return false
+	}
+	_, is_array := typ.Underlying().(*types.Array)
+	return is_array
+}
+
+// an unsized error param has no 'sizeof', its size is the maxlen param after it.
+func GetStrSize(dest string) string {
+	if maxlen, found := ASTMod.ASTCtxt.ErrorLens[dest]; found {
+		return maxlen
+	}
+	return "sizeof(" + dest + ")"
+}
+
+// s = a + b             => Format(s, sizeof(s), "%s%s", a, b)
+// s += a                => StrCat(s, sizeof(s), a)
+// s = Sprintf("%d", n)  => FormatEx(s, sizeof(s), "%d", n)
+func MakeStrAssign(n *ast.AssignStmt, tabstr string) string {
+	if len(n.Lhs) != 1 || len(n.Rhs) != 1 || !IsStringType(n.Lhs[0]) {
+		return ""
+	}
+	dest := GetExprString(n.Lhs[0])
+	if ident, is_ident := n.Rhs[0].(*ast.Ident); is_ident && ident.Name == "nil" {
+		/// a nil error is an empty string.
+		return dest + "[0] = '\\0'"
+	} else if call, is_call := n.Rhs[0].(*ast.CallExpr); is_call && n.Tok == token.ASSIGN && (ASTMod.GetFmtFunc(call) == "Sprintf" || ASTMod.GetFmtFunc(call) == "Errorf") {
+		format, args := TranslateFormat(call.Args[0], call.Args[1:])
+		fn := "FormatEx"
+		for _, arg := range call.Args[1:] {
+			/// FormatEx can't write into a buffer that it's also reading from.
+			if GetExprString(arg) == dest {
+				fn = "Format"
+				break
+			}
+		}
+		return fn + "(" + strings.Join(append([]string{dest, GetStrSize(dest), format}, args...), ", ") + ")"
+	}
+	parts := ASTMod.GetStrConcatParts(n.Rhs[0])
+	switch n.Tok {
+	case token.ADD_ASSIGN:
+		strcats := make([]string, 0)
+		for _, part := range parts {
+			strcats = append(strcats, fmt.Sprintf("StrCat(%s, %s, %s)", dest, GetStrSize(dest), GetExprString(part)))
+		}
+		return strings.Join(strcats, ";\n"+tabstr)
+	case token.ASSIGN:
+		if call, is_call := n.Rhs[0].(*ast.CallExpr); is_call && GetExprString(call.Fun) == "make" {
+			return ""
+		} else if len(parts) == 1 {
+			/// char arrays can't be assigned to.
+			return fmt.Sprintf("strcopy(%s, %s, %s)", dest, GetStrSize(dest), GetExprString(n.Rhs[0]))
+		}
+		var format strings.Builder
+		args := make([]string, 0)
+		for _, part := range parts {
+			if tv := ASTMod.ASTCtxt.TypeInfo.Types[part]; tv.Value != nil && tv.Value.Kind() == constant.String {
+				format.WriteString(strings.Replace(constant.StringVal(tv.Value), "%", "%%", -1))
+			} else {
+				format.WriteString("%s")
+				args = append(args, GetExprString(part))
+			}
+		}
+		return fmt.Sprintf("Format(%s, %s, %s, %s)", dest, GetStrSize(dest), QuoteSPString(format.String()), strings.Join(args, ", "))
+	}
+	return ""
+}
+
+// err != nil => err[0] != '\0'
+func MakeNilStrCompare(x *ast.BinaryExpr) string {
+	if x.Op != token.EQL && x.Op != token.NEQ {
+		return ""
+	}
+	str := x.X
+	if IsBuiltin(x.X, "nil") {
+		str = x.Y
+	} else if !IsBuiltin(x.Y, "nil") {
+		return ""
+	}
+	if !IsStringType(str) {
+		return ""
+	}
+	return GetExprString(str) + "[0] " + x.Op.String() + " '\\0'"
+}
+
+// checks if an expression is one of Go's builtins, which can be shadowed.
+func IsBuiltin(e ast.Expr, name string) bool {
+	ident, is_ident := e.(*ast.Ident)
+	if !is_ident || ident.Name != name {
+		return false
+	}
+	obj := ASTMod.ASTCtxt.TypeInfo.ObjectOf(ident)
+	return obj == nil || obj.Parent() == types.Universe
+}
+
+// err.Error() => err
+func IsErrorMethod(call *ast.CallExpr) bool {
+	sel, is_sel := call.Fun.(*ast.SelectorExpr)
+	return is_sel && sel.Sel.Name == "Error" && len(call.Args) == 0 && ASTMod.IsErrorType(ASTMod.ASTCtxt.TypeInfo.TypeOf(sel.X))
+}
+
+// panic("bad")                => ThrowError("bad")
+// panic(Sprintf("%d", n))     => ThrowError("%d", n)
+// panic(err)                  => ThrowError("%s", err)
+// panic in main               => SetFailState(...)
+// panic in a native           => ThrowNativeError(SP_ERROR_NATIVE, ...)
+func MakePanicCall(call *ast.CallExpr) string {
+	if len(call.Args) != 1 {
+		return ""
+	}
+	arg := call.Args[0]
+	for {
+		paren, is_paren := arg.(*ast.ParenExpr)
+		if !is_paren {
+			break
+		}
+		arg = paren.X
+	}
+	var format string
+	var args []string
+	if fmt_call, is_call := arg.(*ast.CallExpr); is_call && (ASTMod.GetFmtFunc(fmt_call) == "Sprintf" || ASTMod.GetFmtFunc(fmt_call) == "Errorf") {
+		format, args = TranslateFormat(fmt_call.Args[0], fmt_call.Args[1:])
+	} else {
+		/// the message isn't a format, even when it's constant.
+		format, args = TranslateFormatString("%v", arg.Pos(), []ast.Expr{arg})
+	}
+	args = append([]string{format}, args...)
+	if PanicFunc == "ThrowNativeError" {
+		args = append([]string{"SP_ERROR_NATIVE"}, args...)
+	}
+	return PanicFunc + "(" + strings.Join(args, ", ") + ")"
+}
+
+// escapes a string into a SourcePawn string literal.
+func QuoteSPString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// Printf("%v", n)     => PrintToServer("%d", n)
+// Println(a, b)       => PrintToServer("%s %d", a, b)
+// Errorf("%s", s)     => LogError("%s", s), unless it's assigned to an error.
+func MakeFmtCall(call *ast.CallExpr) string {
+	var format string
+	var args []string
+	switch ASTMod.GetFmtFunc(call) {
+	case "Printf":
+		format, args = TranslateFormat(call.Args[0], call.Args[1:])
+		return "PrintToServer(" + strings.Join(append([]string{format}, args...), ", ") + ")"
+	case "Println":
+		verbs := make([]string, len(call.Args))
+		for i := range verbs {
+			verbs[i] = "%v"
+		}
+		format, args = TranslateFormatString(strings.Join(verbs, " "), call.Pos(), call.Args)
+		return "PrintToServer(" + strings.Join(append([]string{format}, args...), ", ") + ")"
+	case "Errorf":
+		format, args = TranslateFormat(call.Args[0], call.Args[1:])
+		return "LogError(" + strings.Join(append([]string{format}, args...), ", ") + ")"
+	case "Sprintf":
+		ASTMod.PrintSrcGoErr(call.Pos(), "Sprintf has to be assigned to a string variable.")
+	}
+	return ""
+}
+
+// the format string has to be constant so its verbs can be translated.
+func TranslateFormat(format ast.Expr, args []ast.Expr) (string, []string) {
+	tv := ASTMod.ASTCtxt.TypeInfo.Types[format]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		ASTMod.PrintSrcGoErr(format.Pos(), "Format strings have to be constant.")
+		return GetExprString(format), nil
+	}
+	return TranslateFormatString(constant.StringVal(tv.Value), format.Pos(), args)
+}
+
+// translates Go's format verbs to SourceMod's by the type of their args.
+// %v => %d, %s, %f, %c or a bool as "true"/"false"
+// %q => "%s"
+// %t => a bool as "true"/"false"
+func TranslateFormatString(format string, pos token.Pos, args []ast.Expr) (string, []string) {
+	var out strings.Builder
+	sp_args := make([]string, 0)
+	arg_idx := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		} else if i+1 < len(format) && format[i+1] == '%' {
+			out.WriteString("%%")
+			i++
+			continue
+		}
+		/// flags, width and precision carry over as they are.
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) != -1 {
+			i++
+		}
+		if i >= len(format) {
+			ASTMod.PrintSrcGoErr(pos, "Format string ends with an incomplete verb.")
+			break
+		} else if arg_idx >= len(args) {
+			ASTMod.PrintSrcGoErr(pos, fmt.Sprintf("Format verb '%%%c' is missing its arg.", format[i]))
+			break
+		}
+		flags, verb, arg := format[start+1:i], format[i], args[arg_idx]
+		arg_str := GetExprString(arg)
+		arg_idx++
+		typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(arg)
+		if verb == 'v' {
+			switch {
+			case typ == nil:
+				verb = 'd'
+			case ASTMod.IsStrType(typ):
+				verb = 's'
+			case ASTMod.IsFloatType(typ):
+				verb = 'f'
+			case IsBoolType(typ):
+				verb = 't'
+			case ASTMod.IsCharType(typ):
+				verb = 'c'
+			default:
+				verb = 'd'
+			}
+		}
+		switch verb {
+		case 'd', 'i', 's', 'f', 'x', 'X', 'c', 'b':
+			out.WriteString("%" + flags + string(verb))
+		case 'F', 'e', 'E', 'g', 'G':
+			out.WriteString("%" + flags + "f")
+		case 'w':
+			out.WriteString("%" + flags + "s")
+		case 'q':
+			out.WriteString("\"%" + flags + "s\"")
+		case 't':
+			out.WriteString("%" + flags + "s")
+			arg_str = "(" + arg_str + " ? \"true\" : \"false\")"
+		default:
+			ASTMod.PrintSrcGoErr(pos, fmt.Sprintf("Format verb '%%%c' has no SourcePawn equivalent.", verb))
+		}
+		sp_args = append(sp_args, arg_str)
+	}
+	if arg_idx < len(args) {
+		ASTMod.PrintSrcGoErr(pos, "Format string has more args than verbs.")
+	}
+	return QuoteSPString(out.String()), sp_args
+}
+
+func IsBoolType(typ types.Type) bool {
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	return is_basic && basic.Info()&types.IsBoolean > 0
+}
+
+// make([]T, n) => new ArrayList(blocksize, n)
+func MakeArrayList(call *ast.CallExpr) string {
+	args := make([]string, 0)
+	if block_size := ASTMod.ArrayListBlockSize(ASTMod.GetSliceElem(call.Args[0])); block_size != nil {
+		args = append(args, GetExprString(block_size))
+	}
+	if len(call.Args) > 1 {
+		if size := GetExprString(call.Args[1]); size != "0" {
+			if len(args) == 0 {
+				args = append(args, "1")
+			}
+			args = append(args, size)
+		}
+	}
+	return "new ArrayList(" + strings.Join(args, ", ") + ")"
+}
+
+// BaseBoss(x) => view_as<BaseBoss>(x), calling a methodmap's name would call its constructor.
+func IsHandleConversion(call *ast.CallExpr) bool {
+	if tv, found := ASTMod.ASTCtxt.TypeInfo.Types[call.Fun]; !found || !tv.IsType() || len(call.Args) != 1 {
+		return false
+	}
+	return IsMethodMapType(ASTMod.ASTCtxt.TypeInfo.TypeOf(call.Fun)) || IsMethodMapType(ASTMod.ASTCtxt.TypeInfo.TypeOf(call.Args[0]))
+}
+
+/**
+ * float(i)  => float(i)
+ * int(f)    => RoundToZero(f)
+ * int(b)    => view_as<int>(b)
+ * Action(n) => view_as<Action>(n)
+ * float(5)  => 5.0
+ */
+func MakeConversion(call *ast.CallExpr) string {
+	tv, found := ASTMod.ASTCtxt.TypeInfo.Types[call.Fun]
+	if !found || !tv.IsType() || len(call.Args) != 1 {
+		return ""
+	}
+	from := ASTMod.ASTCtxt.TypeInfo.TypeOf(call.Args[0])
+	to, to_basic := tv.Type.Underlying().(*types.Basic)
+	if from == nil || !to_basic {
+		return ""
+	}
+	arg := GetExprString(call.Args[0])
+	if to.Info()&types.IsString > 0 {
+		/// char arrays are already strings.
+		return arg
+	}
+	value := ASTMod.ASTCtxt.TypeInfo.Types[call].Value
+	named, is_named := tv.Type.(*types.Named)
+	tagged := is_named && !ASTMod.IsCharType(named) && to.Info()&types.IsFloat == 0
+	if value != nil && !tagged {
+		return GetConstString(value, to)
+	}
+
+	from_info := types.BasicInfo(0)
+	if basic, is_basic := from.Underlying().(*types.Basic); is_basic {
+		from_info = basic.Info()
+	}
+	switch {
+	case to.Info()&types.IsFloat > 0:
+		if from_info&types.IsFloat > 0 {
+			return arg
+		}
+		return "float(" + arg + ")"
+	case to.Info()&types.IsInteger > 0:
+		if from_info&types.IsFloat > 0 {
+			arg = FloatToInt + "(" + arg + ")"
+		} else if from_info&types.IsBoolean > 0 && !tagged {
+			return "view_as<int>(" + arg + ")"
+		}
+		if tagged {
+			return "view_as<" + named.Obj().Name() + ">(" + arg + ")"
+		}
+		return arg
+	}
+	return ""
+}
+
+// a constant as a value of a basic type, floats always have a decimal point.
+func GetConstString(value constant.Value, typ *types.Basic) string {
+	if typ.Info()&types.IsFloat > 0 {
+		f, _ := constant.Float64Val(constant.ToFloat(value))
+		float_str := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(float_str, ".") {
+			float_str += ".0"
+		}
+		return float_str
+	} else if typ.Info()&types.IsBoolean > 0 {
+		return value.ExactString()
+	}
+	return constant.ToInt(value).ExactString()
+}
+
 func GetExprString(e ast.Expr) string {
 	switch x := e.(type) {
 	case *ast.IndexExpr:
+		/// only scalars are left to read from ArrayLists.
+		if ASTMod.IsArrayList(x.X) {
+			return GetExprString(x.X) + ".Get(" + GetExprString(x.Index) + ")"
+		}
 		return GetExprString(x.X) + "[" + GetExprString(x.Index) + "]"
 
 	case *ast.KeyValueExpr:
@@ -781,8 +2654,44 @@ func GetExprString(e ast.Expr) string {
 		return x.Op.String() + GetExprString(x.X)
 
 	case *ast.CallExpr:
+		if IsMakeMap(x) {
+			return "new StringMap()"
+		} else if ASTMod.ASTCtxt.ArrayListMakes[x] {
+			return MakeArrayList(x)
+		}
+		if fmt_call := MakeFmtCall(x); fmt_call != "" {
+			return fmt_call
+		} else if ASTMod.GetPkgFunc(x, "errors") == "New" && len(x.Args) == 1 {
+			/// errors are their message.
+			return GetExprString(x.Args[0])
+		} else if IsErrorMethod(x) {
+			return GetExprString(x.Fun.(*ast.SelectorExpr).X)
+		} else if IsBuiltin(x.Fun, "panic") {
+			return MakePanicCall(x)
+		}
 		var call strings.Builder
 		name := GetExprString(x.Fun)
+		if prop := GetPropAccess(x); prop != "" {
+			return prop
+		} else if methodmap, is_ctor := MethodMapCtors[name]; is_ctor {
+			name = "new " + methodmap
+		} else if IsHandleConversion(x) {
+			return "view_as<" + name + ">(" + GetExprString(x.Args[0]) + ")"
+		} else if conversion := MakeConversion(x); conversion != "" {
+			return conversion
+		}
+		if name == "len" && len(x.Args) == 1 {
+			if ASTMod.GetMapType(x.Args[0]) != nil {
+				return GetExprString(x.Args[0]) + ".Size"
+			} else if ASTMod.IsArrayList(x.Args[0]) {
+				return GetExprString(x.Args[0]) + ".Length"
+			} else if typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(x.Args[0]); typ != nil {
+				/// Go strings have no size, only a length.
+				if basic, is_basic := typ.Underlying().(*types.Basic); is_basic && basic.Info()&types.IsString > 0 {
+					return "strlen(" + GetExprString(x.Args[0]) + ")"
+				}
+			}
+		}
 		if n, found := FuncNames[name]; found {
 			name = n
 		}
@@ -797,6 +2706,21 @@ func GetExprString(e ast.Expr) string {
 		return call.String()
 
 	case *ast.BinaryExpr:
+		if tv := ASTMod.ASTCtxt.TypeInfo.Types[x]; tv.Value != nil && (tv.Value.Kind() == constant.String || tv.Value.Kind() == constant.Bool) {
+			/// "a" + "b" => "ab"
+			return tv.Value.ExactString()
+		} else if nil_cmp := MakeNilStrCompare(x); nil_cmp != "" {
+			return nil_cmp
+		} else if IsStringType(x.X) || IsStringType(x.Y) {
+			switch x.Op {
+			case token.EQL:
+				return "StrEqual(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ")"
+			case token.NEQ:
+				return "!StrEqual(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ")"
+			case token.LSS, token.GTR, token.LEQ, token.GEQ:
+				return "strcmp(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ") " + x.Op.String() + " 0"
+			}
+		}
 		return GetExprString(x.X) + " " + x.Op.String() + " " + GetExprString(x.Y)
 
 	case *ast.SelectorExpr:
@@ -816,3 +2740,118 @@ func GetExprString(e ast.Expr) string {
 	}
 	return ""
 }
+
+// RetType Name(params)
+func MakePrototype(f *ast.FuncDecl) string {
+	ret_type := "void"
+	if f.Type.Results != nil {
+		ret_type = GetTypeString(f.Type.Results.List[0].Type, "", false)
+	}
+	return ret_type + " " + f.Name.Name + "(" + strings.Join(WriteParams(f.Type.Params), ", ") + ")"
+}
+
+// the include file for other plugins to use the natives and forwards of a plugin, empty if it has neither.
+// the plugin's types that the prototypes use are declared in the include too, without their methods.
+func MakeIncludeTypes(protos []*ast.FuncDecl) string {
+	var type_names []string
+	seen := make(map[string]bool)
+	for _, proto := range protos {
+		if obj := ASTMod.ASTCtxt.TypeInfo.ObjectOf(proto.Name); obj != nil {
+			CollectLocalTypes(obj.Type(), seen, &type_names)
+		}
+	}
+
+	var types_code CodeBuilder
+	plugin := SMPlugin{Structs: make(map[string]EStruct), TypeDefs: make(map[string]string)}
+	for _, name := range type_names {
+		plugin.MakeTypeSpec(LocalTypes[name])
+		if typedef, found := plugin.TypeDefs[name]; found {
+			types_code.WriteString(typedef + "\n\n")
+		} else if struc, found := plugin.Structs[name]; found {
+			struc.Write(&types_code, name)
+		} else if methodmap := plugin.FindMethodMap(name); methodmap != nil {
+			methodmap.Write(&types_code)
+		}
+	}
+	return types_code.String()
+}
+
+// adds the local types that 'typ' uses to 'names', each after the ones it uses.
+func CollectLocalTypes(typ types.Type, seen map[string]bool, names *[]string) {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Pointer:
+		CollectLocalTypes(t.Elem(), seen, names)
+	case *types.Array:
+		CollectLocalTypes(t.Elem(), seen, names)
+	case *types.Slice:
+		CollectLocalTypes(t.Elem(), seen, names)
+	case *types.Signature:
+		for i := 0; i < t.Params().Len(); i++ {
+			CollectLocalTypes(t.Params().At(i).Type(), seen, names)
+		}
+		for i := 0; i < t.Results().Len(); i++ {
+			CollectLocalTypes(t.Results().At(i).Type(), seen, names)
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			CollectLocalTypes(t.Field(i).Type(), seen, names)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			CollectLocalTypes(t.ExplicitMethod(i).Type(), seen, names)
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			CollectLocalTypes(t.EmbeddedType(i), seen, names)
+		}
+	case *types.Union:
+		for i := 0; i < t.Len(); i++ {
+			CollectLocalTypes(t.Term(i).Type(), seen, names)
+		}
+	case *types.Named:
+		name := t.Obj().Name()
+		type_spec, local := LocalTypes[name]
+		if !local || seen[name] {
+			return
+		}
+		seen[name] = true
+		/// a methodmap's parent, an enum struct's fields or a typeset's signatures.
+		CollectLocalTypes(ASTMod.ASTCtxt.TypeInfo.TypeOf(type_spec.Type), seen, names)
+		*names = append(*names, name)
+	}
+}
+
+func GenerateIncludeFile(library string) string {
+	natives, forwards := ASTMod.ASTCtxt.Natives, ASTMod.ASTCtxt.Forwards
+	if len(natives) == 0 && len(forwards) == 0 {
+		return ""
+	}
+
+	var inc strings.Builder
+	inc.WriteString(Header)
+	inc.WriteString(fmt.Sprintf("#if defined _%s_included\n\t#endinput\n#endif\n#define _%s_included\n\n", library, library))
+	inc.WriteString(MakeIncludeTypes(append(append([]*ast.FuncDecl{}, natives...), forwards...)))
+	for _, native := range natives {
+		inc.WriteString("native " + MakePrototype(native) + ";\n")
+	}
+	if len(natives) > 0 {
+		inc.WriteString("\n")
+	}
+	for _, forward := range forwards {
+		inc.WriteString("forward " + MakePrototype(forward) + ";\n")
+	}
+	if len(forwards) > 0 {
+		inc.WriteString("\n")
+	}
+
+	inc.WriteString(fmt.Sprintf("public SharedPlugin __pl_%s = {\n", library))
+	inc.WriteString(fmt.Sprintf("\tname = \"%s\",\n\tfile = \"%s.smx\",\n", library, library))
+	inc.WriteString("#if defined REQUIRE_PLUGIN\n\trequired = 1,\n#else\n\trequired = 0,\n#endif\n};\n")
+	if len(natives) > 0 {
+		inc.WriteString(fmt.Sprintf("\n#if !defined REQUIRE_PLUGIN\npublic void __pl_%s_SetNTVOptional()\n{", library))
+		for _, native := range natives {
+			inc.WriteString(fmt.Sprintf("\n\tMarkNativeAsOptional(\"%s\");", native.Name.Name))
+		}
+		inc.WriteString("\n}\n#endif\n")
+	}
+	return inc.String()
+}
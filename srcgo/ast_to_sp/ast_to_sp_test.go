@@ -0,0 +1,105 @@
+/**
+ * ast_to_sp_test.go
+ *
+ * Copyright 2020 Nirari Technologies, Alliedmodders LLC.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package GoToSPGen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"testing"
+
+	ASTMod "github.com/assyrianic/Go2SourcePawn/srcgo/ast_transform"
+)
+
+// declares a type of the bindings, like 'type Handle uintptr'.
+func NewTestNamed(pkg *types.Package, name string, underlying types.Type) *types.Named {
+	return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), underlying, nil)
+}
+
+// declares an alias of the bindings, like 'type Vec3 = [3]float'.
+func NewTestAlias(pkg *types.Package, name string, rhs types.Type) *types.Alias {
+	return types.NewAlias(types.NewTypeName(token.NoPos, pkg, name, nil), rhs)
+}
+
+func TestMakeTypeString(t *testing.T) {
+	pkg := types.NewPackage("main", "main")
+	char := NewTestNamed(pkg, "char", types.Typ[types.Byte])
+	float := NewTestAlias(pkg, "float", types.Typ[types.Float64])
+	vec3 := NewTestAlias(pkg, "Vec3", types.NewArray(float, 3))
+	entity := NewTestAlias(pkg, "Entity", types.Typ[types.Int])
+	handle := NewTestNamed(pkg, "Handle", types.Typ[types.Uintptr])
+	slot2 := NewTestNamed(pkg, "Slot2", types.Typ[types.Int])
+	player2 := NewTestNamed(pkg, "Player2", types.NewStruct(nil, nil))
+	LocalTypes = map[string]*ast.TypeSpec{"Player2": {}}
+	defer func() { LocalTypes = make(map[string]*ast.TypeSpec) }()
+
+	tests := []struct {
+		typ  types.Type
+		name string
+		pos  TypePos
+		want string
+	}{
+		/// sized numbers are cells.
+		{types.Typ[types.Int8], "a", TypePosVar, "int a"},
+		{types.Typ[types.Int16], "a", TypePosVar, "int a"},
+		{types.Typ[types.Int32], "a", TypePosVar, "int a"},
+		{types.Typ[types.Int64], "a", TypePosParam, "int a"},
+		{types.Typ[types.Uint], "a", TypePosVar, "int a"},
+		{types.Typ[types.Uint16], "a", TypePosVar, "int a"},
+		{types.Typ[types.Uint32], "a", TypePosField, "int a"},
+		{types.Typ[types.Uint64], "a", TypePosVar, "int a"},
+		{types.Typ[types.Rune], "r", TypePosVar, "int r"},
+		{types.Typ[types.Float32], "f", TypePosVar, "float f"},
+		{types.Typ[types.Float64], "", TypePosReturn, "float"},
+		{types.Typ[types.Bool], "ok", TypePosVar, "bool ok"},
+
+		/// bytes are chars.
+		{types.Typ[types.Byte], "c", TypePosVar, "char c"},
+		{types.Typ[types.Uint8], "c", TypePosParam, "char c"},
+		{char, "c", TypePosVar, "char c"},
+		{types.NewArray(types.Typ[types.Byte], 32), "name", TypePosVar, "char name[32]"},
+		{types.NewSlice(char), "buf", TypePosParam, "char[] buf"},
+
+		/// aliases are written as what they stand for.
+		{float, "f", TypePosVar, "float f"},
+		{entity, "client", TypePosParam, "int client"},
+		{vec3, "v", TypePosVar, "float v[3]"},
+		{vec3, "v", TypePosParam, "const float v[3]"},
+		{types.NewPointer(vec3), "v", TypePosParam, "float v[3]"},
+		{vec3, "", TypePosReturn, "float[3]"},
+		{types.NewArray(vec3, 2), "vs", TypePosField, "float vs[2][3]"},
+
+		/// names ending in digits keep them.
+		{slot2, "s", TypePosVar, "Slot2 s"},
+		{types.NewArray(slot2, 4), "s", TypePosParam, "const Slot2 s[4]"},
+		{player2, "p", TypePosVar, "Player2 p"},
+		{player2, "p", TypePosParam, "const Player2 p"},
+		{types.NewPointer(player2), "p", TypePosParam, "Player2 p"},
+		{types.NewArray(player2, 4), "ps", TypePosField, "Player2 ps[4]"},
+
+		{handle, "h", TypePosParam, "Handle h"},
+		{types.NewPointer(handle), "h", TypePosParam, "Handle& h"},
+		{types.NewPointer(types.Typ[types.Int64]), "n", TypePosParam, "int& n"},
+		{types.Typ[types.String], "s", TypePosParam, "const char[] s"},
+		{types.Typ[types.String], "s", TypePosField, fmt.Sprintf("char s[%d]", ASTMod.StrBufferLen)},
+		{types.NewArray(types.NewArray(types.Typ[types.Int16], 3), 2), "m", TypePosVar, "int m[2][3]"},
+		{types.NewSignature(nil, nil, nil, false), "cb", TypePosParam, "Function cb"},
+	}
+	for _, test := range tests {
+		if got := MakeTypeString(test.typ, test.name, test.pos, token.NoPos); got != test.want {
+			t.Errorf("MakeTypeString(%s, %q, %d) = %q, want %q", test.typ, test.name, test.pos, got, test.want)
+		}
+	}
+}
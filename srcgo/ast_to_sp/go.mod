@@ -1,5 +1,5 @@
 module github.com/assyrianic/SourceGo/srcgo/ast_to_sp
 
-go 1.17
+go 1.22
 
 replace github.com/assyrianic/SourceGo/srcgo/ast_transform => ./srcgo/ast_transform
@@ -25,9 +25,13 @@ import (
 	"go/types"
 	"go/format"
 	"go/constant"
+	"path/filepath"
 )
 
 
+/// size of the char buffers made for strings that have no known length.
+const StrBufferLen = 256
+
 var ASTCtxt struct {
 	TypeInfo      *types.Info
 	NewDecls      []ast.Decl
@@ -37,6 +41,16 @@ var ASTCtxt struct {
 	BuiltInTypes  map[string]types.Object
 	Err           func(err error)
 	RangeIter,TmpVar,TmpFunc uint
+	ArrayLists    map[token.Pos]bool
+	ArrayListMakes map[*ast.CallExpr]bool
+	SliceLens     map[token.Pos]ast.Expr
+	Natives, Forwards []*ast.FuncDecl
+	StrBufferSizes map[*ast.Ident]ast.Expr
+	ErrorFuncs    map[types.Object]bool
+	ErrorLenFuncs map[types.Object]bool
+	ErrorLens     map[string]string
+	PromotedMethods map[string]bool
+	EnumStructs   map[types.Object]bool
 }
 
 func PtrizeExpr(x ast.Expr) *ast.StarExpr {
@@ -85,28 +99,61 @@ func MakeAssign(create bool) *ast.AssignStmt {
 	return assign
 }
 
-
 func MakeVarDecl(names []*ast.Ident, val ast.Expr, typ types.Type) *ast.DeclStmt {
-	decl_stmt := new(ast.DeclStmt)
-	gen_decl := new(ast.GenDecl)
-	gen_decl.Tok = token.VAR
-	gen_decl.Lparen = token.NoPos
-	
+	if val != nil {
+		return MakeTypedVarDecl(names, ValueToTypeExpr(val))
+	}
+	return MakeTypedVarDecl(names, TypeToASTExpr(typ))
+}
+
+func MakeTypedVarDecl(names []*ast.Ident, typ ast.Expr) *ast.DeclStmt {
 	val_spec := new(ast.ValueSpec)
 	for _, name := range names {
 		val_spec.Names = append(val_spec.Names, name)
 	}
-	if val != nil {
-		val_spec.Type = ValueToTypeExpr(val)
-	} else {
-		val_spec.Type = TypeToASTExpr(typ)
-	}
-	gen_decl.Specs = append(gen_decl.Specs, val_spec)
-	
+	val_spec.Type = typ
+	return MakeDeclStmt(token.VAR, val_spec)
+}
+
+func MakeDeclStmt(tok token.Token, specs ...ast.Spec) *ast.DeclStmt {
+	decl_stmt := new(ast.DeclStmt)
+	gen_decl := new(ast.GenDecl)
+	gen_decl.Tok = tok
+	gen_decl.Lparen = token.NoPos
+	gen_decl.Specs = specs
 	decl_stmt.Decl = gen_decl
 	return decl_stmt
 }
 
+func MakeCall(name string, args ...ast.Expr) *ast.CallExpr {
+	call := new(ast.CallExpr)
+	call.Fun = ast.NewIdent(name)
+	call.Args = args
+	return call
+}
+
+func MakeSelector(x ast.Expr, name string) *ast.SelectorExpr {
+	sel := new(ast.SelectorExpr)
+	sel.X = x
+	sel.Sel = ast.NewIdent(name)
+	return sel
+}
+
+func MakeMethodCall(x ast.Expr, method string, args ...ast.Expr) *ast.CallExpr {
+	call := new(ast.CallExpr)
+	call.Fun = MakeSelector(x, method)
+	call.Args = args
+	return call
+}
+
+func MakeBinaryExpr(x ast.Expr, op token.Token, y ast.Expr) *ast.BinaryExpr {
+	b := new(ast.BinaryExpr)
+	b.X = x
+	b.Op = op
+	b.Y = y
+	return b
+}
+
 
 func MakeBitNotExpr(e ast.Expr) *ast.UnaryExpr {
 	u := new(ast.UnaryExpr)
@@ -137,6 +184,8 @@ func GetTypeBase(t types.Type) types.Type {
 func TypeToASTExpr(typ types.Type) ast.Expr {
 	var type_stack []types.Type
 	for typ != nil {
+		/// aliases are written as the type they stand for.
+		typ = types.Unalias(typ)
 		type_stack = append(type_stack, typ)
 		typ = GetTypeBase(typ)
 	}
@@ -146,10 +195,19 @@ func TypeToASTExpr(typ types.Type) ast.Expr {
 		switch t := type_stack[i].(type) {
 			case *types.Array:
 				x = Arrayify( x, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())) )
+			case *types.Slice:
+				x = Arrayify(x, nil)
 			case *types.Pointer:
 				x = PtrizeExpr(x)
-			case *types.Basic, *types.Named:
+			case *types.Map:
+				m := new(ast.MapType)
+				m.Key = TypeToASTExpr(t.Key())
+				m.Value = TypeToASTExpr(t.Elem())
+				x = m
+			case *types.Basic:
 				x = ast.NewIdent(t.String())
+			case *types.Named:
+				x = ast.NewIdent(t.Obj().Name())
 		}
 	}
 	return x
@@ -206,6 +264,19 @@ func FindStmt(a []ast.Stmt, x ast.Stmt) int {
 	return -1
 }
 
+/// replaces 's' in the statement list with the given statements.
+func ReplaceStmt(list *[]ast.Stmt, s ast.Stmt, stmts ...ast.Stmt) {
+	index := FindStmt(*list, s)
+	if index < 0 {
+		return
+	}
+	new_list := make([]ast.Stmt, 0, len(*list) + len(stmts))
+	new_list = append(new_list, (*list)[:index]...)
+	new_list = append(new_list, stmts...)
+	new_list = append(new_list, (*list)[index+1:]...)
+	*list = new_list
+}
+
 func FindParam(fn *ast.FuncDecl, name string) (*ast.Field, int) {
 	for _, field := range fn.Type.Params.List {
 		for i, iden := range field.Names {
@@ -312,6 +383,43 @@ func IsFuncPtr(expr ast.Expr) bool {
 	return false
 }
 
+func IsCharType(t types.Type) bool {
+	switch t := t.(type) {
+		case *types.Named:
+			return t.Obj().Name()=="char"
+		case *types.Basic:
+			return t.Kind()==types.Byte
+	}
+	return false
+}
+
+/// '[]char' is a buffer that can be written to, unlike 'string'.
+func IsCharBuffer(t types.Type) bool {
+	s, is_slice := t.Underlying().(*types.Slice)
+	return is_slice && IsCharType(s.Elem())
+}
+
+/// strings, char arrays, char slices and errors are all strings in SourcePawn.
+func IsStrType(t types.Type) bool {
+	if IsErrorType(t) {
+		/// errors are strings too.
+		return true
+	}
+	switch t := t.(type) {
+		case *types.Basic:
+			return t.Info() & types.IsString > 0
+		case *types.Array:
+			return IsCharType(t.Elem())
+		case *types.Slice:
+			return IsCharType(t.Elem())
+		case *types.Named:
+			if !IsCharType(t) {
+				return IsStrType(t.Underlying())
+			}
+	}
+	return false
+}
+
 func GetFuncName(expr ast.Expr) string {
 	if expr != nil {
 		switch e := expr.(type) {
@@ -329,6 +437,31 @@ func GetFuncName(expr ast.Expr) string {
 }
 
 
+/// code made by the transpiler has no position, its type errors are fine when they're from what only SourcePawn has:
+/// the StringMap and ArrayList methods of maps and slices, untyped DataPack reads, 'any[]' params that take any array,
+/// params left to their SourcePawn default and methods promoted from embedded structs.
+func IsGeneratedTypeErr(err types.Error) bool {
+	if err.Pos.IsValid() {
+		return false
+	}
+	switch {
+		case strings.Contains(err.Msg, "undefined (type map["), strings.Contains(err.Msg, "undefined (type []"):
+			return true
+		case strings.Contains(err.Msg, "need type assertion"):
+			return true
+		case strings.Contains(err.Msg, "as []any value in argument to"):
+			return true
+		case strings.HasPrefix(err.Msg, "not enough arguments in call to"):
+			return true
+	}
+	for name := range ASTCtxt.PromotedMethods {
+		if strings.HasSuffix(err.Msg, "has no field or method " + name + ")") {
+			return true
+		}
+	}
+	return false
+}
+
 func PrintSrcGoErr(p token.Pos, msg string) {
 	ASTCtxt.Err(errors.New("SourceGo :: " + ASTCtxt.FSet.PositionFor(p, false).String() + ": " + msg))
 }
@@ -409,6 +542,9 @@ func AddSrcGoTypes() {
 	/// func __sp__(code string)
 	/// void __sp__(const char[] code);
 	MakeFunc("__sp__", nil, MakeParams([]string{"code"}, []types.Type{types.Typ[types.String]}), nil, false)
+	
+	/// func sizeof(x any) int
+	MakeFunc("sizeof", nil, MakeParams([]string{"x"}, []types.Type{types.NewInterfaceType(nil, nil)}), MakeRet([]types.Type{types.Typ[types.Int]}), false)
 }
 
 func SetUpSrcGo(fset *token.FileSet, info *types.Info, err_fn func(err error)) {
@@ -455,20 +591,20 @@ func AnalyzeIllegalCode(file *ast.File) {
 						}
 					}
 				case *ast.BranchStmt:
-					if x.Tok==token.GOTO || x.Tok==token.FALLTHROUGH {
-						PrintSrcGoErr(x.Pos(), fmt.Sprintf(" %s is Illegal.", x.Tok.String()))
-					} else if x.Label != nil {
-						PrintSrcGoErr(x.Pos(), "Branched Labels are Illegal.")
+					if x.Tok==token.GOTO {
+						PrintSrcGoErr(x.Pos(), " goto is Illegal, SourcePawn has no goto.")
 					}
 				
 				case *ast.CommClause:
 					PrintSrcGoErr(x.Pos(), "Comm Select Cases are Illegal.")
-				case *ast.DeferStmt:
-					PrintSrcGoErr(x.Pos(), "Defer Statements are Illegal.")
 				case *ast.TypeSwitchStmt:
 					PrintSrcGoErr(x.Pos(), "Type-Switches are Illegal.")
 				case *ast.LabeledStmt:
-					PrintSrcGoErr(x.Pos(), "Labels are Illegal.")
+					switch x.Stmt.(type) {
+						case *ast.ForStmt, *ast.RangeStmt:
+						default:
+							PrintSrcGoErr(x.Pos(), "Labels are only allowed on loops.")
+					}
 				case *ast.GoStmt:
 					PrintSrcGoErr(x.Pos(), "Goroutines are Illegal.")
 				case *ast.SelectStmt:
@@ -496,40 +632,134 @@ func AnalyzeIllegalCode(file *ast.File) {
 }
 
 
-func MergeRetVals(file *ast.File) {
+/**
+ * embedded structs are flattened into the enum struct embedding them.
+ * 
+ * p.BaseEntityData.Health
+ * 
+ * Becomes:
+ * p.Health
+ */
+func MutateEmbeds(file *ast.File) {
+	for _, decl := range file.Decls {
+		if gen_decl, is_gendecl := decl.(*ast.GenDecl); is_gendecl && gen_decl.Tok==token.TYPE {
+			for _, spec := range gen_decl.Specs {
+				type_spec := spec.(*ast.TypeSpec)
+				if struct_type, is_struct := type_spec.Type.(*ast.StructType); is_struct {
+					CheckEmbeddedFields(struct_type, type_spec.Name.Name)
+				}
+			}
+		}
+	}
+	
+	ASTCtxt.PromotedMethods = make(map[string]bool)
 	ast.Inspect(file, func(n ast.Node) bool {
-		if n != nil {
-			switch f := n.(type) {
-				case *ast.FuncDecl:
-					f.Type.Params.List = MutateRetTypes(&f.Type.Results, f.Type.Params, f.Name.Name)
-				case *ast.TypeSpec:
-					if t, is_func_type := f.Type.(*ast.FuncType); is_func_type {
-						t.Params.List = MutateRetTypes(&t.Results, t.Params, f.Name.Name)
-					}
+		if sel, is_sel := n.(*ast.SelectorExpr); is_sel {
+			flattened := false
+			for {
+				inner, is_inner_sel := sel.X.(*ast.SelectorExpr)
+				if !is_inner_sel || !IsEmbeddedField(inner.Sel) {
+					break
+				}
+				sel.X, flattened = inner.X, true
+			}
+			/// a method overridden by the struct embedding it is promoted as 'Embedded_Method'.
+			if method, is_method := ASTCtxt.TypeInfo.Uses[sel.Sel].(*types.Func); is_method && flattened {
+				if resolved, _, _ := types.LookupFieldOrMethod(ASTCtxt.TypeInfo.TypeOf(sel.X), true, method.Pkg(), method.Name()); resolved != method {
+					sel.Sel = ast.NewIdent(GetRecvTypeName(method) + "_" + method.Name())
+					ASTCtxt.TypeInfo.Uses[sel.Sel] = method
+					ASTCtxt.PromotedMethods[sel.Sel.Name] = true
+				}
+			}
+			if IsEmbeddedField(sel.Sel) {
+				PrintSrcGoErr(sel.Pos(), fmt.Sprintf("'%s' can only be used to select its fields and methods, embedded structs are flattened.", types.ExprString(sel)))
 			}
 		}
 		return true
 	})
 }
 
-func ChangeRecvrNames(file *ast.File) {
+func GetRecvTypeName(method *types.Func) string {
+	recv := method.Type().(*types.Signature).Recv().Type()
+	if ptr, is_ptr := recv.(*types.Pointer); is_ptr {
+		recv = ptr.Elem()
+	}
+	if named, is_named := recv.(*types.Named); is_named {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+func IsEmbeddedField(ident *ast.Ident) bool {
+	field, is_var := ASTCtxt.TypeInfo.Uses[ident].(*types.Var)
+	return is_var && field.IsField() && field.Embedded()
+}
+
+/// the fields of embedded structs end up next to the fields of the struct embedding them, so their names can't clash.
+func CheckEmbeddedFields(struct_type *ast.StructType, name string) {
+	has_embeds := false
+	for _, field := range struct_type.Fields.List {
+		if field.Names==nil {
+			has_embeds = true
+			typ := ASTCtxt.TypeInfo.TypeOf(field.Type)
+			if _, is_struct := typ.Underlying().(*types.Struct); !is_struct {
+				PrintSrcGoErr(field.Pos(), fmt.Sprintf("only structs can be embedded, '%s' is a '%s'.", types.ExprString(field.Type), typ.Underlying()))
+			}
+		}
+	}
+	if !has_embeds {
+		return
+	}
+	
+	seen := make(map[string]bool)
+	var flatten func(s *types.Struct)
+	flatten = func(s *types.Struct) {
+		for i := 0; i < s.NumFields(); i++ {
+			field := s.Field(i)
+			if embedded, is_struct := field.Type().Underlying().(*types.Struct); field.Embedded() && is_struct {
+				flatten(embedded)
+			} else if seen[field.Name()] {
+				PrintSrcGoErr(field.Pos(), fmt.Sprintf("field '%s' is declared more than once in enum struct '%s' after flattening its embedded structs.", field.Name(), name))
+			} else {
+				seen[field.Name()] = true
+			}
+		}
+	}
+	if s, is_struct := ASTCtxt.TypeInfo.TypeOf(struct_type).(*types.Struct); is_struct {
+		flatten(s)
+	}
+}
+
+/// a function given as a typeset has to match one of its signatures.
+func CheckTypeSets(file *ast.File) {
 	ast.Inspect(file, func(n ast.Node) bool {
 		if n != nil {
-			switch f := n.(type) {
-				case *ast.FuncDecl:
-					if f.Recv != nil && f.Recv.List[0].Names != nil && len(f.Recv.List[0].Names) > 0 {
-						recvr := f.Recv.List[0].Names[0].Name
-						ast.Inspect(f.Body, func(n ast.Node) bool {
-							if n != nil {
-								switch i := n.(type) {
-									case *ast.Ident:
-										if recvr==i.Name {
-											i.Name = "this"
-										}
-								}
+			switch x := n.(type) {
+				case *ast.CallExpr:
+					sig, is_sig := ASTCtxt.TypeInfo.TypeOf(x.Fun).(*types.Signature)
+					if !is_sig {
+						break
+					}
+					for i, arg := range x.Args {
+						if sig.Variadic() && i >= sig.Params().Len() - 1 {
+							if slice, is_slice := sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice); is_slice {
+								CheckTypeSetValue(slice.Elem(), arg)
 							}
-							return true
-						})
+						} else if i < sig.Params().Len() {
+							CheckTypeSetValue(sig.Params().At(i).Type(), arg)
+						}
+					}
+				case *ast.AssignStmt:
+					if len(x.Lhs)==len(x.Rhs) {
+						for i := range x.Lhs {
+							CheckTypeSetValue(ASTCtxt.TypeInfo.TypeOf(x.Lhs[i]), x.Rhs[i])
+						}
+					}
+				case *ast.ValueSpec:
+					if x.Type != nil {
+						for _, value := range x.Values {
+							CheckTypeSetValue(ASTCtxt.TypeInfo.TypeOf(x.Type), value)
+						}
 					}
 			}
 		}
@@ -537,1030 +767,5104 @@ func ChangeRecvrNames(file *ast.File) {
 	})
 }
 
-func MakeFuncPtrArgCall(arg ast.Expr, by_ref bool, pretyp types.Type) *ast.ExprStmt {
-	var typ types.Type
-	if typ = ASTCtxt.TypeInfo.TypeOf(arg); typ == nil && pretyp != nil {
-		typ = pretyp
+func CheckTypeSetValue(typ types.Type, value ast.Expr) {
+	named, is_named := typ.(*types.Named)
+	if !is_named {
+		return
+	}
+	sigs := GetTypeSetSigs(named)
+	if len(sigs)==0 {
+		return
+	}
+	/// values of the typeset itself or 'INVALID_FUNCTION' have no signature to check.
+	value_sig, is_func := ASTCtxt.TypeInfo.TypeOf(value).(*types.Signature)
+	if !is_func {
+		return
+	}
+	for _, sig := range sigs {
+		if IsSameSignature(sig, value_sig) {
+			return
+		}
 	}
+	PrintSrcGoErr(value.Pos(), fmt.Sprintf("'%s' of type '%s' doesn't match any signature of typeset '%s'.", types.ExprString(value), value_sig, named.Obj().Name()))
+}
+
+/// the signatures of an interface made of methods and/or func types, nil if it isn't a typeset.
+func GetTypeSetSigs(typ types.Type) []*types.Signature {
+	var sigs []*types.Signature
 	switch t := typ.(type) {
-		case *types.Array:
-			switch t.Elem().String() {
-				case "char":
-					Call_PushStringEx := new(ast.CallExpr)
-					Call_PushStringEx.Fun = ast.NewIdent("Call_PushStringEx")
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, arg)
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "1"))
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "2"))
-					
-					Call_PushString_stmt := new(ast.ExprStmt)
-					Call_PushString_stmt.X = Call_PushStringEx
-					return Call_PushString_stmt
-				default:
-					Call_PushArrayEx := new(ast.CallExpr)
-					Call_PushArrayEx.Fun = ast.NewIdent("Call_PushArrayEx")
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, arg)
-					
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
-					
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, "1"))
-					
-					Call_PushArrayEx_stmt := new(ast.ExprStmt)
-					Call_PushArrayEx_stmt.X = Call_PushArrayEx
-					return Call_PushArrayEx_stmt
+		case *types.Named:
+			return GetTypeSetSigs(t.Underlying())
+		case *types.Signature:
+			sigs = append(sigs, t)
+		case *types.Union:
+			for i := 0; i < t.Len(); i++ {
+				sigs = append(sigs, GetTypeSetSigs(t.Term(i).Type())...)
 			}
-		case *types.Pointer:
-			switch t.Elem().String() {
-				case "float":
-					Call_PushFloatRef := new(ast.CallExpr)
-					Call_PushFloatRef.Fun = ast.NewIdent("Call_PushFloatRef")
-					if by_ref {
-						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, MakeReference(arg))
-					} else {
-						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, arg)
-					}
-					
-					Call_PushFloatRef_stmt := new(ast.ExprStmt)
-					Call_PushFloatRef_stmt.X = Call_PushFloatRef
-					return Call_PushFloatRef_stmt
-				default:
-					Call_PushCellRef := new(ast.CallExpr)
-					Call_PushCellRef.Fun = ast.NewIdent("Call_PushCellRef")
-					if by_ref {
-						Call_PushCellRef.Args = append(Call_PushCellRef.Args, MakeReference(arg))
-					} else {
-						Call_PushCellRef.Args = append(Call_PushCellRef.Args, arg)
-					}
-					
-					Call_PushCellRef_stmt := new(ast.ExprStmt)
-					Call_PushCellRef_stmt.X = Call_PushCellRef
-					return Call_PushCellRef_stmt
+		case *types.Interface:
+			for i := 0; i < t.NumExplicitMethods(); i++ {
+				sigs = append(sigs, t.ExplicitMethod(i).Type().(*types.Signature))
 			}
-		case *types.Basic:
-			switch t.Name() {
-				case "string":
-					Call_PushString := new(ast.CallExpr)
-					Call_PushString.Fun = ast.NewIdent("Call_PushString")
-					Call_PushString.Args = append(Call_PushString.Args, arg)
-					
-					Call_PushString_stmt := new(ast.ExprStmt)
-					Call_PushString_stmt.X = Call_PushString
-					return Call_PushString_stmt
-				case "float", "float32":
-					Call_PushFloat := new(ast.CallExpr)
-					if by_ref {
-						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloatRef")
-						Call_PushFloat.Args = append(Call_PushFloat.Args, MakeReference(arg))
-					} else {
-						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloat")
-						Call_PushFloat.Args = append(Call_PushFloat.Args, arg)
-					}
-					Call_PushFloat_stmt := new(ast.ExprStmt)
-					Call_PushFloat_stmt.X = Call_PushFloat
-					return Call_PushFloat_stmt
-				default:
-					Call_PushCell := new(ast.CallExpr)
-					if by_ref {
-						Call_PushCell.Fun = ast.NewIdent("Call_PushCellRef")
-						Call_PushCell.Args = append(Call_PushCell.Args, MakeReference(arg))
-					} else {
-						Call_PushCell.Fun = ast.NewIdent("Call_PushCell")
-						Call_PushCell.Args = append(Call_PushCell.Args, arg)
-					}
-					Call_PushCell_stmt := new(ast.ExprStmt)
-					Call_PushCell_stmt.X = Call_PushCell
-					return Call_PushCell_stmt
+			for i := 0; i < t.NumEmbeddeds(); i++ {
+				sigs = append(sigs, GetTypeSetSigs(t.EmbeddedType(i))...)
 			}
 	}
-	return nil
+	return sigs
 }
 
-func ExpandFuncPtrCalls(x *ast.CallExpr, retvals []ast.Expr, retTypes []types.Type) []ast.Stmt {
-	new_stmts := make([]ast.Stmt, 0)
-	Call_StartFunction := new(ast.CallExpr)
-	Call_StartFunction.Fun = ast.NewIdent("Call_StartFunction")
-	Call_StartFunction.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
-	Call_StartFunction.Args = append(Call_StartFunction.Args, x.Fun)
-	
-	Call_StartFunction_stmt := new(ast.ExprStmt)
-	Call_StartFunction_stmt.X = Call_StartFunction
-	new_stmts = append(new_stmts, Call_StartFunction_stmt)
-	
-	for _, arg := range x.Args {
-		if func_call := MakeFuncPtrArgCall(arg, false, nil); func_call != nil {
-			new_stmts = append(new_stmts, func_call)
-		}
+/// SourcePawn only sees the types underneath named types, method receivers and param names don't matter.
+func IsSameSignature(a, b *types.Signature) bool {
+	return a.Variadic()==b.Variadic() && IsSameTuple(a.Params(), b.Params()) && IsSameTuple(a.Results(), b.Results())
+}
+
+func IsSameTuple(a, b *types.Tuple) bool {
+	if a.Len() != b.Len() {
+		return false
 	}
-	
-	if retvals != nil && len(retvals) > 0 {
-		rets := len(retvals)
-		for i:=1; i<rets; i++ {
-			_, is_ptr := retvals[i].(*ast.StarExpr)
-			func_call := MakeFuncPtrArgCall(retvals[i], !is_ptr, nil)
-			if func_call != nil {
-				new_stmts = append(new_stmts, func_call)
-			} else if retTypes != nil {
-				func_call = MakeFuncPtrArgCall(retvals[i], !is_ptr, retTypes[i])
-				new_stmts = append(new_stmts, func_call)
-			}
+	for i := 0; i < a.Len(); i++ {
+		if !IsSameSPType(a.At(i).Type(), b.At(i).Type()) {
+			return false
 		}
-		Call_Finish := new(ast.CallExpr)
-		Call_Finish.Fun = ast.NewIdent("Call_Finish")
-		
-		var retval_type ast.Expr
-		if field, _ := FindParam(ASTCtxt.CurrFunc, retvals[0].(*ast.Ident).Name); field != nil {
-			retval_type = field.Type
-		}
-		
-		if _, is_ptr := retval_type.(*ast.StarExpr); is_ptr {
-			Call_Finish.Args = append(Call_Finish.Args, retvals[0])
-		} else {
-			Call_Finish.Args = append(Call_Finish.Args, MakeReference(retvals[0]))
-		}
-		
-		Call_Finish_stmt := new(ast.ExprStmt)
-		Call_Finish_stmt.X = Call_Finish
-		new_stmts = append(new_stmts, Call_Finish_stmt)
-	} else {
-		Call_Finish := new(ast.CallExpr)
-		Call_Finish.Fun = ast.NewIdent("Call_Finish")
-		Call_Finish.Args = nil
-		
-		Call_Finish_stmt := new(ast.ExprStmt)
-		Call_Finish_stmt.X = Call_Finish
-		new_stmts = append(new_stmts, Call_Finish_stmt)
 	}
-	return new_stmts
+	return true
 }
 
+func IsSameSPType(a, b types.Type) bool {
+	if types.Identical(a, b) {
+		return true
+	}
+	switch t := a.Underlying().(type) {
+		case *types.Basic:
+			u, is_basic := b.Underlying().(*types.Basic)
+			return is_basic && t.Kind()==u.Kind()
+		case *types.Pointer:
+			u, is_ptr := b.Underlying().(*types.Pointer)
+			return is_ptr && IsSameSPType(t.Elem(), u.Elem())
+		case *types.Array:
+			u, is_array := b.Underlying().(*types.Array)
+			return is_array && t.Len()==u.Len() && IsSameSPType(t.Elem(), u.Elem())
+		case *types.Slice:
+			u, is_slice := b.Underlying().(*types.Slice)
+			return is_slice && IsSameSPType(t.Elem(), u.Elem())
+	}
+	return false
+}
 
-type (
-	StmtMutator  func(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator)
-	BlockMutator func(b *ast.BlockStmt, mutator StmtMutator)
-)
-
-func MutateRets(file *ast.File) {
-	/** Case Studies of Returning statements to transform:
-	 * 
-	 * return m3() /// func m3() (type, type, type)
-	 * 
-	 * return int, float, m1() /// func m1() type
-	 */
+/**
+ * errors are strings in SourcePawn, so they're returned through a string param like any other extra return value.
+ * a function that only returns an error gets the param here, before 'MergeRetVals' does it for the rest.
+ * 
+ * func Load(path string) error {
+ *     return errors.New("missing")
+ * }
+ * if err := Load(path); err != nil {}
+ * 
+ * Becomes:
+ * func Load(path string, Load_param0 *error) {
+ *     *Load_param0 = errors.New("missing")
+ *     return
+ * }
+ * {
+ *     var err error
+ *     Load(path, &err)
+ *     if err != nil {}
+ * }
+ */
+func MutateErrors(file *ast.File) {
+	ASTCtxt.ErrorFuncs = make(map[types.Object]bool)
+	ASTCtxt.ErrorLenFuncs = make(map[types.Object]bool)
+	ASTCtxt.ErrorLens = make(map[string]string)
 	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateRetStmts)
-				}
-				ASTCtxt.CurrFunc = nil
+		if f, is_func := decl.(*ast.FuncDecl); is_func && f.Body != nil && GetErrorResult(f) != nil {
+			ASTCtxt.ErrorFuncs[ASTCtxt.TypeInfo.Defs[f.Name]] = true
+			ASTCtxt.ErrorLenFuncs[ASTCtxt.TypeInfo.Defs[f.Name]] = true
+			/// the calls to it are changed before its params are.
+			ASTCtxt.ErrorLens[GetErrorParam(f).Name] = GetErrorParam(f).Name + "_maxlen"
 		}
 	}
-}
-
-func MutateAssignDefs(file *ast.File) {
+	if len(ASTCtxt.ErrorFuncs)==0 {
+		return
+	}
 	for _, decl := range file.Decls {
 		switch d := decl.(type) {
 			case *ast.FuncDecl:
 				ASTCtxt.CurrFunc = d
 				if d.Body != nil {
-					MutateBlock(d.Body, MutateAssignDefStmts)
+					MutateBlock(d.Body, MutateErrorStmts)
 				}
 				ASTCtxt.CurrFunc = nil
 		}
 	}
-}
-
-func MutateAssigns(file *ast.File) {
 	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateAssignStmts)
-				}
-				ASTCtxt.CurrFunc = nil
+		if f, is_func := decl.(*ast.FuncDecl); is_func && ASTCtxt.ErrorFuncs[ASTCtxt.TypeInfo.Defs[f.Name]] {
+			result := GetErrorResult(f)
+			param := new(ast.Field)
+			param.Names = append(param.Names, GetErrorParam(f))
+			param.Type = PtrizeExpr(result.Type)
+			f.Type.Params.List = append(f.Type.Params.List, AddErrorLenParams([]*ast.Field{param})...)
+			f.Type.Results = nil
+			if len(result.Names) > 0 && f.Body != nil {
+				/// a named error is a local that's copied out on return.
+				f.Body.List = InsertStmt(f.Body.List, 0, MakeTypedVarDecl(result.Names, result.Type))
+			}
 		}
 	}
 }
 
-func MutateRanges(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateRangeStmts)
-				}
-				ASTCtxt.CurrFunc = nil
-				ASTCtxt.RangeIter = 0
-		}
+func IsErrorType(t types.Type) bool {
+	return t != nil && types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+/// the result of a function that only returns an error.
+func GetErrorResult(f *ast.FuncDecl) *ast.Field {
+	if results := f.Type.Results; results != nil && len(results.List)==1 && len(results.List[0].Names) < 2 && IsErrorType(ASTCtxt.TypeInfo.TypeOf(results.List[0].Type)) {
+		return results.List[0]
 	}
+	return nil
 }
 
-func MutateNoRetCalls(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateNoRetCallStmts)
-				}
-				ASTCtxt.CurrFunc = nil
+func GetErrorParam(f *ast.FuncDecl) *ast.Ident {
+	return ast.NewIdent(f.Name.Name + "_param0")
+}
+
+/// an unsized param has no 'sizeof', so each error param is followed by the size of the caller's buffer.
+func AddErrorLenParams(params []*ast.Field) []*ast.Field {
+	new_params := make([]*ast.Field, 0, len(params))
+	for _, param := range params {
+		new_params = append(new_params, param)
+		if star, is_ptr := param.Type.(*ast.StarExpr); !is_ptr || !IsErrorType(ASTCtxt.TypeInfo.TypeOf(star.X)) {
+			continue
+		}
+		maxlen := new(ast.Field)
+		for _, name := range param.Names {
+			ASTCtxt.ErrorLens[name.Name] = name.Name + "_maxlen"
+			maxlen.Names = append(maxlen.Names, ast.NewIdent(name.Name + "_maxlen"))
 		}
+		maxlen.Type = ast.NewIdent("int")
+		ASTCtxt.TypeInfo.Types[maxlen.Type] = types.TypeAndValue{Type: types.Typ[types.Int]}
+		new_params = append(new_params, maxlen)
 	}
+	return new_params
 }
 
-func NameAnonFuncs(file *ast.File) {
-	/**
-	 * Function Literals can be represented in different ways:
-	 * 
-	 * f := func(params){
-	 *     code
-	 * }
-	 * f(args)
-	 * 
-	 * OR
-	 * 
-	 * func(params){
-	 *     code
-	 * }(args)
-	 */
-	ASTCtxt.NewDecls = make([]ast.Decl, 0)
-	ASTCtxt.FuncMap = make(map[string]*ast.FuncDecl)
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.FuncMap[d.Name.Name] = d
-		}
-		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, decl)
+/// the size of an error buffer given to a function, an error param passes on the size it was given.
+func MakeErrorLen(err ast.Expr) ast.Expr {
+	if star, is_ptr := err.(*ast.StarExpr); is_ptr {
+		err = star.X
 	}
-	
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateFuncLit)
-				}
-				ASTCtxt.CurrFunc = nil
+	if iden, is_ident := err.(*ast.Ident); is_ident {
+		if maxlen, found := ASTCtxt.ErrorLens[iden.Name]; found {
+			return ast.NewIdent(maxlen)
 		}
 	}
-	if len(file.Decls) < len(ASTCtxt.NewDecls) {
-		file.Decls = ASTCtxt.NewDecls
+	return MakeCall("sizeof", err)
+}
+
+/// checks if a call is to a function of the plugin that takes the size of its error buffers.
+func IsErrorLenCall(call *ast.CallExpr) bool {
+	switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			return ASTCtxt.ErrorLenFuncs[ASTCtxt.TypeInfo.ObjectOf(fn)]
+		case *ast.SelectorExpr:
+			return ASTCtxt.ErrorLenFuncs[ASTCtxt.TypeInfo.ObjectOf(fn.Sel)]
 	}
-	ASTCtxt.NewDecls = nil
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.FuncMap[d.Name.Name] = d
-		}
+	return false
+}
+
+/// checks if an expression is a call to a function that only returns an error.
+func IsErrorCall(e ast.Expr) bool {
+	call, is_call := e.(*ast.CallExpr)
+	if !is_call {
+		return false
 	}
+	switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			return ASTCtxt.ErrorFuncs[ASTCtxt.TypeInfo.ObjectOf(fn)]
+		case *ast.SelectorExpr:
+			return ASTCtxt.ErrorFuncs[ASTCtxt.TypeInfo.ObjectOf(fn.Sel)]
+	}
+	return false
 }
 
-func MutateBlock(b *ast.BlockStmt, mutator StmtMutator) {
-	for i, stmt := range b.List {
-		mutator(&b.List, i, stmt, MutateBlock)
+func HasErrorCall(node ast.Node) bool {
+	if node==nil {
+		return false
 	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if e, is_expr := n.(ast.Expr); is_expr && IsErrorCall(e) {
+			found = true
+		}
+		return !found
+	})
+	return found
 }
 
-func MutateRetStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+/// f(args) => f(args, &err, sizeof(err))
+func MakeErrorCall(call *ast.CallExpr, err ast.Expr) *ast.ExprStmt {
+	call.Args = append(call.Args, MakeReference(err), MakeErrorLen(err))
+	return MakeExprStmt(call)
+}
+
+/// declares a new error to pass to a function that only returns an error.
+func MakeErrorTemp() (*ast.Ident, *ast.DeclStmt) {
+	tmp := MakeTypedIdent(fmt.Sprintf("fn_temp%d", ASTCtxt.TmpVar), types.Universe.Lookup("error").Type())
+	ASTCtxt.TmpVar++
+	return tmp, MakeTypedVarDecl([]*ast.Ident{tmp}, ast.NewIdent("error"))
+}
+
+func MutateErrorStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
 	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateRetStmts)
+			bm(n, MutateErrorStmts)
 		
 		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateRetStmts(owner_list, index, n.Init, bm)
+			if n.Init != nil && HasErrorCall(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateErrorStmts)
+				return
 			}
-			if n.Post != nil {
-				MutateRetStmts(owner_list, index, n.Post, bm)
+			if HasErrorCall(n.Cond) || HasErrorCall(n.Post) {
+				PrintSrcGoErr(n.Pos(), "Functions that return an error can't be called in a for-loop condition or post statement.")
 			}
-			bm(n.Body, MutateRetStmts)
+			bm(n.Body, MutateErrorStmts)
 		
 		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateRetStmts(owner_list, index, n.Init, bm)
-			}
-			bm(n.Body, MutateRetStmts)
-			if n.Else != nil {
-				MutateRetStmts(owner_list, index, n.Else, bm)
-			}
-		
-		case *ast.ReturnStmt:
-			index := FindStmt(*owner_list, s)
-			res_len := len(n.Results)
-			func_calls := make([]*ast.CallExpr, 0)
-			for i := range n.Results {
-				switch call := n.Results[i].(type) {
-					case *ast.CallExpr:
-						func_calls = append(func_calls, call)
-					default:
-						func_calls = append(func_calls, nil)
-				}
+			if n.Init != nil && HasErrorCall(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateErrorStmts)
+				return
 			}
-			
-			res := len(n.Results)
-			for i:=1; i<res; i++ {
-				call := func_calls[i]
-				if call != nil && IsFuncPtr(call.Fun) {
-					calls := ExpandFuncPtrCalls(call, []ast.Expr{ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i))}, nil)
-					for i:=len(calls)-1; i>=0; i-- {
-						*owner_list = InsertStmt(*owner_list, index, calls[i])
-					}
-				} else {
-					ptr_deref := PtrizeExpr(ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i)))
-					assign := MakeAssign(false)
-					assign.Lhs = append(assign.Lhs, ptr_deref)
-					assign.Rhs = append(assign.Rhs, n.Results[i])
-					*owner_list = InsertStmt(*owner_list, index, assign)
-					index++
-				}
+			MutateErrorExpr(&n.Cond, owner_list, n)
+			bm(n.Body, MutateErrorStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && (HasErrorCall(else_if.Init) || HasErrorCall(else_if.Cond)) {
+				else_block := new(ast.BlockStmt)
+				else_block.List = append(else_block.List, else_if)
+				n.Else = else_block
 			}
-			
-			if res_len > 1 {
-				n.Results = n.Results[:1]
-				MutateRetStmts(owner_list, index, s, bm)
-			} else if res_len==1 {
-				/// check for function call.
-				ast.Inspect(n.Results[0], func(node ast.Node) bool {
-					if node != nil {
-						switch call := node.(type) {
-							case *ast.CallExpr:
-								if IsFuncPtr(call.Fun) {
-									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-									ASTCtxt.TmpVar++
-									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Results[0], nil)
-									calls := ExpandFuncPtrCalls(call, []ast.Expr{ret_tmp}, nil)
-									calls = InsertStmt(calls, 0, declstmt)
-									for i:=len(calls)-1; i>=0; i-- {
-										*owner_list = InsertStmt(*owner_list, index, calls[i])
-									}
-									n.Results[0] = ret_tmp
-								} else if fn, found := ASTCtxt.FuncMap[GetFuncName(call.Fun)]; found {
-									if arg_count, param_count := len(call.Args), len(fn.Type.Params.List); arg_count < param_count {
-										for _, param := range ASTCtxt.CurrFunc.Type.Params.List {
-											for _, name := range param.Names {
-												if strings.HasPrefix(name.Name, ASTCtxt.CurrFunc.Name.Name + "_param") {
-													call.Args = append(call.Args, name)
-												}
-											}
-										}
-									}
-								}
-						}
-					}
-					return true
-				})
+			if n.Else != nil {
+				MutateErrorStmts(owner_list, index, n.Else, bm)
 			}
 		
 		case *ast.SwitchStmt:
-			MutateRetStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateRetStmts)
+			if n.Init != nil && HasErrorCall(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateErrorStmts)
+				return
+			}
+			MutateErrorExpr(&n.Tag, owner_list, n)
+			bm(n.Body, MutateErrorStmts)
 		
 		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateRetStmts(&n.Body, i, stmt, bm)
+			for j := range n.List {
+				MutateErrorExpr(&n.List[j], nil, nil)
 			}
+			MutateStmtList(&n.Body, MutateErrorStmts)
 		
 		case *ast.RangeStmt:
-			bm(n.Body, MutateRetStmts)
-	}
-}
-
-func MutateAssignDefStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
-		case *ast.BlockStmt:
-			bm(n, MutateAssignDefStmts)
+			MutateErrorExpr(&n.X, owner_list, n)
+			bm(n.Body, MutateErrorStmts)
 		
-		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+		case *ast.ReturnStmt:
+			result := GetErrorResult(ASTCtxt.CurrFunc)
+			if result==nil || len(n.Results) > 1 {
+				for i := range n.Results {
+					MutateErrorExpr(&n.Results[i], owner_list, n)
+				}
+				return
+			} else if len(n.Results)==0 {
+				if len(result.Names)==0 {
+					return
+				}
+				/// naked return of a named error.
+				n.Results = append(n.Results, ast.NewIdent(result.Names[0].Name))
 			}
-			if n.Post != nil {
-				MutateAssignDefStmts(owner_list, index, n.Post, bm)
-			}
-			bm(n.Body, MutateAssignDefStmts)
-		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateAssignDefStmts(owner_list, index, n.Init, bm)
-			}
-			bm(n.Body, MutateAssignDefStmts)
-			if n.Else != nil {
-				MutateAssignDefStmts(owner_list, index, n.Else, bm)
+			/// return err => *f_param0 = err; return
+			param := GetErrorParam(ASTCtxt.CurrFunc)
+			var set ast.Stmt
+			if IsErrorCall(n.Results[0]) {
+				call := n.Results[0].(*ast.CallExpr)
+				for i := range call.Args {
+					MutateErrorExpr(&call.Args[i], owner_list, n)
+				}
+				call.Args = append(call.Args, param, MakeErrorLen(param))
+				set = MakeExprStmt(call)
+			} else {
+				MutateErrorExpr(&n.Results[0], owner_list, n)
+				assign := MakeAssign(false)
+				assign.Lhs = append(assign.Lhs, PtrizeExpr(param))
+				assign.Rhs = append(assign.Rhs, n.Results[0])
+				set = assign
 			}
+			n.Results = nil
+			*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n), set)
 		
-		case *ast.SwitchStmt:
-			MutateAssignDefStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateAssignDefStmts)
-		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateAssignDefStmts(&n.Body, i, stmt, bm)
+		case *ast.ExprStmt:
+			if IsErrorCall(n.X) {
+				call := n.X.(*ast.CallExpr)
+				for i := range call.Args {
+					MutateErrorExpr(&call.Args[i], owner_list, n)
+				}
+				tmp, decl := MakeErrorTemp()
+				*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n), decl)
+				call.Args = append(call.Args, MakeReference(tmp), MakeErrorLen(tmp))
+				return
 			}
-		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateAssignDefStmts)
+			MutateErrorExpr(&n.X, owner_list, n)
 		
 		case *ast.AssignStmt:
-			/**
-			 * a,b,c := f()
-			 * 
-			 * if not func ptr:
-			 *     var a,b,c type
-			 *     a = f(&b, &c)
-			 * 
-			 * if func ptr:
-			 *     var a,b,c type
-			 *     Call_StartFunction(nil, f)
-			 *     Call_PushCellRef(&b)
-			 *     Call_PushCellRef(&c)
-			 *     Call_Finish(&a)
-			 */
-			left_len, rite_len := len(n.Lhs), len(n.Rhs)
-			if rite_len==1 && left_len >= rite_len {
-				switch e := n.Rhs[0].(type) {
-					case *ast.CallExpr:
-						if iden, is_ident := e.Fun.(*ast.Ident); is_ident && iden.Name=="make" {
-							arg_len := len(e.Args)
-							switch {
-								case arg_len > 2:
-									PrintSrcGoErr(n.TokPos, "'make' has too many arguments.")
-								case arg_len < 2:
-									PrintSrcGoErr(n.TokPos, "'make' has too few arguments.")
-								case left_len > 1:
-									PrintSrcGoErr(n.TokPos, "'make' only returns one value.")
-							}
-							return
-						}
-						/// a func call returning multiple items as a decl + init.
-						switch n.Tok {
-							case token.DEFINE:
-								decl_stmt := new(ast.DeclStmt)
-								gen_decl := new(ast.GenDecl)
-								gen_decl.Tok = token.VAR
-								
-								/// first we get each name of a var and then map them to a type.
-								var_map := make(map[types.Type][]ast.Expr)
-								for _, e := range n.Lhs {
-									if type_expr := ASTCtxt.TypeInfo.TypeOf(e); type_expr != nil {
-										var_map[type_expr] = append(var_map[type_expr], e)
-									} else {
-										PrintSrcGoErr(n.TokPos, "Failed to expand assignment statement.")
-									}
-								}
-								
-								for key, val := range var_map {
-									val_spec := new(ast.ValueSpec)
-									for _, name := range val {
-										val_spec.Names = append(val_spec.Names, name.(*ast.Ident))
-									}
-									val_spec.Type = TypeToASTExpr(key)
-									gen_decl.Specs = append(gen_decl.Specs, val_spec)
-								}
-								
-								decl_stmt.Decl = gen_decl
-								*owner_list = InsertStmt(*owner_list, 0, decl_stmt)
-								n.Tok = token.ASSIGN
-						}
+			if len(n.Lhs)==1 && len(n.Rhs)==1 && IsErrorCall(n.Rhs[0]) {
+				/// err := f() => var err error; f(&err)
+				call := n.Rhs[0].(*ast.CallExpr)
+				for i := range call.Args {
+					MutateErrorExpr(&call.Args[i], owner_list, n)
 				}
+				var new_stmts []ast.Stmt
+				err := n.Lhs[0]
+				if iden, is_ident := err.(*ast.Ident); is_ident && iden.Name=="_" {
+					tmp, decl := MakeErrorTemp()
+					new_stmts = append(new_stmts, decl)
+					err = tmp
+				} else if is_ident && n.Tok==token.DEFINE {
+					new_stmts = append(new_stmts, MakeTypedVarDecl([]*ast.Ident{iden}, ast.NewIdent("error")))
+				}
+				new_stmts = append(new_stmts, MakeErrorCall(call, err))
+				ReplaceStmt(owner_list, n, new_stmts...)
+				return
 			}
-	}
-}
-
-func MutateAssignStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
-		case *ast.BlockStmt:
-			bm(n, MutateAssignStmts)
-		
-		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateAssignStmts(owner_list, index, n.Init, bm)
-			}
-			if n.Post != nil {
-				MutateAssignStmts(owner_list, index, n.Post, bm)
-			}
-			bm(n.Body, MutateAssignStmts)
-		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateAssignStmts(owner_list, index, n.Init, bm)
+			for i := range n.Lhs {
+				MutateErrorExpr(&n.Lhs[i], owner_list, n)
 			}
-			bm(n.Body, MutateAssignStmts)
-			if n.Else != nil {
-				MutateAssignStmts(owner_list, index, n.Else, bm)
+			for i := range n.Rhs {
+				MutateErrorExpr(&n.Rhs[i], owner_list, n)
 			}
 		
-		case *ast.SwitchStmt:
-			MutateAssignStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateAssignStmts)
-		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateAssignStmts(&n.Body, i, stmt, bm)
+		case *ast.DeclStmt:
+			g := n.Decl.(*ast.GenDecl)
+			if g.Tok != token.VAR {
+				return
 			}
-		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateAssignStmts)
-		
-		case *ast.AssignStmt:
-			left_len, rite_len := len(n.Lhs), len(n.Rhs)
-			if rite_len==1 && left_len >= rite_len {
-				switch n.Tok {
-					case token.ASSIGN:
-						switch fn := n.Rhs[0].(type) {
-							case *ast.CallExpr:
-								/// a func call returning multiple items as a decl + init.
-								if IsFuncPtr(fn) {
-									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-									ASTCtxt.TmpVar++
-									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Lhs[0], nil)
-									
-									retvals := make([]ast.Expr, 0)
-									retvals = append(retvals, ret_tmp)
-									for i:=1; i<left_len; i++ {
-										retvals = append(retvals, n.Lhs[i])
-									}
-									calls := ExpandFuncPtrCalls(fn, retvals, nil)
-									calls = InsertStmt(calls, 0, declstmt)
-									for i := len(calls)-1; i>=0; i-- {
-										*owner_list = InsertStmt(*owner_list, index, calls[i])
-									}
-									n.Lhs = n.Lhs[:1]
-									n.Rhs[0] = ret_tmp
-								} else {
-									/// transform the tuple return into a single return + pass by ref.
-									for i:=1; i<left_len; i++ {
-										switch e := n.Lhs[i].(type) {
-											case *ast.Ident:
-												fn.Args = append(fn.Args, MakeReference(e))
-										}
-									}
-									if left_len > 1 {
-										n.Lhs = n.Lhs[:1]
-									}
-								}
-							/*
-							case *ast.IndexExpr:
-								/// value, found = map[str]
-								/// Becomes: found = map.GetValue(str, &value)
-								if typ := ASTCtxt.TypeInfo.TypeOf(fn.X); typ != nil {
-									switch t := typ.(type) {
-										case *types.Map:
-											ret_tmp := ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
-											ASTCtxt.TmpVar++
-											declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.Elem())
-											
-											new_stmts := make([]ast.Stmt, 0)
-											map_access := new(ast.CallExpr)
-											switch elem_typ := t.Elem().(type) {
-												case 
-												map_access.Fun = ast.NewIdent("GetValue")
-											
-											map_access.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
-											map_access.Args = append(Call_StartFunction.Args, x.Fun)
-								}
-							*/
-						}
+			for _, spec := range g.Specs {
+				v := spec.(*ast.ValueSpec)
+				if len(v.Names)==1 && len(v.Values)==1 && IsErrorCall(v.Values[0]) {
+					/// var err = f() => var err error; f(&err)
+					call := v.Values[0].(*ast.CallExpr)
+					for i := range call.Args {
+						MutateErrorExpr(&call.Args[i], owner_list, n)
+					}
+					v.Values, v.Type = nil, ast.NewIdent("error")
+					*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, MakeErrorCall(call, v.Names[0]))
+					continue
+				}
+				for i := range v.Values {
+					MutateErrorExpr(&v.Values[i], owner_list, n)
 				}
 			}
 	}
 }
 
-func MutateRangeStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
-		case *ast.BlockStmt:
-			bm(n, MutateRangeStmts)
-		
-		case *ast.EmptyStmt:
+/// moves calls to functions that only return an error out before 'anchor' and uses their error instead.
+func MutateErrorExpr(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
+			MutateErrorExpr(&n.Y, owner_list, anchor)
 		
-		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateRangeStmts(owner_list, index, n.Init, bm)
+		case *ast.CallExpr:
+			MutateErrorExpr(&n.Fun, owner_list, anchor)
+			for i := range n.Args {
+				MutateErrorExpr(&n.Args[i], owner_list, anchor)
 			}
-			if n.Post != nil {
-				MutateRangeStmts(owner_list, index, n.Post, bm)
+			if !IsErrorCall(n) {
+				return
+			} else if owner_list==nil {
+				PrintSrcGoErr(n.Pos(), "Functions that return an error can't be called here, store the error in a variable first.")
+				return
 			}
-			bm(n.Body, MutateRangeStmts)
+			tmp, decl := MakeErrorTemp()
+			i := FindStmt(*owner_list, anchor)
+			*owner_list = InsertStmt(*owner_list, i, decl)
+			*owner_list = InsertStmt(*owner_list, i+1, MakeErrorCall(n, tmp))
+			*e = tmp
 		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateRangeStmts(owner_list, index, n.Init, bm)
-			}
-			bm(n.Body, MutateRangeStmts)
-			if n.Else != nil {
-				MutateRangeStmts(owner_list, index, n.Else, bm)
+		case *ast.KeyValueExpr:
+			MutateErrorExpr(&n.Value, owner_list, anchor)
+		
+		case *ast.CompositeLit:
+			for i := range n.Elts {
+				MutateErrorExpr(&n.Elts[i], owner_list, anchor)
 			}
 		
-		case *ast.SwitchStmt:
-			MutateRangeStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateRangeStmts)
+		case *ast.IndexExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
+			MutateErrorExpr(&n.Index, owner_list, anchor)
 		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateRangeStmts(&n.Body, i, stmt, bm)
-			}
+		case *ast.ParenExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
 		
-		case *ast.RangeStmt:
-			if n.Key != nil {
-				if iden, ok := n.Key.(*ast.Ident); ok && iden.Name=="_" {
-					n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
-					ASTCtxt.RangeIter++
-				}
-			} else {
-				n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
-				ASTCtxt.RangeIter++
-			}
-			
-			if n.Value != nil {
-				if iden, ok := n.Value.(*ast.Ident); ok && iden.Name=="_" {
-					n.Value = nil
-				} else {
-					switch n.Tok {
-						case token.DEFINE:
-							decl_stmt := MakeVarDecl([]*ast.Ident{n.Value.(*ast.Ident)}, n.Value, nil)
-							n.Body.List = InsertStmt(n.Body.List, 0, decl_stmt)
-							
-							assign := MakeAssign(false)
-							assign.Lhs = append(assign.Lhs, n.Value)
-							
-							get_index := MakeIndex(n.Key, n.X)
-							assign.Rhs = append(assign.Rhs, get_index)
-							
-							n.Body.List = InsertStmt(n.Body.List, 1, assign)
-							n.Value = nil
-					}
-				}
-			}
-			bm(n.Body, MutateRangeStmts)
+		case *ast.SelectorExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
+		
+		case *ast.StarExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
+		
+		case *ast.UnaryExpr:
+			MutateErrorExpr(&n.X, owner_list, anchor)
 	}
 }
 
-func MutateNoRetCallStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
+func MergeRetVals(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch f := n.(type) {
+				case *ast.FuncDecl:
+					ret_params := len(f.Type.Params.List)
+					params := MutateRetTypes(&f.Type.Results, f.Type.Params, f.Name.Name)
+					f.Type.Params.List = append(params[:ret_params:ret_params], AddErrorLenParams(params[ret_params:])...)
+					if len(f.Type.Params.List) > len(params) {
+						ASTCtxt.ErrorLenFuncs[ASTCtxt.TypeInfo.Defs[f.Name]] = true
+					}
+				case *ast.TypeSpec:
+					if t, is_func_type := f.Type.(*ast.FuncType); is_func_type {
+						t.Params.List = MutateRetTypes(&t.Results, t.Params, f.Name.Name)
+					} else if iface, is_iface := f.Type.(*ast.InterfaceType); is_iface {
+						/// the signatures of a typeset.
+						ast.Inspect(iface, func(n ast.Node) bool {
+							if t, is_func_type := n.(*ast.FuncType); is_func_type {
+								t.Params.List = MutateRetTypes(&t.Results, t.Params, f.Name.Name)
+							}
+							return true
+						})
+					}
+			}
+		}
+		return true
+	})
+}
+
+func ChangeRecvrNames(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch f := n.(type) {
+				case *ast.FuncDecl:
+					if f.Recv != nil && f.Recv.List[0].Names != nil && len(f.Recv.List[0].Names) > 0 {
+						recvr := f.Recv.List[0].Names[0].Name
+						/// rename the receiver too so the renamed uses still resolve when type-checking again.
+						f.Recv.List[0].Names[0].Name = "this"
+						ast.Inspect(f.Body, func(n ast.Node) bool {
+							if n != nil {
+								switch i := n.(type) {
+									case *ast.Ident:
+										if recvr==i.Name {
+											i.Name = "this"
+										}
+								}
+							}
+							return true
+						})
+					}
+			}
+		}
+		return true
+	})
+}
+
+func MakeFuncPtrArgCall(arg ast.Expr, by_ref bool, pretyp types.Type) *ast.ExprStmt {
+	var typ types.Type
+	if typ = ASTCtxt.TypeInfo.TypeOf(arg); typ == nil && pretyp != nil {
+		typ = pretyp
+	}
+	switch t := typ.(type) {
+		case *types.Array:
+			switch t.Elem().String() {
+				case "char":
+					Call_PushStringEx := new(ast.CallExpr)
+					Call_PushStringEx.Fun = ast.NewIdent("Call_PushStringEx")
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, arg)
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "1"))
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "2"))
+					
+					Call_PushString_stmt := new(ast.ExprStmt)
+					Call_PushString_stmt.X = Call_PushStringEx
+					return Call_PushString_stmt
+				default:
+					Call_PushArrayEx := new(ast.CallExpr)
+					Call_PushArrayEx.Fun = ast.NewIdent("Call_PushArrayEx")
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, arg)
+					
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+					
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, "1"))
+					
+					Call_PushArrayEx_stmt := new(ast.ExprStmt)
+					Call_PushArrayEx_stmt.X = Call_PushArrayEx
+					return Call_PushArrayEx_stmt
+			}
+		case *types.Pointer:
+			switch t.Elem().String() {
+				case "float":
+					Call_PushFloatRef := new(ast.CallExpr)
+					Call_PushFloatRef.Fun = ast.NewIdent("Call_PushFloatRef")
+					if by_ref {
+						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, MakeReference(arg))
+					} else {
+						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, arg)
+					}
+					
+					Call_PushFloatRef_stmt := new(ast.ExprStmt)
+					Call_PushFloatRef_stmt.X = Call_PushFloatRef
+					return Call_PushFloatRef_stmt
+				default:
+					Call_PushCellRef := new(ast.CallExpr)
+					Call_PushCellRef.Fun = ast.NewIdent("Call_PushCellRef")
+					if by_ref {
+						Call_PushCellRef.Args = append(Call_PushCellRef.Args, MakeReference(arg))
+					} else {
+						Call_PushCellRef.Args = append(Call_PushCellRef.Args, arg)
+					}
+					
+					Call_PushCellRef_stmt := new(ast.ExprStmt)
+					Call_PushCellRef_stmt.X = Call_PushCellRef
+					return Call_PushCellRef_stmt
+			}
+		case *types.Basic:
+			switch t.Name() {
+				case "string":
+					Call_PushString := new(ast.CallExpr)
+					Call_PushString.Fun = ast.NewIdent("Call_PushString")
+					Call_PushString.Args = append(Call_PushString.Args, arg)
+					
+					Call_PushString_stmt := new(ast.ExprStmt)
+					Call_PushString_stmt.X = Call_PushString
+					return Call_PushString_stmt
+				case "float", "float32":
+					Call_PushFloat := new(ast.CallExpr)
+					if by_ref {
+						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloatRef")
+						Call_PushFloat.Args = append(Call_PushFloat.Args, MakeReference(arg))
+					} else {
+						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloat")
+						Call_PushFloat.Args = append(Call_PushFloat.Args, arg)
+					}
+					Call_PushFloat_stmt := new(ast.ExprStmt)
+					Call_PushFloat_stmt.X = Call_PushFloat
+					return Call_PushFloat_stmt
+				default:
+					Call_PushCell := new(ast.CallExpr)
+					if by_ref {
+						Call_PushCell.Fun = ast.NewIdent("Call_PushCellRef")
+						Call_PushCell.Args = append(Call_PushCell.Args, MakeReference(arg))
+					} else {
+						Call_PushCell.Fun = ast.NewIdent("Call_PushCell")
+						Call_PushCell.Args = append(Call_PushCell.Args, arg)
+					}
+					Call_PushCell_stmt := new(ast.ExprStmt)
+					Call_PushCell_stmt.X = Call_PushCell
+					return Call_PushCell_stmt
+			}
+	}
+	return nil
+}
+
+func ExpandFuncPtrCalls(x *ast.CallExpr, retvals []ast.Expr, retTypes []types.Type) []ast.Stmt {
+	new_stmts := make([]ast.Stmt, 0)
+	Call_StartFunction := new(ast.CallExpr)
+	Call_StartFunction.Fun = ast.NewIdent("Call_StartFunction")
+	Call_StartFunction.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
+	Call_StartFunction.Args = append(Call_StartFunction.Args, x.Fun)
+	
+	Call_StartFunction_stmt := new(ast.ExprStmt)
+	Call_StartFunction_stmt.X = Call_StartFunction
+	new_stmts = append(new_stmts, Call_StartFunction_stmt)
+	
+	for _, arg := range x.Args {
+		if func_call := MakeFuncPtrArgCall(arg, false, nil); func_call != nil {
+			new_stmts = append(new_stmts, func_call)
+		}
+	}
+	
+	if retvals != nil && len(retvals) > 0 {
+		rets := len(retvals)
+		for i:=1; i<rets; i++ {
+			_, is_ptr := retvals[i].(*ast.StarExpr)
+			func_call := MakeFuncPtrArgCall(retvals[i], !is_ptr, nil)
+			if func_call != nil {
+				new_stmts = append(new_stmts, func_call)
+			} else if retTypes != nil {
+				func_call = MakeFuncPtrArgCall(retvals[i], !is_ptr, retTypes[i])
+				new_stmts = append(new_stmts, func_call)
+			}
+		}
+		Call_Finish := new(ast.CallExpr)
+		Call_Finish.Fun = ast.NewIdent("Call_Finish")
+		
+		var retval_type ast.Expr
+		if field, _ := FindParam(ASTCtxt.CurrFunc, retvals[0].(*ast.Ident).Name); field != nil {
+			retval_type = field.Type
+		}
+		
+		if _, is_ptr := retval_type.(*ast.StarExpr); is_ptr {
+			Call_Finish.Args = append(Call_Finish.Args, retvals[0])
+		} else {
+			Call_Finish.Args = append(Call_Finish.Args, MakeReference(retvals[0]))
+		}
+		
+		Call_Finish_stmt := new(ast.ExprStmt)
+		Call_Finish_stmt.X = Call_Finish
+		new_stmts = append(new_stmts, Call_Finish_stmt)
+	} else {
+		Call_Finish := new(ast.CallExpr)
+		Call_Finish.Fun = ast.NewIdent("Call_Finish")
+		Call_Finish.Args = nil
+		
+		Call_Finish_stmt := new(ast.ExprStmt)
+		Call_Finish_stmt.X = Call_Finish
+		new_stmts = append(new_stmts, Call_Finish_stmt)
+	}
+	return new_stmts
+}
+
+
+type (
+	StmtMutator  func(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator)
+	BlockMutator func(b *ast.BlockStmt, mutator StmtMutator)
+)
+
+func MutateRets(file *ast.File) {
+	/** Case Studies of Returning statements to transform:
+	 * 
+	 * return m3() /// func m3() (type, type, type)
+	 * 
+	 * return int, float, m1() /// func m1() type
+	 */
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateRetStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateAssignDefs(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateAssignDefStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateAssigns(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateAssignStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateRanges(file *ast.File) {
+	ASTCtxt.StrBufferSizes = make(map[*ast.Ident]ast.Expr)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateRangeStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+				ASTCtxt.RangeIter = 0
+		}
+	}
+}
+
+func MutateNoRetCalls(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateNoRetCallStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func NameAnonFuncs(file *ast.File) {
+	/**
+	 * Function Literals can be represented in different ways:
+	 * 
+	 * f := func(params){
+	 *     code
+	 * }
+	 * f(args)
+	 * 
+	 * OR
+	 * 
+	 * func(params){
+	 *     code
+	 * }(args)
+	 */
+	ASTCtxt.NewDecls = make([]ast.Decl, 0)
+	ASTCtxt.FuncMap = make(map[string]*ast.FuncDecl)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.FuncMap[d.Name.Name] = d
+		}
+		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, decl)
+	}
+	
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateFuncLit)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+	if len(file.Decls) < len(ASTCtxt.NewDecls) {
+		file.Decls = ASTCtxt.NewDecls
+	}
+	ASTCtxt.NewDecls = nil
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.FuncMap[d.Name.Name] = d
+		}
+	}
+}
+
+func MutateBlock(b *ast.BlockStmt, mutator StmtMutator) {
+	MutateStmtList(&b.List, mutator)
+}
+
+/// mutators can insert or replace statements, so skip over anything they've generated.
+func MutateStmtList(list *[]ast.Stmt, mutator StmtMutator) {
+	for i := 0; i < len(*list); i++ {
+		old_len := len(*list)
+		mutator(list, i, (*list)[i], MutateBlock)
+		i += len(*list) - old_len
+	}
+}
+
+func MutateRetStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateRetStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateRetStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateRetStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateRetStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateRetStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateRetStmts)
+			if n.Else != nil {
+				MutateRetStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.ReturnStmt:
+			index := FindStmt(*owner_list, s)
+			res_len := len(n.Results)
+			func_calls := make([]*ast.CallExpr, 0)
+			for i := range n.Results {
+				switch call := n.Results[i].(type) {
+					case *ast.CallExpr:
+						func_calls = append(func_calls, call)
+					default:
+						func_calls = append(func_calls, nil)
+				}
+			}
+			
+			res := len(n.Results)
+			for i:=1; i<res; i++ {
+				call := func_calls[i]
+				if call != nil && IsFuncPtr(call.Fun) {
+					calls := ExpandFuncPtrCalls(call, []ast.Expr{ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i))}, nil)
+					for i:=len(calls)-1; i>=0; i-- {
+						*owner_list = InsertStmt(*owner_list, index, calls[i])
+					}
+				} else {
+					ptr_deref := PtrizeExpr(ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i)))
+					assign := MakeAssign(false)
+					assign.Lhs = append(assign.Lhs, ptr_deref)
+					assign.Rhs = append(assign.Rhs, n.Results[i])
+					*owner_list = InsertStmt(*owner_list, index, assign)
+					index++
+				}
+			}
+			
+			if res_len > 1 {
+				n.Results = n.Results[:1]
+				MutateRetStmts(owner_list, index, s, bm)
+			} else if res_len==1 {
+				/// check for function call.
+				ast.Inspect(n.Results[0], func(node ast.Node) bool {
+					if node != nil {
+						switch call := node.(type) {
+							case *ast.CallExpr:
+								if IsFuncPtr(call.Fun) {
+									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+									ASTCtxt.TmpVar++
+									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Results[0], nil)
+									calls := ExpandFuncPtrCalls(call, []ast.Expr{ret_tmp}, nil)
+									calls = InsertStmt(calls, 0, declstmt)
+									for i:=len(calls)-1; i>=0; i-- {
+										*owner_list = InsertStmt(*owner_list, index, calls[i])
+									}
+									n.Results[0] = ret_tmp
+								} else if fn, found := ASTCtxt.FuncMap[GetFuncName(call.Fun)]; found {
+									if arg_count, param_count := len(call.Args), len(fn.Type.Params.List); arg_count < param_count {
+										for _, param := range ASTCtxt.CurrFunc.Type.Params.List {
+											for _, name := range param.Names {
+												if strings.HasPrefix(name.Name, ASTCtxt.CurrFunc.Name.Name + "_param") {
+													call.Args = append(call.Args, name)
+												}
+											}
+										}
+									}
+								}
+						}
+					}
+					return true
+				})
+			}
+		
+		case *ast.SwitchStmt:
+			MutateRetStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateRetStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateRetStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateRetStmts)
+	}
+}
+
+func MutateAssignDefStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateAssignDefStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateAssignDefStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateAssignDefStmts)
+			if n.Else != nil {
+				MutateAssignDefStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateAssignDefStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.AssignStmt:
+			/**
+			 * a,b,c := f()
+			 * 
+			 * if not func ptr:
+			 *     var a,b,c type
+			 *     a = f(&b, &c)
+			 * 
+			 * if func ptr:
+			 *     var a,b,c type
+			 *     Call_StartFunction(nil, f)
+			 *     Call_PushCellRef(&b)
+			 *     Call_PushCellRef(&c)
+			 *     Call_Finish(&a)
+			 */
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if rite_len==1 && left_len >= rite_len {
+				switch e := n.Rhs[0].(type) {
+					case *ast.CallExpr:
+						if iden, is_ident := e.Fun.(*ast.Ident); is_ident && iden.Name=="make" {
+							arg_len := len(e.Args)
+							switch {
+								case arg_len > 2:
+									PrintSrcGoErr(n.TokPos, "'make' has too many arguments.")
+								case arg_len > 0 && GetMapType(e.Args[0]) != nil:
+									/// maps become 'new StringMap()', any size hint is ignored.
+								case arg_len < 2:
+									PrintSrcGoErr(n.TokPos, "'make' has too few arguments.")
+								case left_len > 1:
+									PrintSrcGoErr(n.TokPos, "'make' only returns one value.")
+							}
+							return
+						}
+						/// a func call returning multiple items as a decl + init.
+						switch n.Tok {
+							case token.DEFINE:
+								decl_stmt := new(ast.DeclStmt)
+								gen_decl := new(ast.GenDecl)
+								gen_decl.Tok = token.VAR
+								
+								/// first we get each name of a var and then map them to a type.
+								var_map := make(map[types.Type][]ast.Expr)
+								/// the types in the order of their first var so the decls come out the same every time.
+								var var_types []types.Type
+								for _, e := range n.Lhs {
+									if type_expr := ASTCtxt.TypeInfo.TypeOf(e); type_expr != nil {
+										if _, found := var_map[type_expr]; !found {
+											var_types = append(var_types, type_expr)
+										}
+										var_map[type_expr] = append(var_map[type_expr], e)
+									} else {
+										PrintSrcGoErr(n.TokPos, "Failed to expand assignment statement.")
+									}
+								}
+								
+								for _, key := range var_types {
+									val_spec := new(ast.ValueSpec)
+									for _, name := range var_map[key] {
+										val_spec.Names = append(val_spec.Names, name.(*ast.Ident))
+									}
+									val_spec.Type = TypeToASTExpr(key)
+									gen_decl.Specs = append(gen_decl.Specs, val_spec)
+								}
+								
+								decl_stmt.Decl = gen_decl
+								*owner_list = InsertStmt(*owner_list, 0, decl_stmt)
+								n.Tok = token.ASSIGN
+						}
+				}
+			}
+	}
+}
+
+func MutateAssignStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateAssignStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateAssignStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateAssignStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateAssignStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateAssignStmts)
+			if n.Else != nil {
+				MutateAssignStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateAssignStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateAssignStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.AssignStmt:
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if rite_len==1 && left_len >= rite_len {
+				switch n.Tok {
+					case token.ASSIGN:
+						switch fn := n.Rhs[0].(type) {
+							case *ast.CallExpr:
+								/// a func call returning multiple items as a decl + init.
+								if GetFmtFunc(fn) != "" {
+									if left_len > 1 {
+										PrintSrcGoErr(n.TokPos, "fmt calls are lowered to natives, they don't return a count and error.")
+									}
+								} else if IsFuncPtr(fn) {
+									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+									ASTCtxt.TmpVar++
+									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Lhs[0], nil)
+									
+									retvals := make([]ast.Expr, 0)
+									retvals = append(retvals, ret_tmp)
+									for i:=1; i<left_len; i++ {
+										retvals = append(retvals, n.Lhs[i])
+									}
+									calls := ExpandFuncPtrCalls(fn, retvals, nil)
+									calls = InsertStmt(calls, 0, declstmt)
+									for i := len(calls)-1; i>=0; i-- {
+										*owner_list = InsertStmt(*owner_list, index, calls[i])
+									}
+									n.Lhs = n.Lhs[:1]
+									n.Rhs[0] = ret_tmp
+								} else {
+									/// transform the tuple return into a single return + pass by ref.
+									for i:=1; i<left_len; i++ {
+										switch e := n.Lhs[i].(type) {
+											case *ast.Ident:
+												fn.Args = append(fn.Args, MakeReference(e))
+												if IsErrorType(ASTCtxt.TypeInfo.TypeOf(e)) && IsErrorLenCall(fn) {
+													fn.Args = append(fn.Args, MakeErrorLen(e))
+												}
+										}
+									}
+									if left_len > 1 {
+										n.Lhs = n.Lhs[:1]
+									}
+								}
+							/*
+							case *ast.IndexExpr:
+								/// value, found = map[str]
+								/// Becomes: found = map.GetValue(str, &value)
+								if typ := ASTCtxt.TypeInfo.TypeOf(fn.X); typ != nil {
+									switch t := typ.(type) {
+										case *types.Map:
+											ret_tmp := ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
+											ASTCtxt.TmpVar++
+											declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.Elem())
+											
+											new_stmts := make([]ast.Stmt, 0)
+											map_access := new(ast.CallExpr)
+											switch elem_typ := t.Elem().(type) {
+												case 
+												map_access.Fun = ast.NewIdent("GetValue")
+											
+											map_access.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
+											map_access.Args = append(Call_StartFunction.Args, x.Fun)
+								}
+							*/
+						}
+				}
+			}
+	}
+}
+
+func MutateRangeStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateRangeStmts)
+		
+		case *ast.EmptyStmt:
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateRangeStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateRangeStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateRangeStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateRangeStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateRangeStmts)
+			if n.Else != nil {
+				MutateRangeStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateRangeStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateRangeStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateRangeStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			if map_type := GetMapType(n.X); map_type != nil {
+				if n.Tok != token.DEFINE && (n.Key != nil || n.Value != nil) {
+					PrintSrcGoErr(n.Pos(), "Ranging over a map requires declaring the key and value with ':='.")
+					return
+				}
+				MutateMapRange(owner_list, n, map_type)
+				bm(n.Body, MutateRangeStmts)
+				return
+			}
+			
+			if n.Key != nil {
+				if iden, ok := n.Key.(*ast.Ident); ok && iden.Name=="_" {
+					n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
+					ASTCtxt.RangeIter++
+				}
+			} else {
+				n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
+				ASTCtxt.RangeIter++
+			}
+			
+			if n.Value != nil {
+				if iden, ok := n.Value.(*ast.Ident); ok && iden.Name=="_" {
+					n.Value = nil
+				} else {
+					switch n.Tok {
+						case token.DEFINE:
+							decl_stmt := MakeVarDecl([]*ast.Ident{n.Value.(*ast.Ident)}, n.Value, nil)
+							n.Body.List = InsertStmt(n.Body.List, 0, decl_stmt)
+							
+							assign := MakeAssign(false)
+							assign.Lhs = append(assign.Lhs, n.Value)
+							
+							get_index := MakeIndex(n.Key, n.X)
+							assign.Rhs = append(assign.Rhs, get_index)
+							
+							n.Body.List = InsertStmt(n.Body.List, 1, assign)
+							n.Value = nil
+					}
+				}
+			}
+			bm(n.Body, MutateRangeStmts)
+	}
+}
+
+/**
+ * Ranging over a map iterates a snapshot of its keys, the snapshot is deleted after the loop and before any return inside it.
+ * 
+ * for key, val := range m {}
+ * 
+ * Becomes:
+ * {
+ *     var snap StringMapSnapshot = m.Snapshot()
+ *     for iter := 0; iter < snap.Length; iter++ {
+ *         var keylen int = snap.KeyBufferSize(iter)
+ *         var key string   /// char[] key = new char[keylen]
+ *         snap.GetKey(iter, key, keylen)
+ *         var val T
+ *         m.GetValue(key, &val)
+ *     }
+ *     delete(snap)
+ * }
+ */
+func MutateMapRange(owner_list *[]ast.Stmt, n *ast.RangeStmt, map_type *types.Map) {
+	func_name := ASTCtxt.CurrFunc.Name.Name
+	iter := ast.NewIdent(fmt.Sprintf("%s_iter%d", func_name, ASTCtxt.RangeIter))
+	snap := ast.NewIdent(fmt.Sprintf("%s_snap%d", func_name, ASTCtxt.RangeIter))
+	keylen := ast.NewIdent(fmt.Sprintf("%s_keylen%d", func_name, ASTCtxt.RangeIter))
+	key := ast.NewIdent(fmt.Sprintf("%s_key%d", func_name, ASTCtxt.RangeIter))
+	ASTCtxt.RangeIter++
+	
+	if iden, is_ident := n.Key.(*ast.Ident); is_ident && iden.Name != "_" {
+		key = iden
+	}
+	var value *ast.Ident
+	if iden, is_ident := n.Value.(*ast.Ident); is_ident && iden.Name != "_" {
+		value = iden
+	}
+	
+	snap_spec := new(ast.ValueSpec)
+	snap_spec.Names = append(snap_spec.Names, snap)
+	snap_spec.Type = ast.NewIdent("StringMapSnapshot")
+	snap_spec.Values = append(snap_spec.Values, MakeMethodCall(n.X, "Snapshot"))
+	
+	loop := new(ast.ForStmt)
+	init := MakeAssign(true)
+	init.Lhs = append(init.Lhs, iter)
+	init.Rhs = append(init.Rhs, MakeBasicLit(token.INT, "0"))
+	loop.Init = init
+	loop.Cond = MakeBinaryExpr(iter, token.LSS, MakeSelector(snap, "Length"))
+	post := new(ast.IncDecStmt)
+	post.X = iter
+	post.Tok = token.INC
+	loop.Post = post
+	loop.Body = n.Body
+	
+	if n.Key != nil || value != nil {
+		keylen_spec := new(ast.ValueSpec)
+		keylen_spec.Names = append(keylen_spec.Names, keylen)
+		keylen_spec.Type = ast.NewIdent("int")
+		keylen_spec.Values = append(keylen_spec.Values, MakeMethodCall(snap, "KeyBufferSize", iter))
+		
+		/// the key stays a string so it can still be compared, its buffer is made with the key size.
+		key_buf := MakeTypedVarDecl([]*ast.Ident{key}, ast.NewIdent("string"))
+		ASTCtxt.StrBufferSizes[key] = keylen
+		
+		get_key := new(ast.ExprStmt)
+		get_key.X = MakeMethodCall(snap, "GetKey", iter, key, keylen)
+		
+		key_stmts := []ast.Stmt{ MakeDeclStmt(token.VAR, keylen_spec), key_buf, get_key }
+		if value != nil {
+			get_val := new(ast.ExprStmt)
+			get_val.X = MakeMapGet(MakeIndex(key, n.X), map_type, value)
+			key_stmts = append(key_stmts, MakeMapValueDecl(value, map_type.Elem()), get_val)
+		}
+		loop.Body.List = append(key_stmts, loop.Body.List...)
+	}
+	
+	delete_snap := func() ast.Stmt {
+		del := new(ast.ExprStmt)
+		del.X = MakeCall("delete", snap)
+		return del
+	}
+	InsertBeforeReturns(&loop.Body.List, delete_snap)
+	
+	block := new(ast.BlockStmt)
+	block.List = append(block.List, MakeDeclStmt(token.VAR, snap_spec), loop, delete_snap())
+	(*owner_list)[FindStmt(*owner_list, n)] = block
+	n.Body = loop.Body
+}
+
+/// places a new statement from 'make_stmt' before every return inside of the statement list.
+func InsertBeforeReturns(list *[]ast.Stmt, make_stmt func() ast.Stmt) {
+	for i := 0; i < len(*list); i++ {
+		if _, is_ret := (*list)[i].(*ast.ReturnStmt); is_ret {
+			*list = InsertStmt(*list, i, make_stmt())
+			i++
+		} else {
+			InsertBeforeNestedReturns((*list)[i], make_stmt)
+		}
+	}
+}
+
+func InsertBeforeNestedReturns(s ast.Stmt, make_stmt func() ast.Stmt) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			InsertBeforeReturns(&n.List, make_stmt)
+		case *ast.ForStmt:
+			InsertBeforeReturns(&n.Body.List, make_stmt)
+		case *ast.RangeStmt:
+			InsertBeforeReturns(&n.Body.List, make_stmt)
+		case *ast.IfStmt:
+			InsertBeforeReturns(&n.Body.List, make_stmt)
+			if n.Else != nil {
+				InsertBeforeNestedReturns(n.Else, make_stmt)
+			}
+		case *ast.SwitchStmt:
+			InsertBeforeReturns(&n.Body.List, make_stmt)
+		case *ast.CaseClause:
+			InsertBeforeReturns(&n.Body, make_stmt)
+		case *ast.LabeledStmt:
+			InsertBeforeNestedReturns(n.Stmt, make_stmt)
+	}
+}
+
+func MutateNoRetCallStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateNoRetCallStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateNoRetCallStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateNoRetCallStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateNoRetCallStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateNoRetCallStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateNoRetCallStmts)
+			if n.Else != nil {
+				MutateNoRetCallStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateNoRetCallStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateNoRetCallStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateNoRetCallStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateNoRetCallStmts)
+		
+		case *ast.ExprStmt:
+			/// the real fmt package returns '(int, error)', fmt calls are lowered to natives instead.
+			if fn, is_func_call := n.X.(*ast.CallExpr); is_func_call && GetFmtFunc(fn)=="" {
+				if typ := ASTCtxt.TypeInfo.TypeOf(fn); typ != nil {
+					switch t := typ.(type) {
+						case *types.Tuple:
+							extra_args := t.Len()
+							if IsFuncPtr(fn) {
+								if extra_args > 1 {
+									retvals := make([]ast.Expr, 0)
+									rettypes := make([]types.Type, 0)
+									for i:=0; i<extra_args; i++ {
+										ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+										ASTCtxt.TmpVar++
+										declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.At(i).Type())
+										*owner_list = InsertStmt(*owner_list, 0, declstmt)
+										retvals = append(retvals, ret_tmp)
+										rettypes = append(rettypes, t.At(i).Type())
+									}
+									
+									calls := ExpandFuncPtrCalls(fn, retvals, rettypes)
+									for i := len(calls)-1; i>0; i-- {
+										*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
+									}
+									n.X = calls[0].(*ast.ExprStmt).X
+								} else {
+									calls := ExpandFuncPtrCalls(fn, nil, nil)
+									n.X = calls[0].(*ast.ExprStmt).X
+									for i := len(calls)-1; i>0; i-- {
+										*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
+									}
+								}
+							} else {
+								if extra_args > 1 {
+									for i:=1; i<extra_args; i++ {
+										ret_tmp := ast.NewIdent(fmt.Sprintf("fn_temp%d", ASTCtxt.TmpVar))
+										ASTCtxt.TmpVar++
+										declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.At(i).Type())
+										*owner_list = InsertStmt(*owner_list, 0, declstmt)
+										fn.Args = append(fn.Args, MakeReference(ret_tmp))
+										if IsErrorType(t.At(i).Type()) && IsErrorLenCall(fn) {
+											fn.Args = append(fn.Args, MakeErrorLen(ret_tmp))
+										}
+									}
+								}
+							}
+						
+						default:
+							if IsFuncPtr(fn) {
+								ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+								ASTCtxt.TmpVar++
+								declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t)
+								*owner_list = InsertStmt(*owner_list, 0, declstmt)
+								
+								calls := ExpandFuncPtrCalls(fn, []ast.Expr{ret_tmp}, []types.Type{t})
+								n.X = calls[0].(*ast.ExprStmt).X
+								for i := len(calls)-1; i>0; i-- {
+									*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
+								}
+							}
+					}
+				}
+			}
+	}
+}
+
+/// a &^ b ==> a & ^(b) ==> a & ~(b) in sp.
+func MutateAndNotExpr(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch x := n.(type) {
+				case *ast.BinaryExpr:
+					if x.Op==token.AND_NOT {
+						x.Op = token.AND
+						x.Y = MakeBitNotExpr(MakeParenExpr(x.Y))
+					}
+				case *ast.AssignStmt:
+					if x.Tok==token.AND_NOT_ASSIGN {
+						x.Tok = token.AND_ASSIGN
+						for i := range x.Rhs {
+							x.Rhs[i] = MakeBitNotExpr(MakeParenExpr(x.Rhs[i]))
+						}
+					}
+			}
+		}
+		return true
+	})
+}
+
+
+/// func(params){code}(args) => func _srcgo_func#(params){code} ... _srcgo_func#(args)
+func MutateFuncLit(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateFuncLit)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateFuncLit(owner_list, index, n.Init, bm)
+			}
+			if n.Cond != nil {
+				MutateFuncLitExprs(&n.Cond)
+			}
+			if n.Post != nil {
+				MutateFuncLit(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateFuncLit)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateFuncLit(owner_list, index, n.Init, bm)
+			}
+			MutateFuncLitExprs(&n.Cond)
+			bm(n.Body, MutateFuncLit)
+			if n.Else != nil {
+				MutateFuncLit(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateFuncLit(owner_list, index, n.Init, bm)
+			MutateFuncLitExprs(&n.Tag)
+			bm(n.Body, MutateFuncLit)
+		
+		case *ast.CaseClause:
+			for j := range n.List {
+				MutateFuncLitExprs(&n.List[j])
+			}
+			for i, stmt := range n.Body {
+				MutateFuncLit(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			MutateFuncLitExprs(&n.Key)
+			MutateFuncLitExprs(&n.Value)
+			MutateFuncLitExprs(&n.X)
+			bm(n.Body, MutateFuncLit)
+		
+		case *ast.ExprStmt:
+			MutateFuncLitExprs(&n.X)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateFuncLitExprs(&n.Results[i])
+			}
+		
+		case *ast.AssignStmt:
+			for i := range n.Rhs {
+				MutateFuncLitExprs(&n.Rhs[i])
+			}
+			for i := range n.Lhs {
+				MutateFuncLitExprs(&n.Lhs[i])
+			}
+		
+		case *ast.DeclStmt:
+			g := n.Decl.(*ast.GenDecl)
+			for _, d := range g.Specs {
+				switch g.Tok {
+					case token.CONST, token.VAR:
+						v := d.(*ast.ValueSpec)
+						for expr := range v.Values {
+							MutateFuncLitExprs(&v.Values[expr])
+						}
+				}
+			}
+	}
+}
+
+func MutateFuncLitExprs(e *ast.Expr) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			MutateFuncLitExprs(&n.X)
+			MutateFuncLitExprs(&n.Y)
+		
+		case *ast.CallExpr:
+			MutateFuncLitExprs(&n.Fun)
+			for i := range n.Args {
+				MutateFuncLitExprs(&n.Args[i])
+			}
+		
+		case *ast.KeyValueExpr:
+			MutateFuncLitExprs(&n.Key)
+			MutateFuncLitExprs(&n.Value)
+		
+		case *ast.IndexExpr:
+			MutateFuncLitExprs(&n.X)
+			MutateFuncLitExprs(&n.Index)
+		
+		case *ast.UnaryExpr:
+			MutateFuncLitExprs(&n.X)
+		
+		case *ast.FuncLit:
+			if IsClosure(n) {
+				/// captured variables need type info, MutateClosures hoists them after the type-check.
+				return
+			}
+			tmp_func_name := ast.NewIdent(fmt.Sprintf("SrcGoTmpFunc%d", ASTCtxt.TmpFunc))
+			ASTCtxt.TmpFunc++
+			fn_decl := new(ast.FuncDecl)
+			fn_decl.Name = tmp_func_name
+			fn_decl.Type = n.Type
+			n.Type = nil
+			fn_decl.Body = n.Body
+			n.Body = nil
+			*e = tmp_func_name
+			
+			ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+	}
+}
+
+/// checks if a function literal uses local variables declared outside of it.
+func IsClosure(lit *ast.FuncLit) bool {
+	if ASTCtxt.CurrFunc==nil {
+		return false
+	}
+	found := false
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident && iden.Obj != nil && iden.Obj.Kind==ast.Var {
+			pos := iden.Obj.Pos()
+			in_func := pos >= ASTCtxt.CurrFunc.Pos() && pos < ASTCtxt.CurrFunc.End()
+			in_lit := pos >= lit.Pos() && pos < lit.End()
+			if in_func && !in_lit {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+/**
+ * Closures passed as callbacks get their captured variables through a DataPack.
+ * The DataPack is passed as the callback's data arg and unpacked at the top of the hoisted function.
+ * Clients are captured by their userid so the callback won't use an index that someone else took.
+ * 
+ * CreateTimer(1.0, func(timer Timer, data any) Action {
+ *     PrintToChat(client, msg)
+ *     return Plugin_Stop
+ * }, nil, 0)
+ * 
+ * Becomes:
+ * 
+ * var closure_pack0 DataPack = CreateDataPack()
+ * if client > 0 && IsClientInGame(client) {
+ *     closure_pack0.WriteCell(GetClientUserId(client), false)
+ * } else {
+ *     closure_pack0.WriteCell(0, false)
+ * }
+ * closure_pack0.WriteString(msg, false)
+ * CreateTimer(1.0, SrcGoTmpFunc0, closure_pack0, 0 | TIMER_DATA_HNDL_CLOSE)
+ * 
+ * func SrcGoTmpFunc0(timer Timer, data any) Action {
+ *     var closure_pack DataPack = data
+ *     closure_pack.Reset(false)
+ *     var client int = GetClientOfUserId(closure_pack.ReadCell())
+ *     var msg string
+ *     closure_pack.ReadString(msg, sizeof(msg))
+ *     PrintToChat(client, msg)
+ *     return Plugin_Stop
+ * }
+ * 
+ * Timers close the DataPack themselves, other callbacks delete it once it's unpacked.
+ */
+func MutateClosures(file *ast.File) {
+	for i := 0; i < len(file.Decls); i++ {
+		/// hoisted closures are appended to the decls so closures inside of them get hoisted too.
+		if d, is_func := file.Decls[i].(*ast.FuncDecl); is_func && d.Body != nil {
+			ASTCtxt.CurrFunc = d
+			ASTCtxt.NewDecls = make([]ast.Decl, 0)
+			MutateBlock(d.Body, MutateClosureStmts)
+			file.Decls = append(file.Decls, ASTCtxt.NewDecls...)
+			ASTCtxt.CurrFunc = nil
+		}
+	}
+	ASTCtxt.NewDecls = nil
+}
+
+func MutateClosureStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateClosureStmts)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateClosureStmts)
+		
+		case *ast.IfStmt:
+			bm(n.Body, MutateClosureStmts)
+			if n.Else != nil {
+				MutateClosureStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateClosureStmts)
+		
+		case *ast.CaseClause:
+			MutateStmtList(&n.Body, MutateClosureStmts)
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateClosureStmts)
+		
+		case *ast.ExprStmt, *ast.AssignStmt, *ast.DeclStmt, *ast.ReturnStmt:
+			ast.Inspect(s, func(node ast.Node) bool {
+				switch x := node.(type) {
+					case *ast.CallExpr:
+						for i := range x.Args {
+							if lit, is_lit := x.Args[i].(*ast.FuncLit); is_lit {
+								x.Args[i] = HoistClosure(x, lit, owner_list, s)
+							}
+						}
+					case *ast.FuncLit:
+						if len(GetClosureCaptures(x)) > 0 {
+							PrintSrcGoErr(x.Pos(), "Closures that capture variables have to be passed straight to the function that calls them.")
+						}
+						return false
+				}
+				return true
+			})
+	}
+}
+
+/// the local variables a function literal uses from the function it's in, in the order they're used.
+func GetClosureCaptures(lit *ast.FuncLit) []*types.Var {
+	captures := make([]*types.Var, 0)
+	seen := make(map[*types.Var]bool)
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		iden, is_ident := n.(*ast.Ident)
+		if !is_ident {
+			return true
+		}
+		v, is_var := ASTCtxt.TypeInfo.Uses[iden].(*types.Var)
+		if !is_var || v.IsField() || seen[v] || v.Parent()==nil || v.Parent()==types.Universe || v.Parent()==v.Pkg().Scope() {
+			return true
+		} else if v.Pos() >= lit.Pos() && v.Pos() < lit.End() {
+			return true
+		}
+		seen[v] = true
+		captures = append(captures, v)
+		return true
+	})
+	return captures
+}
+
+/// client indices can be reused once a player leaves, so they're captured as userids.
+/// ints named 'client', 'target_client' or 'targetClient' are clients, counts like 'maxclient' aren't.
+func IsClientVar(v *types.Var) bool {
+	if basic, is_basic := v.Type().Underlying().(*types.Basic); !is_basic || basic.Info() & types.IsInteger==0 {
+		return false
+	}
+	name := v.Name()
+	if !strings.HasSuffix(strings.ToLower(name), "client") {
+		return false
+	}
+	prefix := name[:len(name) - len("client")]
+	switch strings.ToLower(strings.TrimSuffix(prefix, "_")) {
+		case "max", "num", "count", "total":
+			return false
+	}
+	return prefix=="" || strings.HasSuffix(prefix, "_") || name[len(prefix)]=='C'
+}
+
+/**
+ * the console and clients that aren't in game have no userid, they're packed as 0 which unpacks to 0.
+ * if client > 0 && IsClientInGame(client) {
+ *     pack.WriteCell(GetClientUserId(client), false)
+ * } else {
+ *     pack.WriteCell(0, false)
+ * }
+ */
+func MakeClientPack(pack, client *ast.Ident) *ast.IfStmt {
+	in_game := new(ast.IfStmt)
+	in_game.Cond = MakeBinaryExpr(MakeBinaryExpr(client, token.GTR, MakeBasicLit(token.INT, "0")), token.LAND, MakeCall("IsClientInGame", client))
+	in_game.Body = new(ast.BlockStmt)
+	in_game.Body.List = append(in_game.Body.List, MakeExprStmt(MakeMethodCall(pack, "WriteCell", MakeCall("GetClientUserId", client), ast.NewIdent("false"))))
+	no_userid := new(ast.BlockStmt)
+	no_userid.List = append(no_userid.List, MakeExprStmt(MakeMethodCall(pack, "WriteCell", MakeBasicLit(token.INT, "0"), ast.NewIdent("false"))))
+	in_game.Else = no_userid
+	return in_game
+}
+
+/// only values that fit in a cell or a string can be written to a DataPack.
+func IsCapturable(v *types.Var) bool {
+	if IsStrType(v.Type()) {
+		_, is_slice := v.Type().Underlying().(*types.Slice)
+		return !is_slice
+	}
+	switch v.Type().Underlying().(type) {
+		case *types.Basic:
+			return true
+		case *types.Struct:
+			/// structs from the includes are methodmaps, local ones are enum structs.
+			named, is_named := v.Type().(*types.Named)
+			return is_named && ASTCtxt.FSet.Position(named.Obj().Pos()).Filename != ASTCtxt.FSet.Position(ASTCtxt.CurrFunc.Pos()).Filename
+	}
+	return false
+}
+
+/// moves a function literal into its own function, packing its captured variables into the callback's data arg.
+func HoistClosure(call *ast.CallExpr, lit *ast.FuncLit, owner_list *[]ast.Stmt, anchor ast.Stmt) ast.Expr {
+	tmp_func_name := ast.NewIdent(fmt.Sprintf("SrcGoTmpFunc%d", ASTCtxt.TmpFunc))
+	ASTCtxt.TmpFunc++
+	fn_decl := new(ast.FuncDecl)
+	fn_decl.Name = tmp_func_name
+	fn_decl.Type = lit.Type
+	fn_decl.Body = lit.Body
+	ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+	ASTCtxt.FuncMap[tmp_func_name.Name] = fn_decl
+	
+	captures := GetClosureCaptures(lit)
+	if len(captures)==0 {
+		return tmp_func_name
+	}
+	callee := GetFuncName(call.Fun)
+	for _, v := range captures {
+		if !IsCapturable(v) {
+			PrintSrcGoErr(lit.Pos(), fmt.Sprintf("Closure can't capture '%s', only cells and strings can be passed to a callback.", v.Name()))
+			return tmp_func_name
+		}
+	}
+	if HasCaptureWrite(lit, captures) {
+		return tmp_func_name
+	}
+	
+	/// the DataPack replaces the data arg of the call.
+	data_arg := -1
+	if sig, is_sig := ASTCtxt.TypeInfo.TypeOf(call.Fun).(*types.Signature); is_sig {
+		for i := 0; i < sig.Params().Len(); i++ {
+			if param := sig.Params().At(i); param.Name()=="data" && types.IsInterface(param.Type()) {
+				data_arg = i
+			}
+		}
+	}
+	if data_arg < 0 || data_arg >= len(call.Args) {
+		PrintSrcGoErr(lit.Pos(), fmt.Sprintf("Closure passed to '%s' can't capture variables, '%s' has no data arg to pass them with.", callee, callee))
+		return tmp_func_name
+	} else if tv := ASTCtxt.TypeInfo.Types[call.Args[data_arg]]; !tv.IsNil() && (tv.Value==nil || constant.Sign(tv.Value) != 0) {
+		PrintSrcGoErr(call.Args[data_arg].Pos(), fmt.Sprintf("The data arg of '%s' is needed to pass the closure's captured variables, pass 'nil' instead.", callee))
+		return tmp_func_name
+	}
+	
+	/// and the callback reads it back from its own data param.
+	var data_param *ast.Ident
+	for _, field := range lit.Type.Params.List {
+		if typ := ASTCtxt.TypeInfo.TypeOf(field.Type); typ != nil && types.IsInterface(typ) && len(field.Names)==1 {
+			data_param = field.Names[0]
+		}
+	}
+	if data_param==nil {
+		PrintSrcGoErr(lit.Pos(), "Closure that captures variables needs a named 'any' param for its data.")
+		return tmp_func_name
+	} else if data_param.Name=="_" {
+		data_param.Name = "data"
+	}
+	
+	/// the DataPack type comes from the bindings, the closure can't be packed without them.
+	datapack, is_type := captures[0].Pkg().Scope().Lookup("DataPack").(*types.TypeName)
+	if !is_type {
+		PrintSrcGoErr(lit.Pos(), "Closure that captures variables needs the 'DataPack' type from the bindings, import \"datapack\".")
+		return tmp_func_name
+	}
+	capture_types := make([]ast.Expr, len(captures))
+	for i, v := range captures {
+		if capture_types[i] = TypeToASTExpr(v.Type()); capture_types[i]==nil {
+			PrintSrcGoErr(lit.Pos(), fmt.Sprintf("Closure can't capture '%s', its type '%s' has no SourcePawn type.", v.Name(), v.Type()))
+			return tmp_func_name
+		}
+	}
+	
+	pack := ast.NewIdent(fmt.Sprintf("closure_pack%d", ASTCtxt.TmpVar))
+	ASTCtxt.TmpVar++
+	packing := []ast.Stmt{ MakeVarInit(pack, TypeToASTExpr(datapack.Type()), MakeCall("CreateDataPack")) }
+	unpack := ast.NewIdent("closure_pack")
+	unpacking := []ast.Stmt{
+		MakeVarInit(unpack, TypeToASTExpr(datapack.Type()), ast.NewIdent(data_param.Name)),
+		MakeExprStmt(MakeMethodCall(unpack, "Reset", ast.NewIdent("false"))),
+	}
+	for i, v := range captures {
+		name, typ := ast.NewIdent(v.Name()), capture_types[i]
+		switch {
+			case IsStrType(v.Type()):
+				packing = append(packing, MakeExprStmt(MakeMethodCall(pack, "WriteString", name, ast.NewIdent("false"))))
+				unpacking = append(unpacking, MakeTypedVarDecl([]*ast.Ident{name}, typ), MakeExprStmt(MakeMethodCall(unpack, "ReadString", name, MakeCall("sizeof", name))))
+			case IsFloatType(v.Type()):
+				packing = append(packing, MakeExprStmt(MakeMethodCall(pack, "WriteFloat", name, ast.NewIdent("false"))))
+				unpacking = append(unpacking, MakeVarInit(name, typ, MakeMethodCall(unpack, "ReadFloat")))
+			case IsClientVar(v):
+				packing = append(packing, MakeClientPack(pack, name))
+				unpacking = append(unpacking, MakeVarInit(name, typ, MakeCall("GetClientOfUserId", MakeMethodCall(unpack, "ReadCell"))))
+			default:
+				packing = append(packing, MakeExprStmt(MakeMethodCall(pack, "WriteCell", name, ast.NewIdent("false"))))
+				unpacking = append(unpacking, MakeVarInit(name, typ, MakeMethodCall(unpack, "ReadCell")))
+		}
+	}
+	
+	call.Args[data_arg] = pack
+	if callee=="CreateTimer" {
+		/// timers can repeat so they have to close the DataPack when they're done.
+		if len(call.Args) > 3 {
+			call.Args[3] = MakeBinaryExpr(call.Args[3], token.OR, ast.NewIdent("TIMER_DATA_HNDL_CLOSE"))
+		} else {
+			call.Args = append(call.Args, ast.NewIdent("TIMER_DATA_HNDL_CLOSE"))
+		}
+	} else {
+		unpacking = append(unpacking, MakeExprStmt(MakeCall("delete", unpack)))
+	}
+	ReplaceStmt(owner_list, anchor, append(packing, anchor)...)
+	fn_decl.Body.List = append(unpacking, fn_decl.Body.List...)
+	return tmp_func_name
+}
+
+/// closures get a copy of what they capture, so changing it wouldn't change the original.
+func HasCaptureWrite(lit *ast.FuncLit, captures []*types.Var) bool {
+	is_capture := func(e ast.Expr) bool {
+		if iden, is_ident := e.(*ast.Ident); is_ident {
+			for _, v := range captures {
+				if ASTCtxt.TypeInfo.Uses[iden]==v {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	found := false
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		var written ast.Expr
+		switch x := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					if is_capture(lhs) {
+						written = lhs
+					}
+				}
+			case *ast.IncDecStmt:
+				written = x.X
+			case *ast.UnaryExpr:
+				if x.Op==token.AND {
+					written = x.X
+				}
+		}
+		if written != nil && is_capture(written) {
+			PrintSrcGoErr(written.Pos(), fmt.Sprintf("Closure can't change '%s', it only gets a copy of it.", written.(*ast.Ident).Name))
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+/**
+ * Deferred calls are run in reverse order before every return after them and at the end of the function.
+ * Their args are evaluated where they're deferred, so they're stored in temporaries.
+ * Defers inside of an if or switch set a flag so their cleanup only runs if they were reached.
+ * 
+ * kv := CreateKeyValues("data")
+ * defer delete(kv)
+ * if !kv.ImportFromFile(path) {
+ *     return false
+ * }
+ * return kv.GotoFirstSubKey()
+ * 
+ * Becomes:
+ * 
+ * kv := CreateKeyValues("data")
+ * var defer0_arg0 KeyValues = kv
+ * if !kv.ImportFromFile(path) {
+ *     delete(defer0_arg0)
+ *     return false
+ * }
+ * var defer_ret0 bool = kv.GotoFirstSubKey()
+ * delete(defer0_arg0)
+ * return defer_ret0
+ */
+func MutateDefers(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateDeferBody(d.Body)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/// the cleanup of a deferred call, 'Start' is the index of the top statement from which the cleanup runs.
+type DeferCleanup struct {
+	Make  func() ast.Stmt
+	Start int
+}
+
+func MutateDeferBody(body *ast.BlockStmt) {
+	prologue := make([]ast.Stmt, 0)
+	cleanups := make([]DeferCleanup, 0)
+	new_list := make([]ast.Stmt, 0, len(body.List))
+	for _, stmt := range body.List {
+		if defer_stmt, is_defer := stmt.(*ast.DeferStmt); is_defer {
+			temps, call := LowerDeferCall(defer_stmt.Call, len(cleanups))
+			for _, temp := range temps {
+				if IsStrType(temp.Type) {
+					/// strings are copied into a buffer.
+					new_list = append(new_list, MakeTypedVarDecl([]*ast.Ident{temp.Name}, TypeToASTExpr(temp.Type)), MakeDeferAssign(temp))
+				} else {
+					new_list = append(new_list, MakeVarInit(temp.Name, TypeToASTExpr(temp.Type), temp.Value))
+				}
+			}
+			cleanups = append(cleanups, DeferCleanup{ func() ast.Stmt { return MakeExprStmt(CopyCall(call)) }, len(new_list) })
+			continue
+		}
+		
+		/// defers that might not be reached are guarded by a flag.
+		LowerNestedDefers(stmt, false, func(defer_stmt *ast.DeferStmt) []ast.Stmt {
+			temps, call := LowerDeferCall(defer_stmt.Call, len(cleanups))
+			flag := ast.NewIdent(fmt.Sprintf("defer%d_ran", len(cleanups)))
+			prologue = append(prologue, MakeTypedVarDecl([]*ast.Ident{flag}, ast.NewIdent("bool")))
+			stmts := make([]ast.Stmt, 0)
+			for _, temp := range temps {
+				prologue = append(prologue, MakeTypedVarDecl([]*ast.Ident{temp.Name}, TypeToASTExpr(temp.Type)))
+				stmts = append(stmts, MakeDeferAssign(temp))
+			}
+			set_flag := MakeAssign(false)
+			set_flag.Lhs = append(set_flag.Lhs, flag)
+			set_flag.Rhs = append(set_flag.Rhs, ast.NewIdent("true"))
+			
+			make_guard := func() ast.Stmt {
+				guard := new(ast.IfStmt)
+				guard.Cond = flag
+				guard.Body = new(ast.BlockStmt)
+				guard.Body.List = append(guard.Body.List, MakeExprStmt(CopyCall(call)))
+				return guard
+			}
+			cleanups = append(cleanups, DeferCleanup{ make_guard, len(new_list) })
+			return append(stmts, set_flag)
+		})
+		new_list = append(new_list, stmt)
+	}
+	if len(cleanups)==0 {
+		return
+	}
+	
+	/// which cleanups run depends on where the return is, so go through the top statements one by one.
+	for i := 0; i < len(new_list); i++ {
+		active := make([]func() ast.Stmt, 0)
+		for j := len(cleanups)-1; j >= 0; j-- {
+			if cleanups[j].Start <= i {
+				active = append(active, cleanups[j].Make)
+			}
+		}
+		if len(active)==0 {
+			continue
+		}
+		old_len := len(new_list)
+		InsertDeferCleanups(&new_list, i, active)
+		/// the statements after a return moved, so their cleanups' start has to move with them.
+		for j := range cleanups {
+			if cleanups[j].Start > i {
+				cleanups[j].Start += len(new_list) - old_len
+			}
+		}
+		i += len(new_list) - old_len
+	}
+	if _, is_ret := new_list[len(new_list)-1].(*ast.ReturnStmt); !is_ret {
+		for j := len(cleanups)-1; j >= 0; j-- {
+			new_list = append(new_list, cleanups[j].Make())
+		}
+	}
+	body.List = append(prologue, new_list...)
+}
+
+/// a deferred call's arg that's evaluated at the defer.
+type DeferTemp struct {
+	Name  *ast.Ident
+	Type  types.Type
+	Value ast.Expr
+}
+
+func MakeDeferAssign(temp DeferTemp) *ast.AssignStmt {
+	assign := MakeAssign(false)
+	assign.Lhs = append(assign.Lhs, temp.Name)
+	assign.Rhs = append(assign.Rhs, temp.Value)
+	return assign
+}
+
+/// stores the args of a deferred call in temporaries and returns the call that uses them.
+func LowerDeferCall(call *ast.CallExpr, index int) ([]DeferTemp, *ast.CallExpr) {
+	temps := make([]DeferTemp, 0)
+	store := func(e ast.Expr) ast.Expr {
+		tv := ASTCtxt.TypeInfo.Types[e]
+		if tv.Value != nil || tv.IsNil() || tv.Type==nil {
+			return e
+		}
+		temp := DeferTemp{ MakeTypedIdent(fmt.Sprintf("defer%d_arg%d", index, len(temps)), tv.Type), tv.Type, e }
+		temps = append(temps, temp)
+		return temp.Name
+	}
+	
+	deferred := new(ast.CallExpr)
+	deferred.Fun = call.Fun
+	switch fn := call.Fun.(type) {
+		case *ast.FuncLit:
+			PrintSrcGoErr(call.Pos(), "Deferring a function literal is not supported, defer a function call instead.")
+		case *ast.SelectorExpr:
+			/// the receiver of a method is evaluated at the defer too.
+			if tv := ASTCtxt.TypeInfo.Types[fn.X]; tv.IsValue() {
+				deferred.Fun = MakeSelector(store(fn.X), fn.Sel.Name)
+			}
+	}
+	for _, arg := range call.Args {
+		deferred.Args = append(deferred.Args, store(arg))
+	}
+	return temps, deferred
+}
+
+/// each cleanup gets its own call so later passes can change one without changing the others.
+func CopyCall(call *ast.CallExpr) *ast.CallExpr {
+	copied := new(ast.CallExpr)
+	copied.Fun = call.Fun
+	copied.Args = append(copied.Args, call.Args...)
+	return copied
+}
+
+/// an identifier with a type the later passes can look up before the AST is type-checked again.
+func MakeTypedIdent(name string, typ types.Type) *ast.Ident {
+	iden := ast.NewIdent(name)
+	ASTCtxt.TypeInfo.Uses[iden] = types.NewVar(token.NoPos, nil, name, typ)
+	return iden
+}
+
+/// replaces defers nested in 's' with the statements 'lower' makes for them.
+func LowerNestedDefers(s ast.Stmt, in_loop bool, lower func(defer_stmt *ast.DeferStmt) []ast.Stmt) {
+	lower_list := func(list *[]ast.Stmt, in_loop bool) {
+		for i := 0; i < len(*list); i++ {
+			if defer_stmt, is_defer := (*list)[i].(*ast.DeferStmt); is_defer {
+				if in_loop {
+					PrintSrcGoErr(defer_stmt.Pos(), "Defer inside a loop is not supported, its calls would pile up until the function returns.")
+					continue
+				}
+				stmts := lower(defer_stmt)
+				ReplaceStmt(list, defer_stmt, stmts...)
+				i += len(stmts) - 1
+			} else {
+				LowerNestedDefers((*list)[i], in_loop, lower)
+			}
+		}
+	}
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			lower_list(&n.List, in_loop)
+		case *ast.IfStmt:
+			lower_list(&n.Body.List, in_loop)
+			if n.Else != nil {
+				LowerNestedDefers(n.Else, in_loop, lower)
+			}
+		case *ast.SwitchStmt:
+			lower_list(&n.Body.List, in_loop)
+		case *ast.CaseClause:
+			lower_list(&n.Body, in_loop)
+		case *ast.ForStmt:
+			lower_list(&n.Body.List, true)
+		case *ast.RangeStmt:
+			lower_list(&n.Body.List, true)
+		case *ast.LabeledStmt:
+			LowerNestedDefers(n.Stmt, in_loop, lower)
+	}
+}
+
+/// puts the cleanups before the returns in the statement at 'index', return values are stored first so they're evaluated before the cleanup.
+func InsertDeferCleanups(list *[]ast.Stmt, index int, cleanups []func() ast.Stmt) {
+	ret, is_ret := (*list)[index].(*ast.ReturnStmt)
+	if !is_ret {
+		switch n := (*list)[index].(type) {
+			case *ast.BlockStmt:
+				InsertDeferCleanupList(&n.List, cleanups)
+			case *ast.ForStmt:
+				InsertDeferCleanupList(&n.Body.List, cleanups)
+			case *ast.RangeStmt:
+				InsertDeferCleanupList(&n.Body.List, cleanups)
+			case *ast.IfStmt:
+				InsertDeferCleanupList(&n.Body.List, cleanups)
+				if n.Else != nil {
+					else_list := []ast.Stmt{n.Else}
+					InsertDeferCleanups(&else_list, 0, cleanups)
+				}
+			case *ast.SwitchStmt:
+				InsertDeferCleanupList(&n.Body.List, cleanups)
+			case *ast.CaseClause:
+				InsertDeferCleanupList(&n.Body, cleanups)
+			case *ast.LabeledStmt:
+				labeled := []ast.Stmt{n.Stmt}
+				InsertDeferCleanups(&labeled, 0, cleanups)
+		}
+		return
+	}
+	stmts := make([]ast.Stmt, 0)
+	for i, result := range ret.Results {
+		tv := ASTCtxt.TypeInfo.Types[result]
+		if _, is_ident := result.(*ast.Ident); is_ident || tv.Value != nil || tv.IsNil() || tv.Type==nil {
+			continue
+		} else if _, is_tuple := tv.Type.(*types.Tuple); is_tuple {
+			continue
+		}
+		temp := MakeTypedIdent(fmt.Sprintf("defer_ret%d", ASTCtxt.TmpVar), tv.Type)
+		ASTCtxt.TmpVar++
+		stmts = append(stmts, MakeVarInit(temp, TypeToASTExpr(tv.Type), result))
+		ret.Results[i] = temp
+	}
+	for _, make_cleanup := range cleanups {
+		stmts = append(stmts, make_cleanup())
+	}
+	for i := len(stmts)-1; i >= 0; i-- {
+		*list = InsertStmt(*list, index, stmts[i])
+	}
+}
+
+func InsertDeferCleanupList(list *[]ast.Stmt, cleanups []func() ast.Stmt) {
+	for i := 0; i < len(*list); i++ {
+		old_len := len(*list)
+		InsertDeferCleanups(list, i, cleanups)
+		i += len(*list) - old_len
+	}
+}
+
+/**
+ * Labeled break and continue leave the inner loops with a flag that each loop checks once it's done.
+ * 
+ * outer:
+ * for i := 0; i < n; i++ {
+ *     for j := 0; j < n; j++ {
+ *         if found {
+ *             break outer
+ *         }
+ *     }
+ * }
+ * 
+ * Becomes:
+ * 
+ * var outer_break bool
+ * for i := 0; i < n; i++ {
+ *     for j := 0; j < n; j++ {
+ *         if found {
+ *             outer_break = true
+ *             break
+ *         }
+ *     }
+ *     if outer_break {
+ *         break
+ *     }
+ * }
+ * 
+ * 'continue outer' works the same with an 'outer_continue' flag that's reset every time the labeled loop starts over.
+ */
+func MutateLabels(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					LowerLabelsList(&d.Body.List, nil, make(map[ast.Stmt][]ast.Stmt), make(map[string]*LabelFlags))
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/// a loop that break and continue statements can target.
+type LabelLoop struct {
+	Stmt  ast.Stmt
+	Label string
+}
+
+/// the flags of a labeled loop, nil until a branch out of an inner loop needs them.
+type LabelFlags struct {
+	Break, Continue *ast.Ident
+}
+
+/// 'after' holds the flag checks to put after each inner loop, 'flags' holds the flags of each label.
+func LowerLabelsList(list *[]ast.Stmt, loops []LabelLoop, after map[ast.Stmt][]ast.Stmt, flags map[string]*LabelFlags) {
+	for i := 0; i < len(*list); i++ {
+		switch n := (*list)[i].(type) {
+			case *ast.LabeledStmt:
+				label := n.Label.Name
+				flags[label] = new(LabelFlags)
+				LowerLabels(n.Stmt, append(loops, LabelLoop{ n.Stmt, label }), after, flags)
+				stmts := make([]ast.Stmt, 0)
+				if flag := flags[label].Break; flag != nil {
+					stmts = append(stmts, MakeTypedVarDecl([]*ast.Ident{flag}, ast.NewIdent("bool")))
+				}
+				if flag := flags[label].Continue; flag != nil {
+					stmts = append(stmts, MakeTypedVarDecl([]*ast.Ident{flag}, ast.NewIdent("bool")))
+					reset := MakeAssign(false)
+					reset.Lhs = append(reset.Lhs, flag)
+					reset.Rhs = append(reset.Rhs, ast.NewIdent("false"))
+					body := GetLoopBody(n.Stmt)
+					body.List = InsertStmt(body.List, 0, reset)
+				}
+				stmts = append(stmts, n.Stmt)
+				ReplaceStmt(list, n, stmts...)
+				i += len(stmts) - 1
+			
+			case *ast.BranchStmt:
+				if n.Label==nil || n.Tok==token.GOTO {
+					continue
+				}
+				target := len(loops)-1
+				for target >= 0 && loops[target].Label != n.Label.Name {
+					target--
+				}
+				if target < 0 {
+					PrintSrcGoErr(n.Pos(), fmt.Sprintf("'%s %s' has to be inside the loop labeled '%s'.", n.Tok, n.Label.Name, n.Label.Name))
+					continue
+				}
+				branch := new(ast.BranchStmt)
+				branch.Tok = n.Tok
+				if target==len(loops)-1 {
+					/// the labeled loop is the innermost loop.
+					(*list)[i] = branch
+					continue
+				}
+				branch.Tok = token.BREAK
+				flag := GetLabelFlag(flags[n.Label.Name], n.Label.Name, n.Tok)
+				set_flag := MakeAssign(false)
+				set_flag.Lhs = append(set_flag.Lhs, flag)
+				set_flag.Rhs = append(set_flag.Rhs, ast.NewIdent("true"))
+				ReplaceStmt(list, n, set_flag, branch)
+				i++
+				
+				/// every loop between here and the labeled loop checks the flag once it's done.
+				for k := target+1; k < len(loops); k++ {
+					tok := token.BREAK
+					if k==target+1 {
+						tok = n.Tok
+					}
+					AddLabelCheck(after, loops[k].Stmt, flag, tok)
+				}
+			
+			default:
+				LowerLabels(n, loops, after, flags)
+		}
+		if checks, found := after[(*list)[i]]; found {
+			delete(after, (*list)[i])
+			for j := len(checks)-1; j >= 0; j-- {
+				*list = InsertStmt(*list, i+1, checks[j])
+			}
+			i += len(checks)
+		}
+	}
+}
+
+func LowerLabels(s ast.Stmt, loops []LabelLoop, after map[ast.Stmt][]ast.Stmt, flags map[string]*LabelFlags) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			LowerLabelsList(&n.List, loops, after, flags)
+		case *ast.IfStmt:
+			LowerLabelsList(&n.Body.List, loops, after, flags)
+			if n.Else != nil {
+				else_list := []ast.Stmt{n.Else}
+				LowerLabelsList(&else_list, loops, after, flags)
+			}
+		case *ast.SwitchStmt:
+			LowerLabelsList(&n.Body.List, loops, after, flags)
+		case *ast.CaseClause:
+			LowerLabelsList(&n.Body, loops, after, flags)
+		case *ast.ForStmt, *ast.RangeStmt:
+			if len(loops)==0 || loops[len(loops)-1].Stmt != s {
+				/// unlabeled loop.
+				loops = append(loops, LabelLoop{ s, "" })
+			}
+			LowerLabelsList(&GetLoopBody(s).List, loops, after, flags)
+	}
+}
+
+func GetLoopBody(s ast.Stmt) *ast.BlockStmt {
+	switch n := s.(type) {
+		case *ast.ForStmt:
+			return n.Body
+		case *ast.RangeStmt:
+			return n.Body
+	}
+	return nil
+}
+
+func GetLabelFlag(label_flags *LabelFlags, label string, tok token.Token) *ast.Ident {
+	if tok==token.CONTINUE {
+		if label_flags.Continue==nil {
+			label_flags.Continue = ast.NewIdent(label + "_continue")
+		}
+		return label_flags.Continue
+	}
+	if label_flags.Break==nil {
+		label_flags.Break = ast.NewIdent(label + "_break")
+	}
+	return label_flags.Break
+}
+
+/// if flag { break } after 'loop', once for each flag.
+func AddLabelCheck(after map[ast.Stmt][]ast.Stmt, loop ast.Stmt, flag *ast.Ident, tok token.Token) {
+	for _, check := range after[loop] {
+		if check.(*ast.IfStmt).Cond==flag {
+			return
+		}
+	}
+	branch := new(ast.BranchStmt)
+	branch.Tok = tok
+	check := new(ast.IfStmt)
+	check.Cond = flag
+	check.Body = new(ast.BlockStmt)
+	check.Body.List = append(check.Body.List, branch)
+	after[loop] = append(after[loop], check)
+}
+
+/**
+ * Maps are lowered into StringMap method calls.
+ * Which method is used depends on the map's value type:
+ * scalars use Get/SetValue, strings use Get/SetString, fixed arrays and enum structs use Get/SetArray.
+ * 
+ * m[k] = v      => m.SetValue(k, v)
+ * v := m[k]     => var v T; m.GetValue(k, &v)
+ * v, ok := m[k] => var v T; var ok bool; ok = m.GetValue(k, &v)
+ * delete(m, k)  => m.Remove(k)
+ * 
+ * len(m) is left for the generator to print as m.Size so it keeps its type.
+ */
+func MutateMaps(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateMapExprs)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func GetMapType(e ast.Expr) *types.Map {
+	if typ := ASTCtxt.TypeInfo.TypeOf(e); typ != nil {
+		if m, is_map := typ.Underlying().(*types.Map); is_map {
+			return m
+		}
+	}
+	return nil
+}
+
+/// returns the index expression if 'e' is indexing a map.
+func GetMapIndex(e ast.Expr) *ast.IndexExpr {
+	if index, is_index := e.(*ast.IndexExpr); is_index && GetMapType(index.X) != nil {
+		return index
+	}
+	return nil
+}
+
+/// checks if a node reads, writes or deletes map entries.
+func HasMapAccess(node ast.Node) bool {
+	if node==nil {
+		return false
+	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.IndexExpr:
+				if GetMapIndex(x) != nil {
+					found = true
+				}
+			case *ast.CallExpr:
+				if GetFuncName(x.Fun)=="delete" && len(x.Args) > 0 && GetMapType(x.Args[0]) != nil {
+					found = true
+				}
+		}
+		return !found
+	})
+	return found
+}
+
+func MakeMapGet(index *ast.IndexExpr, map_type *types.Map, dest ast.Expr) *ast.CallExpr {
+	elem := map_type.Elem()
+	if IsStrType(elem) {
+		return MakeMethodCall(index.X, "GetString", index.Index, dest, MakeCall("sizeof", dest))
+	}
+	switch elem.Underlying().(type) {
+		case *types.Array:
+			return MakeMethodCall(index.X, "GetArray", index.Index, dest, MakeCall("len", dest))
+		case *types.Struct:
+			return MakeMethodCall(index.X, "GetArray", index.Index, dest, MakeCall("sizeof", dest))
+	}
+	return MakeMethodCall(index.X, "GetValue", index.Index, MakeReference(dest))
+}
+
+func MakeMapSet(index *ast.IndexExpr, map_type *types.Map, value ast.Expr) *ast.CallExpr {
+	elem := map_type.Elem()
+	if IsStrType(elem) {
+		return MakeMethodCall(index.X, "SetString", index.Index, value)
+	}
+	switch t := elem.Underlying().(type) {
+		case *types.Array:
+			return MakeMethodCall(index.X, "SetArray", index.Index, value, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+		case *types.Struct:
+			return MakeMethodCall(index.X, "SetArray", index.Index, value, MakeCall("sizeof", value))
+	}
+	return MakeMethodCall(index.X, "SetValue", index.Index, value)
+}
+
+/// declares a variable that can hold a map value, strings have no size so they get a char buffer.
+func MakeMapValueDecl(name *ast.Ident, elem types.Type) *ast.DeclStmt {
+	if IsStrType(elem) {
+		if _, is_array := elem.Underlying().(*types.Array); !is_array {
+			return MakeTypedVarDecl([]*ast.Ident{name}, Arrayify(ast.NewIdent("char"), MakeBasicLit(token.INT, fmt.Sprintf("%d", StrBufferLen))))
+		}
+	}
+	return MakeVarDecl([]*ast.Ident{name}, nil, elem)
+}
+
+/// reads a map entry into a new temporary declared before 'anchor' and returns the temporary.
+func HoistMapRead(owner_list *[]ast.Stmt, anchor ast.Stmt, index *ast.IndexExpr, map_type *types.Map) ast.Expr {
+	tmp := ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
+	ASTCtxt.TmpVar++
+	get := new(ast.ExprStmt)
+	get.X = MakeMapGet(index, map_type, tmp)
+	i := FindStmt(*owner_list, anchor)
+	*owner_list = InsertStmt(*owner_list, i, MakeMapValueDecl(tmp, map_type.Elem()))
+	*owner_list = InsertStmt(*owner_list, i+1, get)
+	return tmp
+}
+
+/// v = m[k] | v, found = m[k], 'define' declares any new variables first.
+func ExpandMapRead(index *ast.IndexExpr, map_type *types.Map, value, found ast.Expr, define bool) []ast.Stmt {
+	new_stmts := make([]ast.Stmt, 0)
+	if iden, is_ident := value.(*ast.Ident); is_ident {
+		if iden.Name=="_" {
+			value = ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
+			ASTCtxt.TmpVar++
+			new_stmts = append(new_stmts, MakeMapValueDecl(value.(*ast.Ident), map_type.Elem()))
+		} else if define && ASTCtxt.TypeInfo.Defs[iden] != nil {
+			new_stmts = append(new_stmts, MakeMapValueDecl(iden, map_type.Elem()))
+		}
+	}
+	
+	get := MakeMapGet(index, map_type, value)
+	if iden, is_ident := found.(*ast.Ident); found != nil && (!is_ident || iden.Name != "_") {
+		if define && is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil {
+			new_stmts = append(new_stmts, MakeTypedVarDecl([]*ast.Ident{iden}, ast.NewIdent("bool")))
+		}
+		assign := MakeAssign(false)
+		assign.Lhs = append(assign.Lhs, found)
+		assign.Rhs = append(assign.Rhs, get)
+		new_stmts = append(new_stmts, assign)
+	} else {
+		get_stmt := new(ast.ExprStmt)
+		get_stmt.X = get
+		new_stmts = append(new_stmts, get_stmt)
+	}
+	return new_stmts
+}
+
+/// moves the init statement of 'owner' into a new block in front of it so the init can expand into multiple statements.
+func SplitInitStmt(owner_list *[]ast.Stmt, owner ast.Stmt, init *ast.Stmt) *ast.BlockStmt {
+	block := new(ast.BlockStmt)
+	block.List = append(block.List, *init, owner)
+	*init = nil
+	(*owner_list)[FindStmt(*owner_list, owner)] = block
+	return block
+}
+
+func MutateMapExprs(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateMapExprs)
+		
+		case *ast.ForStmt:
+			if n.Init != nil && HasMapAccess(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateMapExprs)
+				return
+			}
+			MutateMapExpr(&n.Cond, nil, nil)
+			if n.Post != nil && HasMapAccess(n.Post) {
+				PrintSrcGoErr(n.Post.Pos(), "Map access in a for-loop post statement is not supported.")
+			}
+			bm(n.Body, MutateMapExprs)
+		
+		case *ast.IfStmt:
+			/// the condition's map reads are moved before the if, so they have to stay after its init.
+			if n.Init != nil && (HasMapAccess(n.Init) || HasMapAccess(n.Cond)) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateMapExprs)
+				return
+			}
+			MutateMapExpr(&n.Cond, owner_list, n)
+			bm(n.Body, MutateMapExprs)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && (HasMapAccess(else_if.Init) || HasMapAccess(else_if.Cond)) {
+				/// keep the else-if's map reads inside of the else.
+				else_block := new(ast.BlockStmt)
+				else_block.List = append(else_block.List, else_if)
+				n.Else = else_block
+			}
+			if n.Else != nil {
+				MutateMapExprs(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			if n.Init != nil && (HasMapAccess(n.Init) || HasMapAccess(n.Tag)) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateMapExprs)
+				return
+			}
+			MutateMapExpr(&n.Tag, owner_list, n)
+			bm(n.Body, MutateMapExprs)
+		
+		case *ast.CaseClause:
+			for j := range n.List {
+				MutateMapExpr(&n.List[j], nil, nil)
+			}
+			MutateStmtList(&n.Body, MutateMapExprs)
+		
+		case *ast.RangeStmt:
+			MutateMapExpr(&n.X, owner_list, n)
+			bm(n.Body, MutateMapExprs)
+		
+		case *ast.ExprStmt:
+			if call, is_call := n.X.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="delete" && len(call.Args)==2 {
+				MutateMapExpr(&call.Args[0], owner_list, n)
+				MutateMapExpr(&call.Args[1], owner_list, n)
+				n.X = MakeMethodCall(call.Args[0], "Remove", call.Args[1])
+				return
+			}
+			MutateMapExpr(&n.X, owner_list, n)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateMapExpr(&n.Results[i], owner_list, n)
+			}
+		
+		case *ast.IncDecStmt:
+			/// m[k]++ => m.SetValue(k, map_value + 1)
+			if map_index := GetMapIndex(n.X); map_index != nil {
+				map_type := GetMapType(map_index.X)
+				MutateMapExpr(&map_index.Index, owner_list, n)
+				op := token.ADD
+				if n.Tok==token.DEC {
+					op = token.SUB
+				}
+				set := new(ast.ExprStmt)
+				set.X = MakeMapSet(map_index, map_type, MakeBinaryExpr(HoistMapRead(owner_list, n, map_index, map_type), op, MakeBasicLit(token.INT, "1")))
+				ReplaceStmt(owner_list, n, set)
+				return
+			}
+			MutateMapExpr(&n.X, owner_list, n)
+		
+		case *ast.AssignStmt:
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if left_len==1 && rite_len==1 {
+				if map_index := GetMapIndex(n.Lhs[0]); map_index != nil {
+					/// m[k] = v | m[k] op= v
+					map_type := GetMapType(map_index.X)
+					MutateMapExpr(&map_index.Index, owner_list, n)
+					MutateMapExpr(&n.Rhs[0], owner_list, n)
+					value := n.Rhs[0]
+					if n.Tok != token.ASSIGN {
+						/// the op-assign tokens are in the same order as their binary op tokens.
+						value = MakeBinaryExpr(HoistMapRead(owner_list, n, map_index, map_type), n.Tok - token.ADD_ASSIGN + token.ADD, MakeParenExpr(value))
+					}
+					set := new(ast.ExprStmt)
+					set.X = MakeMapSet(map_index, map_type, value)
+					ReplaceStmt(owner_list, n, set)
+					return
+				}
+			}
+			
+			if rite_len==1 && left_len <= 2 {
+				if map_index := GetMapIndex(n.Rhs[0]); map_index != nil {
+					map_type := GetMapType(map_index.X)
+					MutateMapExpr(&map_index.Index, owner_list, n)
+					var found ast.Expr
+					if left_len==2 {
+						found = n.Lhs[1]
+					}
+					ReplaceStmt(owner_list, n, ExpandMapRead(map_index, map_type, n.Lhs[0], found, n.Tok==token.DEFINE)...)
+					return
+				}
+			}
+			
+			for i := range n.Lhs {
+				if GetMapIndex(n.Lhs[i]) != nil {
+					PrintSrcGoErr(n.Lhs[i].Pos(), "Assigning multiple map entries in one statement is not supported.")
+					return
+				}
+				MutateMapExpr(&n.Lhs[i], owner_list, n)
+			}
+			for i := range n.Rhs {
+				MutateMapExpr(&n.Rhs[i], owner_list, n)
+			}
+		
+		case *ast.DeclStmt:
+			g := n.Decl.(*ast.GenDecl)
+			if g.Tok != token.VAR {
+				return
+			}
+			new_stmts := make([]ast.Stmt, 0)
+			expanded := false
+			for _, spec := range g.Specs {
+				v := spec.(*ast.ValueSpec)
+				if len(v.Values)==1 && len(v.Names) <= 2 {
+					if map_index := GetMapIndex(v.Values[0]); map_index != nil {
+						map_type := GetMapType(map_index.X)
+						MutateMapExpr(&map_index.Index, owner_list, n)
+						var found ast.Expr
+						if len(v.Names)==2 {
+							found = v.Names[1]
+						}
+						new_stmts = append(new_stmts, ExpandMapRead(map_index, map_type, v.Names[0], found, true)...)
+						expanded = true
+						continue
+					}
+				}
+				for i := range v.Values {
+					MutateMapExpr(&v.Values[i], owner_list, n)
+				}
+				new_stmts = append(new_stmts, MakeDeclStmt(token.VAR, v))
+			}
+			if expanded {
+				ReplaceStmt(owner_list, n, new_stmts...)
+			}
+	}
+}
+
+/// moves map reads out into temporaries before 'anchor'.
+/// if there's no statement list to place the temporaries in, map reads are an error.
+func MutateMapExpr(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			MutateMapExpr(&n.X, owner_list, anchor)
+			MutateMapExpr(&n.Y, owner_list, anchor)
+		
+		case *ast.CallExpr:
+			MutateMapExpr(&n.Fun, owner_list, anchor)
+			for i := range n.Args {
+				MutateMapExpr(&n.Args[i], owner_list, anchor)
+			}
+		
+		case *ast.KeyValueExpr:
+			MutateMapExpr(&n.Key, owner_list, anchor)
+			MutateMapExpr(&n.Value, owner_list, anchor)
+		
+		case *ast.CompositeLit:
+			for i := range n.Elts {
+				MutateMapExpr(&n.Elts[i], owner_list, anchor)
+			}
+		
+		case *ast.IndexExpr:
+			map_type := GetMapType(n.X)
+			MutateMapExpr(&n.X, owner_list, anchor)
+			MutateMapExpr(&n.Index, owner_list, anchor)
+			if map_type != nil {
+				if owner_list==nil {
+					PrintSrcGoErr(n.Pos(), "Map reads are not allowed here, store the value in a variable first.")
+					return
+				}
+				*e = HoistMapRead(owner_list, anchor, n, map_type)
+			}
+		
+		case *ast.ParenExpr:
+			MutateMapExpr(&n.X, owner_list, anchor)
+		
+		case *ast.SelectorExpr:
+			MutateMapExpr(&n.X, owner_list, anchor)
+		
+		case *ast.StarExpr:
+			MutateMapExpr(&n.X, owner_list, anchor)
+		
+		case *ast.UnaryExpr:
+			MutateMapExpr(&n.X, owner_list, anchor)
+	}
+}
+
+
+/**
+ * Slices that are grown with 'append' or escape their function are backed by an ArrayList.
+ * Globals and returned slices escape, anything assigned to or from those spreads it to other variables and params.
+ * Slices of char are strings and are never ArrayLists.
+ * 
+ * x = append(x, v) => x.Push(v)
+ * x[i] = v         => x.Set(i, v)
+ * v := x[i]        => v = x.Get(i)
+ * len(x)           => x.Length
+ * 
+ * Arrays, enum structs and strings are the element's block size and use the Array and String methods.
+ * Scalar reads and 'len' are left for the generator to print so they keep their types.
+ * 
+ * Lists that a function makes and keeps to itself are deleted when it returns.
+ */
+func MutateSlices(file *ast.File) {
+	FindArrayLists(file)
+	fresh := make(map[*ast.FuncDecl]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					lists := FindOwnedLists(d.Body, fresh)
+					MutateBlock(d.Body, MutateSliceStmts)
+					InsertListDeletes(d.Body, lists)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/// the lists declared at the top of a function body that are made there and never leave it.
+func FindOwnedLists(body *ast.BlockStmt, fresh map[*ast.FuncDecl]bool) []*ast.Ident {
+	var names []*ast.Ident
+	for _, stmt := range body.List {
+		switch n := stmt.(type) {
+			case *ast.DeclStmt:
+				if g := n.Decl.(*ast.GenDecl); g.Tok==token.VAR {
+					for _, spec := range g.Specs {
+						names = append(names, spec.(*ast.ValueSpec).Names...)
+					}
+				}
+			case *ast.AssignStmt:
+				if n.Tok==token.DEFINE {
+					for _, e := range n.Lhs {
+						if iden, is_ident := e.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil {
+							names = append(names, iden)
+						}
+					}
+				}
+		}
+	}
+	var lists []*ast.Ident
+	for _, name := range names {
+		obj := ASTCtxt.TypeInfo.ObjectOf(name)
+		if obj != nil && IsArrayList(name) && IsOwnedList(body, obj, fresh) && !ListEscapes(body, obj, false, fresh, make(map[types.Object]bool)) {
+			lists = append(lists, name)
+		}
+	}
+	return lists
+}
+
+/// a list is owned when everything assigned to it is a new list.
+func IsOwnedList(body *ast.BlockStmt, obj types.Object, fresh map[*ast.FuncDecl]bool) bool {
+	if obj.Pos() < body.Pos() || obj.Pos() >= body.End() {
+		/// params belong to the caller.
+		return false
+	}
+	owned := true
+	is_obj := func(e ast.Expr) bool {
+		iden, is_ident := e.(*ast.Ident)
+		return is_ident && ASTCtxt.TypeInfo.ObjectOf(iden)==obj
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.AssignStmt:
+				for i, e := range x.Lhs {
+					if is_obj(e) {
+						owned = owned && len(x.Lhs)==len(x.Rhs) && MakesList(x.Rhs[i], fresh)
+					}
+				}
+			case *ast.ValueSpec:
+				for i, name := range x.Names {
+					if is_obj(name) && len(x.Values) > 0 {
+						owned = owned && i < len(x.Values) && MakesList(x.Values[i], fresh)
+					}
+				}
+			case *ast.RangeStmt:
+				if is_obj(x.Key) || (x.Value != nil && is_obj(x.Value)) {
+					owned = false
+				}
+		}
+		return owned
+	})
+	return owned
+}
+
+/// checks if an expression gives a new list: a literal, 'make', 'append' or a function that returns a list it made.
+func MakesList(e ast.Expr, fresh map[*ast.FuncDecl]bool) bool {
+	switch x := ast.Unparen(e).(type) {
+		case *ast.CompositeLit:
+			return true
+		case *ast.CallExpr:
+			if name := GetFuncName(x.Fun); name=="make" || name=="append" {
+				return true
+			}
+			iden, is_ident := x.Fun.(*ast.Ident)
+			if !is_ident {
+				return false
+			}
+			fn, found := ASTCtxt.FuncMap[iden.Name]
+			if !found || fn.Body==nil || fn.Recv != nil {
+				return false
+			} else if is_fresh, known := fresh[fn]; known {
+				return is_fresh
+			}
+			/// recursive calls don't count as new lists until the function's returns say so.
+			fresh[fn] = false
+			is_fresh := true
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch r := n.(type) {
+					case *ast.FuncLit:
+						return false
+					case *ast.ReturnStmt:
+						if len(r.Results)==0 {
+							is_fresh = false
+						} else if iden, is_ident := ast.Unparen(r.Results[0]).(*ast.Ident); is_ident {
+							obj := ASTCtxt.TypeInfo.ObjectOf(iden)
+							is_fresh = is_fresh && obj != nil && IsOwnedList(fn.Body, obj, fresh) && !ListEscapes(fn.Body, obj, true, fresh, make(map[types.Object]bool))
+						} else {
+							is_fresh = is_fresh && MakesList(r.Results[0], fresh)
+						}
+				}
+				return is_fresh
+			})
+			fresh[fn] = is_fresh
+			return is_fresh
+	}
+	return false
+}
+
+/// a list escapes when it's stored, returned or passed to anything but a function that keeps it to itself.
+func ListEscapes(body *ast.BlockStmt, obj types.Object, can_return bool, fresh map[*ast.FuncDecl]bool, seen map[types.Object]bool) bool {
+	if seen[obj] {
+		return false
+	}
+	seen[obj] = true
+	is_obj := func(e ast.Expr) bool {
+		iden, is_ident := ast.Unparen(e).(*ast.Ident)
+		return is_ident && ASTCtxt.TypeInfo.ObjectOf(iden)==obj
+	}
+	escapes := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.ReturnStmt:
+				for _, e := range x.Results {
+					escapes = escapes || (!can_return && is_obj(e))
+				}
+			case *ast.AssignStmt:
+				for _, e := range x.Rhs {
+					escapes = escapes || is_obj(e)
+				}
+			case *ast.ValueSpec:
+				for _, e := range x.Values {
+					escapes = escapes || is_obj(e)
+				}
+			case *ast.CompositeLit:
+				for _, e := range x.Elts {
+					if kv, is_kv := e.(*ast.KeyValueExpr); is_kv {
+						e = kv.Value
+					}
+					escapes = escapes || is_obj(e)
+				}
+			case *ast.UnaryExpr:
+				escapes = escapes || (x.Op==token.AND && is_obj(x.X))
+			case *ast.CallExpr:
+				name := GetFuncName(x.Fun)
+				switch name {
+					case "len", "cap", "copy":
+						return true
+					case "append":
+						for _, e := range x.Args[1:] {
+							escapes = escapes || is_obj(e)
+						}
+						return true
+				}
+				var params []*ast.Ident
+				if iden, is_ident := x.Fun.(*ast.Ident); is_ident {
+					if fn, found := ASTCtxt.FuncMap[iden.Name]; found && fn.Body != nil && fn.Recv==nil && fn.Type.Params != nil {
+						for _, field := range fn.Type.Params.List {
+							params = append(params, field.Names...)
+						}
+						for i, e := range x.Args {
+							if is_obj(e) && !x.Ellipsis.IsValid() && i < len(params) {
+								escapes = escapes || ListEscapes(fn.Body, ASTCtxt.TypeInfo.Defs[params[i]], false, fresh, seen)
+							} else if is_obj(e) {
+								escapes = true
+							}
+						}
+						return !escapes
+					}
+				}
+				for _, e := range x.Args {
+					escapes = escapes || is_obj(e)
+				}
+		}
+		return !escapes
+	})
+	return escapes
+}
+
+/// deletes the lists before every return after they're declared and at the end of the function.
+func InsertListDeletes(body *ast.BlockStmt, lists []*ast.Ident) {
+	for _, list := range lists {
+		start := -1
+		for i, stmt := range body.List {
+			if UsesIdent(stmt, list) {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			continue
+		}
+		name := list.Name
+		cleanup := []func() ast.Stmt{ func() ast.Stmt { return MakeExprStmt(MakeCall("delete", ast.NewIdent(name))) } }
+		for i := start+1; i < len(body.List); i++ {
+			old_len := len(body.List)
+			InsertDeferCleanups(&body.List, i, cleanup)
+			i += len(body.List) - old_len
+		}
+		if _, is_ret := body.List[len(body.List)-1].(*ast.ReturnStmt); !is_ret {
+			body.List = append(body.List, cleanup[0]())
+		}
+	}
+}
+
+/// checks if the node holds the very ident 'name', not just another ident of the same object.
+func UsesIdent(n ast.Node, name *ast.Ident) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		found = found || n==ast.Node(name)
+		return !found
+	})
+	return found
+}
+
+func GetSliceElem(e ast.Expr) types.Type {
+	if typ := ASTCtxt.TypeInfo.TypeOf(e); typ != nil {
+		if s, is_slice := typ.Underlying().(*types.Slice); is_slice && !IsCharType(s.Elem()) {
+			return s.Elem()
+		}
+	}
+	return nil
+}
+
+/// ArrayLists are tracked by where their variable is declared since objects are remade when re-checking types.
+func IsArrayList(e ast.Expr) bool {
+	switch x := e.(type) {
+		case *ast.Ident:
+			if obj := ASTCtxt.TypeInfo.ObjectOf(x); obj != nil {
+				return ASTCtxt.ArrayLists[obj.Pos()]
+			}
+		case *ast.SelectorExpr:
+			return IsArrayList(x.Sel)
+		case *ast.ParenExpr:
+			return IsArrayList(x.X)
+		case *ast.CallExpr:
+			/// every slice returned from a function is an ArrayList.
+			if iden, is_ident := x.Fun.(*ast.Ident); is_ident {
+				if _, is_builtin := ASTCtxt.TypeInfo.ObjectOf(iden).(*types.Builtin); is_builtin {
+					return false
+				}
+			}
+			if _, is_sig := ASTCtxt.TypeInfo.TypeOf(x.Fun).(*types.Signature); is_sig {
+				return GetSliceElem(x) != nil
+			}
+	}
+	return false
+}
+
+func MarkArrayList(e ast.Expr) bool {
+	if GetSliceElem(e)==nil || IsArrayList(e) {
+		return false
+	}
+	var iden *ast.Ident
+	switch x := e.(type) {
+		case *ast.Ident:
+			iden = x
+		case *ast.SelectorExpr:
+			iden = x.Sel
+		case *ast.ParenExpr:
+			return MarkArrayList(x.X)
+		default:
+			return false
+	}
+	if obj := ASTCtxt.TypeInfo.ObjectOf(iden); obj != nil && obj.Pos().IsValid() {
+		ASTCtxt.ArrayLists[obj.Pos()] = true
+		return true
+	}
+	return false
+}
+
+/// if either side of an assignment is an ArrayList, both are.
+func LinkArrayLists(lhs, rhs ast.Expr) bool {
+	if call, is_call := rhs.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="append" {
+		return MarkArrayList(lhs)
+	}
+	if IsArrayList(rhs) {
+		return MarkArrayList(lhs)
+	} else if IsArrayList(lhs) {
+		return MarkArrayList(rhs)
+	}
+	return false
+}
+
+func FindArrayLists(file *ast.File) {
+	ASTCtxt.ArrayLists = make(map[token.Pos]bool)
+	ASTCtxt.ArrayListMakes = make(map[*ast.CallExpr]bool)
+	ASTCtxt.SliceLens = make(map[token.Pos]ast.Expr)
+	for _, decl := range file.Decls {
+		if gen_decl, is_gen_decl := decl.(*ast.GenDecl); is_gen_decl && gen_decl.Tok==token.VAR {
+			for _, spec := range gen_decl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					MarkArrayList(name)
+				}
+			}
+		}
+	}
+	
+	for changed := true; changed; {
+		changed = false
+		for _, decl := range file.Decls {
+			fn, is_func := decl.(*ast.FuncDecl)
+			if !is_func || fn.Body==nil {
+				continue
+			}
+			returns_list := fn.Type.Results != nil && len(fn.Type.Results.List) > 0 && GetSliceElem(fn.Type.Results.List[0].Type) != nil
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch x := n.(type) {
+					case *ast.CallExpr:
+						if GetFuncName(x.Fun)=="append" && len(x.Args) > 0 {
+							changed = MarkArrayList(x.Args[0]) || changed
+						} else if callee, found := ASTCtxt.FuncMap[GetFuncName(x.Fun)]; found {
+							var params []*ast.Ident
+							for _, field := range callee.Type.Params.List {
+								params = append(params, field.Names...)
+							}
+							for i := 0; i < len(x.Args) && i < len(params); i++ {
+								changed = LinkArrayLists(params[i], x.Args[i]) || changed
+							}
+						}
+					case *ast.AssignStmt:
+						if len(x.Lhs)==len(x.Rhs) {
+							for i := range x.Lhs {
+								changed = LinkArrayLists(x.Lhs[i], x.Rhs[i]) || changed
+							}
+						}
+					case *ast.ValueSpec:
+						for i := 0; i < len(x.Names) && i < len(x.Values); i++ {
+							changed = LinkArrayLists(x.Names[i], x.Values[i]) || changed
+						}
+					case *ast.ReturnStmt:
+						if returns_list && len(x.Results) > 0 {
+							changed = MarkArrayList(x.Results[0]) || changed
+						}
+				}
+				return true
+			})
+		}
+	}
+}
+
+/// the cell size of an ArrayList's elements, nil means the default size of 1.
+func ArrayListBlockSize(elem types.Type) ast.Expr {
+	if IsStrType(elem) {
+		str_len := int64(StrBufferLen)
+		if arr, is_array := elem.Underlying().(*types.Array); is_array {
+			str_len = arr.Len()
+		}
+		return MakeCall("ByteCountToCells", MakeBasicLit(token.INT, fmt.Sprintf("%d", str_len)))
+	}
+	switch t := elem.Underlying().(type) {
+		case *types.Array:
+			return MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len()))
+		case *types.Struct:
+			if named, is_named := elem.(*types.Named); is_named {
+				return MakeCall("sizeof", ast.NewIdent(named.Obj().Name()))
+			}
+	}
+	return nil
+}
+
+/// make([]T, 0) that the generator prints as 'new ArrayList(blocksize)'.
+func MakeArrayListMake(slice ast.Expr) *ast.CallExpr {
+	mk := MakeCall("make", ValueToTypeExpr(slice), MakeBasicLit(token.INT, "0"))
+	ASTCtxt.ArrayListMakes[mk] = true
+	return mk
+}
+
+/// scalars can be read in place with 'Get', anything bigger needs a buffer.
+func IsListScalar(elem types.Type) bool {
+	if IsStrType(elem) {
+		return false
+	}
+	switch elem.Underlying().(type) {
+		case *types.Array, *types.Struct:
+			return false
+	}
+	return true
+}
+
+func MakeListGet(list, index ast.Expr, elem types.Type, dest ast.Expr) *ast.CallExpr {
+	if IsStrType(elem) {
+		return MakeMethodCall(list, "GetString", index, dest, MakeCall("sizeof", dest))
+	}
+	return MakeMethodCall(list, "GetArray", index, dest)
+}
+
+func MakeListSet(list, index ast.Expr, elem types.Type, value ast.Expr) *ast.CallExpr {
+	if IsStrType(elem) {
+		return MakeMethodCall(list, "SetString", index, value)
+	} else if IsListScalar(elem) {
+		return MakeMethodCall(list, "Set", index, value)
+	}
+	return MakeMethodCall(list, "SetArray", index, value)
+}
+
+func MakeListPush(list ast.Expr, elem types.Type, value ast.Expr) *ast.ExprStmt {
+	push := new(ast.ExprStmt)
+	if IsStrType(elem) {
+		push.X = MakeMethodCall(list, "PushString", value)
+	} else if IsListScalar(elem) {
+		push.X = MakeMethodCall(list, "Push", value)
+	} else {
+		push.X = MakeMethodCall(list, "PushArray", value)
+	}
+	return push
+}
+
+/// reads an ArrayList element into a new temporary declared before 'anchor' and returns the temporary.
+func HoistListRead(owner_list *[]ast.Stmt, anchor ast.Stmt, index *ast.IndexExpr, elem types.Type) *ast.Ident {
+	tmp := ast.NewIdent(fmt.Sprintf("list_value%d", ASTCtxt.TmpVar))
+	ASTCtxt.TmpVar++
+	get := new(ast.ExprStmt)
+	get.X = MakeListGet(index.X, index.Index, elem, tmp)
+	i := FindStmt(*owner_list, anchor)
+	*owner_list = InsertStmt(*owner_list, i, MakeMapValueDecl(tmp, elem))
+	*owner_list = InsertStmt(*owner_list, i+1, get)
+	return tmp
+}
+
+/// returns the index into an ArrayList that a selector or index chain starts from.
+func GetListIndexRoot(e ast.Expr) *ast.IndexExpr {
+	for {
+		switch x := e.(type) {
+			case *ast.IndexExpr:
+				if IsArrayList(x.X) {
+					return x
+				}
+				e = x.X
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.ParenExpr:
+				e = x.X
+			default:
+				return nil
+		}
+	}
+}
+
+/// x = []T{a, b} => x = make([]T, 0); x.Push(a); x.Push(b)
+func ExpandListLiteral(list ast.Expr, lit *ast.CompositeLit) []ast.Stmt {
+	elem := GetSliceElem(list)
+	var stmts []ast.Stmt
+	for _, value := range lit.Elts {
+		stmts = append(stmts, MakeListPush(list, elem, value))
+	}
+	return stmts
+}
+
+func MutateSliceStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateSliceStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil && HasListAccess(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateSliceStmts)
+				return
+			}
+			if HasListAccess(n.Cond) || HasListAccess(n.Post) {
+				PrintSrcGoErr(n.Pos(), "Reading arrays or enum structs from an ArrayList in a for-loop condition or post statement is not supported.")
+			}
+			bm(n.Body, MutateSliceStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil && HasListAccess(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateSliceStmts)
+				return
+			}
+			MutateSliceExpr(&n.Cond, owner_list, n)
+			bm(n.Body, MutateSliceStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && (HasListAccess(else_if.Init) || HasListAccess(else_if.Cond)) {
+				else_block := new(ast.BlockStmt)
+				else_block.List = append(else_block.List, else_if)
+				n.Else = else_block
+			}
+			if n.Else != nil {
+				MutateSliceStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			if n.Init != nil && HasListAccess(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateSliceStmts)
+				return
+			}
+			MutateSliceExpr(&n.Tag, owner_list, n)
+			bm(n.Body, MutateSliceStmts)
+		
+		case *ast.CaseClause:
+			for j := range n.List {
+				MutateSliceExpr(&n.List[j], nil, nil)
+			}
+			MutateStmtList(&n.Body, MutateSliceStmts)
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateSliceStmts)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateSliceExpr(&n.Results[i], owner_list, n)
+			}
+		
+		case *ast.ExprStmt:
+			if call, is_call := n.X.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="copy" && len(call.Args)==2 && (IsArrayList(call.Args[0]) || IsArrayList(call.Args[1])) {
+				/// copy(dst, src) => for i := 0; i < len(dst) && i < len(src); i++ { dst[i] = src[i] }
+				dst, src := call.Args[0], call.Args[1]
+				iter := ast.NewIdent(fmt.Sprintf("copy_iter%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				loop := new(ast.ForStmt)
+				init := MakeAssign(true)
+				init.Lhs = append(init.Lhs, iter)
+				init.Rhs = append(init.Rhs, MakeBasicLit(token.INT, "0"))
+				loop.Init = init
+				loop.Cond = MakeBinaryExpr(MakeBinaryExpr(iter, token.LSS, GetCopyLen(dst)), token.LAND, MakeBinaryExpr(iter, token.LSS, GetCopyLen(src)))
+				post := new(ast.IncDecStmt)
+				post.X = iter
+				post.Tok = token.INC
+				loop.Post = post
+				assign := MakeAssign(false)
+				assign.Lhs = append(assign.Lhs, MakeIndex(iter, dst))
+				assign.Rhs = append(assign.Rhs, MakeIndex(iter, src))
+				loop.Body = new(ast.BlockStmt)
+				loop.Body.List = append(loop.Body.List, assign)
+				ReplaceStmt(owner_list, n, loop)
+				bm(loop.Body, MutateSliceStmts)
+				return
+			}
+			MutateSliceExpr(&n.X, owner_list, n)
+		
+		case *ast.IncDecStmt:
+			/// x[i]++ => x.Set(i, x[i] + 1)
+			if list_index, is_index := n.X.(*ast.IndexExpr); is_index && IsArrayList(list_index.X) {
+				op := token.ADD
+				if n.Tok==token.DEC {
+					op = token.SUB
+				}
+				set := new(ast.ExprStmt)
+				set.X = MakeListSet(list_index.X, list_index.Index, GetSliceElem(list_index.X), MakeBinaryExpr(MakeIndex(list_index.Index, list_index.X), op, MakeBasicLit(token.INT, "1")))
+				ReplaceStmt(owner_list, n, set)
+			}
+		
+		case *ast.AssignStmt:
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if left_len != rite_len {
+				return
+			}
+			for i := range n.Rhs {
+				MarkListMake(n.Lhs[i], n.Rhs[i])
+				MarkSliceLen(owner_list, n, n.Lhs[i], n.Rhs[i])
+				if call, is_call := n.Rhs[i].(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="append" {
+					if !IsArrayList(n.Lhs[i]) {
+						PrintSrcGoErr(call.Pos(), "'append' only works on slices of numbers, arrays and enum structs, use 'StrCat' for strings.")
+						return
+					} else if left_len > 1 {
+						PrintSrcGoErr(n.Pos(), "'append' has to be assigned by itself.")
+						return
+					}
+					ReplaceStmt(owner_list, n, ExpandAppend(n, call)...)
+					return
+				}
+			}
+			
+			if left_len==1 {
+				if lit, is_lit := n.Rhs[0].(*ast.CompositeLit); is_lit && IsArrayList(n.Lhs[0]) {
+					n.Rhs[0] = MakeArrayListMake(n.Lhs[0])
+					ReplaceStmt(owner_list, n, append([]ast.Stmt{n}, ExpandListLiteral(n.Lhs[0], lit)...)...)
+					return
+				}
+				
+				if list_index := GetListIndexRoot(n.Lhs[0]); list_index != nil {
+					elem := GetSliceElem(list_index.X)
+					MutateSliceExpr(&list_index.Index, owner_list, n)
+					MutateSliceExpr(&n.Rhs[0], owner_list, n)
+					set := new(ast.ExprStmt)
+					if list_index==n.Lhs[0] {
+						/// x[i] = v | x[i] op= v
+						value := n.Rhs[0]
+						if n.Tok != token.ASSIGN {
+							value = MakeBinaryExpr(MakeIndex(list_index.Index, list_index.X), n.Tok - token.ADD_ASSIGN + token.ADD, MakeParenExpr(value))
+							if !IsListScalar(elem) {
+								PrintSrcGoErr(n.Pos(), "Op-assigning an array or enum struct in an ArrayList is not supported.")
+							}
+						}
+						set.X = MakeListSet(list_index.X, list_index.Index, elem, value)
+						ReplaceStmt(owner_list, n, set)
+						return
+					}
+					/// x[i].field = v => tmp = x[i]; tmp.field = v; x[i] = tmp
+					tmp := HoistListRead(owner_list, n, list_index, elem)
+					ReplaceListIndex(&n.Lhs[0], list_index, tmp)
+					set.X = MakeListSet(list_index.X, list_index.Index, elem, tmp)
+					*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, set)
+					return
+				}
+				
+				if list_index, is_index := n.Rhs[0].(*ast.IndexExpr); is_index && IsArrayList(list_index.X) {
+					if elem := GetSliceElem(list_index.X); !IsListScalar(elem) {
+						/// v := x[i] => var v T; x.GetArray(i, v)
+						MutateSliceExpr(&list_index.Index, owner_list, n)
+						new_stmts := make([]ast.Stmt, 0)
+						if iden, is_ident := n.Lhs[0].(*ast.Ident); is_ident && n.Tok==token.DEFINE {
+							new_stmts = append(new_stmts, MakeMapValueDecl(iden, elem))
+						}
+						get := new(ast.ExprStmt)
+						get.X = MakeListGet(list_index.X, list_index.Index, elem, n.Lhs[0])
+						ReplaceStmt(owner_list, n, append(new_stmts, get)...)
+						return
+					}
+				}
+			}
+			for i := range n.Lhs {
+				MutateSliceExpr(&n.Lhs[i], owner_list, n)
+			}
+			for i := range n.Rhs {
+				MutateSliceExpr(&n.Rhs[i], owner_list, n)
+			}
+		
+		case *ast.DeclStmt:
+			g := n.Decl.(*ast.GenDecl)
+			if g.Tok != token.VAR {
+				return
+			}
+			var pushes []ast.Stmt
+			specs := make([]ast.Spec, 0, len(g.Specs))
+			for _, spec := range g.Specs {
+				v := spec.(*ast.ValueSpec)
+				if len(v.Values)==0 {
+					/// local ArrayLists are created where they're declared so every name gets its own spec.
+					for _, name := range v.Names {
+						name_spec := &ast.ValueSpec{ Names: []*ast.Ident{name}, Type: v.Type }
+						if IsArrayList(name) && !IsAssignedFirst(*owner_list, n, name) {
+							name_spec.Values = append(name_spec.Values, MakeArrayListMake(name))
+						}
+						specs = append(specs, name_spec)
+					}
+					continue
+				}
+				for i := 0; i < len(v.Names) && i < len(v.Values); i++ {
+					if lit, is_lit := v.Values[i].(*ast.CompositeLit); is_lit && IsArrayList(v.Names[i]) {
+						v.Values[i] = MakeArrayListMake(v.Names[i])
+						pushes = append(pushes, ExpandListLiteral(v.Names[i], lit)...)
+					} else {
+						MarkListMake(v.Names[i], v.Values[i])
+						MutateSliceExpr(&v.Values[i], owner_list, n)
+					}
+				}
+				specs = append(specs, v)
+			}
+			g.Specs = specs
+			if pushes != nil {
+				ReplaceStmt(owner_list, n, append([]ast.Stmt{n}, pushes...)...)
+			}
+	}
+}
+
+/// checks if the first statement after 's' that uses 'name' assigns to it, a list that's assigned before it's used doesn't need to be made.
+/// 'l := Collect()' is split into a declaration at the top of its block and an assignment that can be a few statements later.
+func IsAssignedFirst(list []ast.Stmt, s ast.Stmt, name *ast.Ident) bool {
+	i := FindStmt(list, s)
+	if i < 0 {
+		return false
+	}
+	obj := ASTCtxt.TypeInfo.ObjectOf(name)
+	for _, stmt := range list[i+1:] {
+		if !UsesObject(stmt, obj) {
+			continue
+		}
+		switch x := stmt.(type) {
+			case *ast.IfStmt:
+				stmt = x.Init
+			case *ast.SwitchStmt:
+				stmt = x.Init
+			case *ast.ForStmt:
+				stmt = x.Init
+		}
+		assign, is_assign := stmt.(*ast.AssignStmt)
+		if !is_assign || assign.Tok != token.ASSIGN || len(assign.Lhs) != len(assign.Rhs) {
+			return false
+		}
+		for j, e := range assign.Lhs {
+			if iden, is_ident := e.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.ObjectOf(iden)==obj {
+				return !UsesObject(assign.Rhs[j], obj)
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func UsesObject(n ast.Node, obj types.Object) bool {
+	found := false
+	if n != nil {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if iden, is_ident := n.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.ObjectOf(iden)==obj {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+/// a 'make' of a slice that's an ArrayList is printed as 'new ArrayList(blocksize, len)'.
+func MarkListMake(list, value ast.Expr) {
+	if call, is_call := value.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="make" && IsArrayList(list) {
+		ASTCtxt.ArrayListMakes[call] = true
+	}
+}
+
+/// a slice that's not an ArrayList is a 'new T[len]' array, which has no size to take, so 'copy' uses its make length.
+func MarkSliceLen(owner_list *[]ast.Stmt, n *ast.AssignStmt, list, value ast.Expr) {
+	call, is_call := value.(*ast.CallExpr)
+	iden, is_ident := list.(*ast.Ident)
+	if !is_call || !is_ident || GetFuncName(call.Fun) != "make" || len(call.Args) < 2 || GetSliceElem(list)==nil || IsArrayList(list) {
+		return
+	}
+	obj := ASTCtxt.TypeInfo.ObjectOf(iden)
+	if obj==nil {
+		return
+	}
+	length := call.Args[1]
+	if tv := ASTCtxt.TypeInfo.Types[length]; tv.Value==nil {
+		/// a variable length can change after the make, so it's kept for the copies of the slice.
+		if n.Tok != token.DEFINE || len(n.Lhs) != 1 || !HasSliceCopy(*owner_list, obj) {
+			return
+		}
+		len_var := ast.NewIdent(fmt.Sprintf("%s_len%d", iden.Name, ASTCtxt.TmpVar))
+		ASTCtxt.TmpVar++
+		len_decl := MakeAssign(true)
+		len_decl.Lhs = append(len_decl.Lhs, len_var)
+		len_decl.Rhs = append(len_decl.Rhs, length)
+		*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n), len_decl)
+		call.Args[1] = ast.NewIdent(len_var.Name)
+		length = ast.NewIdent(len_var.Name)
+	}
+	ASTCtxt.SliceLens[obj.Pos()] = length
+}
+
+/// checks if the slice 'obj' is copied into or from in 'stmts'.
+func HasSliceCopy(stmts []ast.Stmt, obj types.Object) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if call, is_call := n.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="copy" {
+				for _, arg := range call.Args {
+					if iden := GetListIdent(arg); iden != nil && ASTCtxt.TypeInfo.ObjectOf(iden)==obj {
+						found = true
+					}
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+/// the length of a side of 'copy', slices that aren't ArrayLists give the length they were made with.
+func GetCopyLen(e ast.Expr) ast.Expr {
+	if GetSliceElem(e)==nil || IsArrayList(e) {
+		return MakeCall("len", e)
+	}
+	if iden := GetListIdent(e); iden != nil {
+		if obj := ASTCtxt.TypeInfo.ObjectOf(iden); obj != nil {
+			if length, found := ASTCtxt.SliceLens[obj.Pos()]; found {
+				return length
+			}
+		}
+	}
+	PrintSrcGoErr(e.Pos(), "Copying a slice needs it to be made in the same block with 'make', or to be an ArrayList.")
+	return MakeCall("len", e)
+}
+
+/// x = append(x, a, b) => x.Push(a); x.Push(b)
+func ExpandAppend(n *ast.AssignStmt, call *ast.CallExpr) []ast.Stmt {
+	list := n.Lhs[0]
+	elem := GetSliceElem(list)
+	if call.Ellipsis.IsValid() {
+		PrintSrcGoErr(call.Ellipsis, "Appending a spread slice to an ArrayList is not supported.")
+		return []ast.Stmt{n}
+	} else if n.Tok==token.DEFINE || types.ExprString(list) != types.ExprString(call.Args[0]) {
+		PrintSrcGoErr(n.Pos(), "'append' has to assign back to the slice that it grows.")
+		return []ast.Stmt{n}
+	}
+	
+	var stmts []ast.Stmt
+	if obj := ASTCtxt.TypeInfo.ObjectOf(GetListIdent(list)); obj == nil || obj.Parent()==nil || obj.Parent()==obj.Pkg().Scope() {
+		/// globals and fields might not be made yet.
+		create := MakeAssign(false)
+		create.Lhs = append(create.Lhs, list)
+		create.Rhs = append(create.Rhs, MakeArrayListMake(list))
+		guard := new(ast.IfStmt)
+		guard.Cond = MakeBinaryExpr(list, token.EQL, ast.NewIdent("nil"))
+		guard.Body = new(ast.BlockStmt)
+		guard.Body.List = append(guard.Body.List, create)
+		stmts = append(stmts, guard)
+	}
+	for _, value := range call.Args[1:] {
+		stmts = append(stmts, MakeListPush(list, elem, value))
+	}
+	return stmts
+}
+
+func GetListIdent(e ast.Expr) *ast.Ident {
+	switch x := e.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.SelectorExpr:
+			return x.Sel
+		case *ast.ParenExpr:
+			return GetListIdent(x.X)
+	}
+	return nil
+}
+
+/// swaps the ArrayList index at the root of a selector or index chain with 'repl'.
+func ReplaceListIndex(e *ast.Expr, list_index *ast.IndexExpr, repl ast.Expr) {
+	switch x := (*e).(type) {
+		case *ast.IndexExpr:
+			if x==list_index {
+				*e = repl
+				return
+			}
+			ReplaceListIndex(&x.X, list_index, repl)
+		case *ast.SelectorExpr:
+			ReplaceListIndex(&x.X, list_index, repl)
+		case *ast.ParenExpr:
+			ReplaceListIndex(&x.X, list_index, repl)
+	}
+}
+
+/// checks if a node reads an array, string or enum struct out of an ArrayList.
+func HasListAccess(node ast.Node) bool {
+	if node==nil {
+		return false
+	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if x, is_index := n.(*ast.IndexExpr); is_index && IsArrayList(x.X) && !IsListScalar(GetSliceElem(x.X)) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+/// moves reads of arrays, strings and enum structs in ArrayLists out into temporaries before 'anchor'.
+func MutateSliceExpr(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			MutateSliceExpr(&n.X, owner_list, anchor)
+			MutateSliceExpr(&n.Y, owner_list, anchor)
+		
+		case *ast.CallExpr:
+			MutateSliceExpr(&n.Fun, owner_list, anchor)
+			for i := range n.Args {
+				MutateSliceExpr(&n.Args[i], owner_list, anchor)
+			}
+		
+		case *ast.KeyValueExpr:
+			MutateSliceExpr(&n.Key, owner_list, anchor)
+			MutateSliceExpr(&n.Value, owner_list, anchor)
+		
+		case *ast.CompositeLit:
+			for i := range n.Elts {
+				MutateSliceExpr(&n.Elts[i], owner_list, anchor)
+			}
+		
+		case *ast.IndexExpr:
+			is_list := IsArrayList(n.X)
+			MutateSliceExpr(&n.X, owner_list, anchor)
+			MutateSliceExpr(&n.Index, owner_list, anchor)
+			if elem := GetSliceElem(n.X); is_list && !IsListScalar(elem) {
+				if owner_list==nil {
+					PrintSrcGoErr(n.Pos(), "ArrayList reads are not allowed here, store the value in a variable first.")
+					return
+				}
+				*e = HoistListRead(owner_list, anchor, n, elem)
+			}
+		
+		case *ast.ParenExpr:
+			MutateSliceExpr(&n.X, owner_list, anchor)
+		
+		case *ast.SelectorExpr:
+			MutateSliceExpr(&n.X, owner_list, anchor)
+		
+		case *ast.StarExpr:
+			MutateSliceExpr(&n.X, owner_list, anchor)
+		
+		case *ast.UnaryExpr:
+			MutateSliceExpr(&n.X, owner_list, anchor)
+	}
+}
+
+
+/**
+ * Struct literals are lowered into a zeroed enum struct that gets the literal's fields assigned in declaration order.
+ * SourcePawn zeroes every variable it declares, so fields the literal leaves out are already zero.
+ * 
+ * p := Player{Health: 100, Pos: Vec{1.0, 2.0}}
+ * v = Vec{Y: 3.0}
+ * Take(Player{Health: 5})
+ * 
+ * Becomes:
+ * var p Player
+ * p.Health = 100
+ * p.Pos.X = 1.0
+ * p.Pos.Y = 2.0
+ * var struct_lit0 Vec
+ * struct_lit0.Y = 3.0
+ * v = struct_lit0
+ * var struct_lit1 Player
+ * struct_lit1.Health = 5
+ * Take(struct_lit1)
+ * 
+ * enum structs can't be initialized from another variable either, so 'q := p' becomes 'var q Player; q = p'.
+ * enum struct params are passed by reference, so a struct param the function changes is copied into a local.
+ */
+func MutateStructLits(file *ast.File) {
+	/// the plugin's own structs become enum structs, the structs of the bindings are methodmaps.
+	ASTCtxt.EnumStructs = make(map[types.Object]bool)
+	for _, decl := range file.Decls {
+		if g, is_gen := decl.(*ast.GenDecl); is_gen && g.Tok==token.TYPE {
+			for _, spec := range g.Specs {
+				if type_spec := spec.(*ast.TypeSpec); !type_spec.Assign.IsValid() {
+					if _, is_struct := type_spec.Type.(*ast.StructType); is_struct {
+						ASTCtxt.EnumStructs[ASTCtxt.TypeInfo.Defs[type_spec.Name]] = true
+					}
+				}
+			}
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					CopyStructParams(d)
+					MutateBlock(d.Body, MutateStructLitStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/// the struct type of a named struct value, the only structs that have an enum struct.
+func GetStructType(e ast.Expr) *types.Struct {
+	if e==nil {
+		return nil
+	}
+	if named, is_named := ASTCtxt.TypeInfo.TypeOf(e).(*types.Named); is_named {
+		if struct_type, is_struct := named.Underlying().(*types.Struct); is_struct {
+			return struct_type
+		}
+	}
+	return nil
+}
+
+/// x.field with the type information the later passes look up before the AST is type-checked again.
+func MakeFieldSelector(x ast.Expr, field *types.Var) *ast.SelectorExpr {
+	sel := MakeSelector(x, field.Name())
+	ASTCtxt.TypeInfo.Uses[sel.Sel] = field
+	ASTCtxt.TypeInfo.Types[sel] = types.TypeAndValue{Type: field.Type()}
+	return sel
+}
+
+/// the values of a struct literal in the order of the struct's fields, nil for the fields it leaves out.
+func GetStructLitValues(lit *ast.CompositeLit, struct_type *types.Struct) []ast.Expr {
+	values := make([]ast.Expr, struct_type.NumFields())
+	if lit==nil {
+		return values
+	}
+	for i, elt := range lit.Elts {
+		if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+			key := kv.Key.(*ast.Ident)
+			for j := 0; j < struct_type.NumFields(); j++ {
+				if struct_type.Field(j).Name()==key.Name {
+					values[j] = kv.Value
+				}
+			}
+		} else if i < len(values) {
+			values[i] = elt
+		}
+	}
+	return values
+}
+
+/// dest = T{...} as assignments to each field of 'dest', embedded structs are flattened so their fields go into 'dest'.
+func ExpandStructLit(dest ast.Expr, lit *ast.CompositeLit, struct_type *types.Struct) []ast.Stmt {
+	var stmts []ast.Stmt
+	for i, value := range GetStructLitValues(lit, struct_type) {
+		if value==nil {
+			continue
+		}
+		field := struct_type.Field(i)
+		if field.Embedded() {
+			if nested, is_lit := value.(*ast.CompositeLit); is_lit {
+				stmts = append(stmts, ExpandStructLit(dest, nested, field.Type().Underlying().(*types.Struct))...)
+			} else {
+				stmts = append(stmts, CopyFlatFields(dest, value, field.Type().Underlying().(*types.Struct))...)
+			}
+			continue
+		}
+		stmts = append(stmts, ExpandFieldValue(MakeFieldSelector(dest, field), value)...)
+	}
+	return stmts
+}
+
+/// dest = value, struct and array literals are assigned element by element since SourcePawn only has initializer lists for declarations.
+func ExpandFieldValue(dest ast.Expr, value ast.Expr) []ast.Stmt {
+	if lit, is_lit := value.(*ast.CompositeLit); is_lit {
+		if struct_type := GetStructType(lit); struct_type != nil {
+			return ExpandStructLit(dest, lit, struct_type)
+		}
+		if array_type, is_array := ASTCtxt.TypeInfo.TypeOf(lit).Underlying().(*types.Array); is_array {
+			var stmts []ast.Stmt
+			index := int64(0)
+			for _, elt := range lit.Elts {
+				if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+					if key := ASTCtxt.TypeInfo.Types[kv.Key].Value; key != nil {
+						index, _ = constant.Int64Val(key)
+					}
+					elt = kv.Value
+				}
+				elem := MakeIndex(MakeBasicLit(token.INT, fmt.Sprintf("%d", index)), dest)
+				ASTCtxt.TypeInfo.Types[elem] = types.TypeAndValue{Type: array_type.Elem()}
+				stmts = append(stmts, ExpandFieldValue(elem, elt)...)
+				index++
+			}
+			return stmts
+		}
+	}
+	assign := MakeAssign(false)
+	assign.Lhs = append(assign.Lhs, dest)
+	assign.Rhs = append(assign.Rhs, value)
+	return []ast.Stmt{assign}
+}
+
+/// an embedded struct has no field of its own in the enum struct, so it's copied into 'dest' field by field.
+func CopyFlatFields(dest, src ast.Expr, struct_type *types.Struct) []ast.Stmt {
+	var stmts []ast.Stmt
+	switch src.(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+		default:
+			/// don't evaluate the value once for every field.
+			tmp := MakeTypedIdent(fmt.Sprintf("struct_lit%d", ASTCtxt.TmpVar), ASTCtxt.TypeInfo.TypeOf(src))
+			ASTCtxt.TmpVar++
+			assign := MakeAssign(false)
+			assign.Lhs = append(assign.Lhs, tmp)
+			assign.Rhs = append(assign.Rhs, src)
+			stmts = append(stmts, MakeVarDecl([]*ast.Ident{tmp}, src, nil), assign)
+			src = tmp
+	}
+	for i := 0; i < struct_type.NumFields(); i++ {
+		field := struct_type.Field(i)
+		if field.Embedded() {
+			stmts = append(stmts, CopyFlatFields(dest, src, field.Type().Underlying().(*types.Struct))...)
+			continue
+		}
+		assign := MakeAssign(false)
+		assign.Lhs = append(assign.Lhs, MakeFieldSelector(dest, field))
+		assign.Rhs = append(assign.Rhs, MakeFieldSelector(src, field))
+		stmts = append(stmts, assign)
+	}
+	return stmts
+}
+
+/// var name T; name = value, or the expanded literal if 'value' is one.
+func MakeStructDecl(name *ast.Ident, typ ast.Expr, value ast.Expr) []ast.Stmt {
+	if typ==nil {
+		typ = ValueToTypeExpr(value)
+	}
+	stmts := []ast.Stmt{MakeTypedVarDecl([]*ast.Ident{name}, typ)}
+	stmts = append(stmts, ExpandFieldValue(name, value)...)
+	/// the expanded fields can have struct literals of their own.
+	MutateStmtList(&stmts, MutateStructLitStmts)
+	return stmts
+}
+
+/// moves a struct literal into a new temporary declared before 'anchor' and returns the temporary.
+func HoistStructLit(owner_list *[]ast.Stmt, anchor ast.Stmt, lit *ast.CompositeLit) *ast.Ident {
+	tmp := MakeTypedIdent(fmt.Sprintf("struct_lit%d", ASTCtxt.TmpVar), ASTCtxt.TypeInfo.TypeOf(lit))
+	ASTCtxt.TmpVar++
+	i := FindStmt(*owner_list, anchor)
+	for j, stmt := range MakeStructDecl(tmp, lit.Type, lit) {
+		*owner_list = InsertStmt(*owner_list, i+j, stmt)
+	}
+	return tmp
+}
+
+/// [2]Pos{{1.0, 2.0}, {X: 3.0}}, local arrays of struct literals are assigned element by element like the struct literals in them.
+func IsStructArrayLit(e ast.Expr) bool {
+	lit, is_lit := e.(*ast.CompositeLit)
+	if !is_lit {
+		return false
+	} else if _, is_array := ASTCtxt.TypeInfo.TypeOf(lit).Underlying().(*types.Array); !is_array {
+		return false
+	}
+	for _, elt := range lit.Elts {
+		if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+			elt = kv.Value
+		}
+		if HasStructLit(elt) {
+			return true
+		}
+	}
+	return false
+}
+
+/// checks if a node has a struct literal that has to be moved out into a temporary.
+func HasStructLit(node ast.Node) bool {
+	if node==nil {
+		return false
+	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if lit, is_lit := n.(*ast.CompositeLit); is_lit && GetStructType(lit) != nil {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func MutateStructLitStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateNoRetCallStmts)
+			bm(n, MutateStructLitStmts)
 		
 		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateNoRetCallStmts(owner_list, index, n.Init, bm)
+			if n.Init != nil && HasStructLit(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStructLitStmts)
+				return
+			}
+			if HasStructLit(n.Cond) || HasStructLit(n.Post) {
+				PrintSrcGoErr(n.Pos(), "Struct literals in a for-loop condition or post statement are not supported.")
+			} else {
+				MutateStructLitExpr(&n.Cond, nil, nil)
+			}
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil && HasStructLit(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStructLitStmts)
+				return
+			}
+			MutateStructLitExpr(&n.Cond, owner_list, n)
+			bm(n.Body, MutateStructLitStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && (HasStructLit(else_if.Init) || HasStructLit(else_if.Cond)) {
+				else_block := new(ast.BlockStmt)
+				else_block.List = append(else_block.List, else_if)
+				n.Else = else_block
+			}
+			if n.Else != nil {
+				MutateStructLitStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			if n.Init != nil && HasStructLit(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStructLitStmts)
+				return
+			}
+			MutateStructLitExpr(&n.Tag, owner_list, n)
+			if n.Tag != nil && IsEnumStruct(ASTCtxt.TypeInfo.TypeOf(n.Tag)) {
+				PrintSrcGoErr(n.Tag.Pos(), "Switching on a struct is not supported, compare it in the cases instead.")
+			}
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.CaseClause:
+			for j := range n.List {
+				MutateStructLitExpr(&n.List[j], nil, nil)
+			}
+			MutateStmtList(&n.Body, MutateStructLitStmts)
+		
+		case *ast.RangeStmt:
+			MutateStructLitExpr(&n.X, owner_list, n)
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateStructLitExpr(&n.Results[i], owner_list, n)
+			}
+		
+		case *ast.ExprStmt:
+			MutateStructLitExpr(&n.X, owner_list, n)
+		
+		case *ast.AssignStmt:
+			if n.Tok==token.DEFINE && len(n.Lhs)==len(n.Rhs) {
+				/// p := T{...} | q := p
+				var new_stmts []ast.Stmt
+				has_struct := false
+				for i := range n.Lhs {
+					iden, is_ident := n.Lhs[i].(*ast.Ident)
+					if is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil && (GetStructType(n.Rhs[i]) != nil || IsStructArrayLit(n.Rhs[i])) {
+						new_stmts = append(new_stmts, MakeStructDecl(iden, nil, n.Rhs[i])...)
+						has_struct = true
+						continue
+					}
+					MutateStructLitExpr(&n.Rhs[i], owner_list, n)
+					assign := MakeAssign(is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil)
+					assign.Lhs = append(assign.Lhs, n.Lhs[i])
+					assign.Rhs = append(assign.Rhs, n.Rhs[i])
+					new_stmts = append(new_stmts, assign)
+				}
+				if has_struct {
+					ReplaceStmt(owner_list, n, new_stmts...)
+					return
+				}
+			}
+			for i := range n.Lhs {
+				MutateStructLitExpr(&n.Lhs[i], owner_list, n)
+			}
+			for i := range n.Rhs {
+				MutateStructLitExpr(&n.Rhs[i], owner_list, n)
+			}
+		
+		case *ast.DeclStmt:
+			/// var p T = T{...} | var q = p
+			g := n.Decl.(*ast.GenDecl)
+			if g.Tok != token.VAR {
+				return
+			}
+			var new_stmts []ast.Stmt
+			has_struct := false
+			for _, spec := range g.Specs {
+				v := spec.(*ast.ValueSpec)
+				if len(v.Values) != len(v.Names) {
+					for i := range v.Values {
+						MutateStructLitExpr(&v.Values[i], owner_list, n)
+					}
+					new_stmts = append(new_stmts, MakeDeclStmt(token.VAR, v))
+					continue
+				}
+				for i, name := range v.Names {
+					if GetStructType(v.Values[i]) != nil || IsStructArrayLit(v.Values[i]) {
+						new_stmts = append(new_stmts, MakeStructDecl(name, v.Type, v.Values[i])...)
+						has_struct = true
+						continue
+					}
+					MutateStructLitExpr(&v.Values[i], owner_list, n)
+					name_spec := &ast.ValueSpec{ Names: []*ast.Ident{name}, Type: v.Type, Values: []ast.Expr{v.Values[i]} }
+					new_stmts = append(new_stmts, MakeDeclStmt(token.VAR, name_spec))
+				}
+			}
+			if has_struct {
+				ReplaceStmt(owner_list, n, new_stmts...)
+			}
+	}
+}
+
+/// moves struct literals out into temporaries before 'anchor' and compares structs field by field.
+func MutateStructLitExpr(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
+			MutateStructLitExpr(&n.Y, owner_list, anchor)
+			if (n.Op==token.EQL || n.Op==token.NEQ) && IsEnumStruct(ASTCtxt.TypeInfo.TypeOf(n.X)) {
+				*e = MakeStructCompare(n, owner_list, anchor)
 			}
-			if n.Post != nil {
-				MutateNoRetCallStmts(owner_list, index, n.Post, bm)
+		
+		case *ast.CallExpr:
+			MutateStructLitExpr(&n.Fun, owner_list, anchor)
+			for i := range n.Args {
+				MutateStructLitExpr(&n.Args[i], owner_list, anchor)
 			}
-			bm(n.Body, MutateNoRetCallStmts)
 		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateNoRetCallStmts(owner_list, index, n.Init, bm)
+		case *ast.KeyValueExpr:
+			MutateStructLitExpr(&n.Value, owner_list, anchor)
+		
+		case *ast.CompositeLit:
+			if GetStructType(n) == nil && !IsStructArrayLit(n) {
+				/// fixed arrays keep their initializer list.
+				if _, is_array := ASTCtxt.TypeInfo.TypeOf(n).Underlying().(*types.Array); !is_array {
+					for i := range n.Elts {
+						MutateStructLitExpr(&n.Elts[i], owner_list, anchor)
+					}
+				}
+				return
 			}
-			bm(n.Body, MutateNoRetCallStmts)
-			if n.Else != nil {
-				MutateNoRetCallStmts(owner_list, index, n.Else, bm)
+			if owner_list==nil {
+				PrintSrcGoErr(n.Pos(), "Struct literals are not allowed here, store the value in a variable first.")
+				return
 			}
+			*e = HoistStructLit(owner_list, anchor, n)
 		
-		case *ast.SwitchStmt:
-			MutateNoRetCallStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateNoRetCallStmts)
+		case *ast.IndexExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
+			MutateStructLitExpr(&n.Index, owner_list, anchor)
 		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateNoRetCallStmts(&n.Body, i, stmt, bm)
-			}
+		case *ast.ParenExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
 		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateNoRetCallStmts)
+		case *ast.SelectorExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
 		
-		case *ast.ExprStmt:
-			if fn, is_func_call := n.X.(*ast.CallExpr); is_func_call {
-				if typ := ASTCtxt.TypeInfo.TypeOf(fn); typ != nil {
-					switch t := typ.(type) {
-						case *types.Tuple:
-							extra_args := t.Len()
-							if IsFuncPtr(fn) {
-								if extra_args > 1 {
-									retvals := make([]ast.Expr, 0)
-									rettypes := make([]types.Type, 0)
-									for i:=0; i<extra_args; i++ {
-										ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-										ASTCtxt.TmpVar++
-										declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.At(i).Type())
-										*owner_list = InsertStmt(*owner_list, 0, declstmt)
-										retvals = append(retvals, ret_tmp)
-										rettypes = append(rettypes, t.At(i).Type())
-									}
-									
-									calls := ExpandFuncPtrCalls(fn, retvals, rettypes)
-									for i := len(calls)-1; i>0; i-- {
-										*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
-									}
-									n.X = calls[0].(*ast.ExprStmt).X
-								} else {
-									calls := ExpandFuncPtrCalls(fn, nil, nil)
-									n.X = calls[0].(*ast.ExprStmt).X
-									for i := len(calls)-1; i>0; i-- {
-										*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
-									}
-								}
-							} else {
-								if extra_args > 1 {
-									for i:=1; i<extra_args; i++ {
-										ret_tmp := ast.NewIdent(fmt.Sprintf("fn_temp%d", ASTCtxt.TmpVar))
-										ASTCtxt.TmpVar++
-										declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.At(i).Type())
-										*owner_list = InsertStmt(*owner_list, 0, declstmt)
-										fn.Args = append(fn.Args, MakeReference(ret_tmp))
-									}
-								}
-							}
-						
-						default:
-							if IsFuncPtr(fn) {
-								ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-								ASTCtxt.TmpVar++
-								declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t)
-								*owner_list = InsertStmt(*owner_list, 0, declstmt)
-								
-								calls := ExpandFuncPtrCalls(fn, []ast.Expr{ret_tmp}, []types.Type{t})
-								n.X = calls[0].(*ast.ExprStmt).X
-								for i := len(calls)-1; i>0; i-- {
-									*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, n)+1, calls[i])
-								}
-							}
+		case *ast.StarExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
+		
+		case *ast.UnaryExpr:
+			MutateStructLitExpr(&n.X, owner_list, anchor)
+	}
+}
+
+/// checks if a type is one of the plugin's structs, which become enum structs.
+func IsEnumStruct(t types.Type) bool {
+	named, is_named := t.(*types.Named)
+	return is_named && ASTCtxt.EnumStructs[named.Obj()]
+}
+
+/**
+ * p == q
+ * 
+ * Becomes:
+ * (p.a == q.a && p.b == q.b)
+ * 
+ * enum structs can't be compared as a whole, 'p != q' is true if any field differs.
+ */
+func MakeStructCompare(n *ast.BinaryExpr, owner_list *[]ast.Stmt, anchor ast.Stmt) ast.Expr {
+	x, y := StoreStructValue(n.X, owner_list, anchor), StoreStructValue(n.Y, owner_list, anchor)
+	if x==nil || y==nil {
+		return n
+	}
+	join := token.LAND
+	if n.Op==token.NEQ {
+		join = token.LOR
+	}
+	var cmp ast.Expr
+	for _, field_cmp := range MakeValueCompares(x, y, ASTCtxt.TypeInfo.TypeOf(n.X), n.Op) {
+		if cmp==nil {
+			cmp = field_cmp
+		} else {
+			cmp = MakeBinaryExpr(cmp, join, field_cmp)
+			ASTCtxt.TypeInfo.Types[cmp] = types.TypeAndValue{Type: types.Typ[types.Bool]}
+		}
+	}
+	if cmp==nil {
+		/// a struct without fields always equals another.
+		return ast.NewIdent(fmt.Sprintf("%t", n.Op==token.EQL))
+	}
+	return MakeParenExpr(cmp)
+}
+
+/// a struct operand that isn't a variable is stored in a temporary so it's evaluated once.
+func StoreStructValue(e ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) ast.Expr {
+	if paren, is_paren := e.(*ast.ParenExpr); is_paren {
+		return StoreStructValue(paren.X, owner_list, anchor)
+	}
+	for x := e; ; {
+		switch n := x.(type) {
+			case *ast.Ident:
+				return e
+			case *ast.SelectorExpr:
+				x = n.X
+				continue
+			case *ast.IndexExpr:
+				x = n.X
+				continue
+			case *ast.StarExpr:
+				x = n.X
+				continue
+			case *ast.ParenExpr:
+				x = n.X
+				continue
+		}
+		break
+	}
+	if owner_list==nil {
+		PrintSrcGoErr(e.Pos(), "Structs can only be compared here as variables, store the value in a variable first.")
+		return nil
+	}
+	tmp := MakeTypedIdent(fmt.Sprintf("struct_cmp%d", ASTCtxt.TmpVar), ASTCtxt.TypeInfo.TypeOf(e))
+	ASTCtxt.TmpVar++
+	i := FindStmt(*owner_list, anchor)
+	for j, stmt := range MakeStructDecl(tmp, nil, e) {
+		*owner_list = InsertStmt(*owner_list, i+j, stmt)
+	}
+	return tmp
+}
+
+/// x op y for each field and element of x and y, embedded structs are flattened like their fields are.
+/// methodmaps are handles, so they're compared as they are.
+func MakeValueCompares(x, y ast.Expr, typ types.Type, op token.Token) []ast.Expr {
+	if !IsStrType(typ) {
+		switch t := typ.Underlying().(type) {
+			case *types.Struct:
+				if !IsEnumStruct(typ) {
+					break
+				}
+				var cmps []ast.Expr
+				for i := 0; i < t.NumFields(); i++ {
+					field := t.Field(i)
+					if field.Embedded() {
+						cmps = append(cmps, MakeValueCompares(x, y, field.Type(), op)...)
+						continue
+					}
+					cmps = append(cmps, MakeValueCompares(MakeFieldSelector(x, field), MakeFieldSelector(y, field), field.Type(), op)...)
+				}
+				return cmps
+			
+			case *types.Array:
+				var cmps []ast.Expr
+				for i := int64(0); i < t.Len(); i++ {
+					elems := [2]*ast.IndexExpr{}
+					for j, arr := range []ast.Expr{x, y} {
+						elems[j] = MakeIndex(MakeBasicLit(token.INT, fmt.Sprintf("%d", i)), arr)
+						ASTCtxt.TypeInfo.Types[elems[j]] = types.TypeAndValue{Type: t.Elem()}
+					}
+					cmps = append(cmps, MakeValueCompares(elems[0], elems[1], t.Elem(), op)...)
+				}
+				return cmps
+		}
+	}
+	cmp := MakeBinaryExpr(x, op, y)
+	ASTCtxt.TypeInfo.Types[cmp] = types.TypeAndValue{Type: types.Typ[types.Bool]}
+	return []ast.Expr{cmp}
+}
+
+/**
+ * func Heal(p Player) {
+ *     p.Health = 100
+ * }
+ * 
+ * Becomes:
+ * func Heal(p_param Player) {
+ *     var p Player
+ *     p = p_param
+ *     p.Health = 100
+ * }
+ */
+func CopyStructParams(f *ast.FuncDecl) {
+	var copies []ast.Stmt
+	for _, field := range f.Type.Params.List {
+		for _, name := range field.Names {
+			param := ASTCtxt.TypeInfo.Defs[name]
+			if param==nil || GetStructType(name)==nil || !IsVarModified(f.Body, param) {
+				continue
+			}
+			local := MakeTypedIdent(name.Name, param.Type())
+			name.Name += "_param"
+			assign := MakeAssign(false)
+			assign.Lhs = append(assign.Lhs, local)
+			assign.Rhs = append(assign.Rhs, MakeTypedIdent(name.Name, param.Type()))
+			copies = append(copies, MakeTypedVarDecl([]*ast.Ident{local}, field.Type), assign)
+		}
+	}
+	f.Body.List = append(copies, f.Body.List...)
+}
+
+/// checks if 'obj' is assigned to, incremented or has its address taken in 'node'.
+func IsVarModified(node ast.Node, obj types.Object) bool {
+	is_obj := func(e ast.Expr) bool {
+		for {
+			switch x := e.(type) {
+				case *ast.Ident:
+					return ASTCtxt.TypeInfo.Uses[x]==obj
+				case *ast.SelectorExpr:
+					e = x.X
+				case *ast.IndexExpr:
+					e = x.X
+				case *ast.ParenExpr:
+					e = x.X
+				default:
+					return false
+			}
+		}
+	}
+	modified := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					modified = modified || is_obj(lhs)
+				}
+			case *ast.IncDecStmt:
+				modified = modified || is_obj(x.X)
+			case *ast.UnaryExpr:
+				modified = modified || (x.Op==token.AND && is_obj(x.X))
+			case *ast.SelectorExpr:
+				/// methods with pointer receivers can change the struct too.
+				if sel := ASTCtxt.TypeInfo.Selections[x]; sel != nil && sel.Kind()==types.MethodVal {
+					if _, ptr_recv := sel.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer); ptr_recv {
+						modified = modified || is_obj(x.X)
 					}
 				}
+		}
+		return !modified
+	})
+	return modified
+}
+
+
+/// checks for a '//srcgo:name' comment directive.
+func HasDirective(doc *ast.CommentGroup, name string) bool {
+	if doc==nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text)=="//srcgo:" + name {
+			return true
+		}
+	}
+	return false
+}
+
+func IsFloatType(t types.Type) bool {
+	if basic, is_basic := t.Underlying().(*types.Basic); is_basic {
+		return basic.Info() & types.IsFloat > 0
+	}
+	return false
+}
+
+/// arrays and enum structs are passed by their cell size.
+func IsArrayOrStruct(t types.Type) bool {
+	switch t.Underlying().(type) {
+		case *types.Array, *types.Struct:
+			return !IsStrType(t)
+	}
+	return false
+}
+
+/**
+ * Functions marked with '//srcgo:native' are registered as natives with a wrapper that reads the native's params.
+ * 
+ * //srcgo:native
+ * func GetBossHealth(boss BaseBoss, name string) int {}
+ * 
+ * Becomes:
+ * func Native_GetBossHealth(plugin Handle, numParams int) any {
+ *     var boss BaseBoss = GetNativeCell(1)
+ *     var name_len int
+ *     GetNativeStringLength(2, &name_len)
+ *     name_len++
+ *     name := make([]char, name_len)
+ *     GetNativeString(2, name, name_len)
+ *     return GetBossHealth(boss, name)
+ * }
+ * 
+ * Bodiless functions marked with '//srcgo:forward' become global forwards, calling them calls a dispatch function.
+ * 
+ * //srcgo:forward
+ * func OnBossKilled(boss BaseBoss, victim int) Action
+ * 
+ * Becomes:
+ * var g_fwdOnBossKilled GlobalForward
+ * func Call_OnBossKilled(boss BaseBoss, victim int) Action {
+ *     var fwd_result Action
+ *     Call_StartForward(g_fwdOnBossKilled)
+ *     Call_PushCell(boss)
+ *     Call_PushCell(victim)
+ *     Call_Finish(&fwd_result)
+ *     return fwd_result
+ * }
+ * 
+ * Both are created in AskPluginLoad2, which is made if the plugin doesn't have one.
+ */
+func MutateNatives(file *ast.File, library string) {
+	ASTCtxt.Natives, ASTCtxt.Forwards = nil, nil
+	var registers []ast.Stmt
+	var ask_plugin_load *ast.FuncDecl
+	renames := make(map[types.Object]string)
+	new_decls := make([]ast.Decl, 0, len(file.Decls))
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func {
+			new_decls = append(new_decls, decl)
+			continue
+		}
+		switch {
+			case f.Name.Name=="AskPluginLoad2" && f.Recv==nil:
+				ask_plugin_load = f
+			
+			case HasDirective(f.Doc, "native"):
+				if f.Recv != nil || f.Body==nil {
+					PrintSrcGoErr(f.Pos(), "Natives have to be functions with a body.")
+					break
+				}
+				ASTCtxt.Natives = append(ASTCtxt.Natives, f)
+				wrapper := MakeNativeWrapper(f)
+				new_decls = append(new_decls, f, wrapper)
+				register := new(ast.ExprStmt)
+				register.X = MakeCall("CreateNative", MakeBasicLit(token.STRING, fmt.Sprintf("%q", f.Name.Name)), ast.NewIdent(wrapper.Name.Name))
+				registers = append(registers, register)
+				continue
+			
+			case HasDirective(f.Doc, "forward"):
+				if f.Recv != nil || f.Body != nil {
+					PrintSrcGoErr(f.Pos(), "Forwards have to be functions without a body.")
+					break
+				}
+				ASTCtxt.Forwards = append(ASTCtxt.Forwards, f)
+				fwd_var := ast.NewIdent("g_fwd" + f.Name.Name)
+				fwd_spec := new(ast.ValueSpec)
+				fwd_spec.Names = append(fwd_spec.Names, fwd_var)
+				fwd_spec.Type = ast.NewIdent("GlobalForward")
+				fwd_decl := MakeDeclStmt(token.VAR, fwd_spec).Decl
+				
+				dispatch, param_types := MakeForwardCall(f, fwd_var)
+				new_decls = append(new_decls, fwd_decl, dispatch)
+				renames[ASTCtxt.TypeInfo.Defs[f.Name]] = dispatch.Name.Name
+				
+				exec_type := "ET_Single"
+				if f.Type.Results==nil {
+					exec_type = "ET_Ignore"
+				} else if ret, is_ident := f.Type.Results.List[0].Type.(*ast.Ident); is_ident && ret.Name=="Action" {
+					exec_type = "ET_Event"
+				}
+				create := MakeAssign(false)
+				create.Lhs = append(create.Lhs, fwd_var)
+				create.Rhs = append(create.Rhs, MakeCall("CreateGlobalForward", append([]ast.Expr{MakeBasicLit(token.STRING, fmt.Sprintf("%q", f.Name.Name)), ast.NewIdent(exec_type)}, param_types...)...))
+				registers = append(registers, create)
+				continue
+		}
+		new_decls = append(new_decls, decl)
+	}
+	file.Decls = new_decls
+	if registers==nil {
+		return
+	}
+	
+	for iden, obj := range ASTCtxt.TypeInfo.Uses {
+		if name, found := renames[obj]; found {
+			iden.Name = name
+		}
+	}
+	
+	reg_lib := new(ast.ExprStmt)
+	reg_lib.X = MakeCall("RegPluginLibrary", MakeBasicLit(token.STRING, fmt.Sprintf("%q", library)))
+	registers = append(registers, reg_lib)
+	if ask_plugin_load != nil {
+		ask_plugin_load.Body.List = append(registers, ask_plugin_load.Body.List...)
+		return
+	}
+	
+	/// func AskPluginLoad2(myself Handle, late bool, error *[]char, err_max int) APLRes
+	ask_plugin_load = new(ast.FuncDecl)
+	ask_plugin_load.Name = ast.NewIdent("AskPluginLoad2")
+	ask_plugin_load.Type = new(ast.FuncType)
+	ask_plugin_load.Type.Params = new(ast.FieldList)
+	param_names := []string{"myself", "late", "error", "err_max"}
+	param_types := []ast.Expr{ast.NewIdent("Handle"), ast.NewIdent("bool"), PtrizeExpr(Arrayify(ast.NewIdent("char"), nil)), ast.NewIdent("int")}
+	for i := range param_names {
+		field := new(ast.Field)
+		field.Names = append(field.Names, ast.NewIdent(param_names[i]))
+		field.Type = param_types[i]
+		ask_plugin_load.Type.Params.List = append(ask_plugin_load.Type.Params.List, field)
+	}
+	ask_plugin_load.Type.Results = new(ast.FieldList)
+	ask_plugin_load.Type.Results.List = append(ask_plugin_load.Type.Results.List, &ast.Field{Type: ast.NewIdent("APLRes")})
+	ret := new(ast.ReturnStmt)
+	ret.Results = append(ret.Results, ast.NewIdent("APLRes_Success"))
+	ask_plugin_load.Body = new(ast.BlockStmt)
+	ask_plugin_load.Body.List = append(registers, ret)
+	file.Decls = append(file.Decls, ask_plugin_load)
+}
+
+/// gives every param a name so it can be used as a local variable.
+func GetParamNames(fn *ast.FuncDecl) ([]*ast.Ident, []ast.Expr) {
+	var names []*ast.Ident
+	var typs []ast.Expr
+	for _, field := range fn.Type.Params.List {
+		if len(field.Names)==0 {
+			names = append(names, ast.NewIdent(fmt.Sprintf("%s_param%d", fn.Name.Name, len(names))))
+			typs = append(typs, field.Type)
+		}
+		for _, name := range field.Names {
+			if name.Name=="_" {
+				names = append(names, ast.NewIdent(fmt.Sprintf("%s_param%d", fn.Name.Name, len(names))))
+			} else {
+				names = append(names, ast.NewIdent(name.Name))
+			}
+			typs = append(typs, field.Type)
+		}
+	}
+	return names, typs
+}
+
+func MakeVarInit(name *ast.Ident, typ, value ast.Expr) *ast.DeclStmt {
+	decl_stmt := MakeTypedVarDecl([]*ast.Ident{name}, typ)
+	val_spec := decl_stmt.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	val_spec.Values = append(val_spec.Values, value)
+	return decl_stmt
+}
+
+func MakeExprStmt(e ast.Expr) *ast.ExprStmt {
+	expr_stmt := new(ast.ExprStmt)
+	expr_stmt.X = e
+	return expr_stmt
+}
+
+/// reads a native's param into a local, 'post' writes back params that are passed by reference.
+/// 'size' is the param after it, the size of a '[]char' buffer that the native writes to.
+func MakeNativeParam(param int, name *ast.Ident, typ ast.Expr, size *ast.Ident) (pre []ast.Stmt, arg ast.Expr, post []ast.Stmt) {
+	param_num := MakeBasicLit(token.INT, fmt.Sprintf("%d", param))
+	t := ASTCtxt.TypeInfo.TypeOf(typ)
+	arg = name
+	if t==nil {
+		PrintSrcGoErr(typ.Pos(), "Unknown type for native param.")
+		return
+	}
+	
+	if ptr, is_ptr := t.(*types.Pointer); is_ptr {
+		elem_type := typ.(*ast.StarExpr).X
+		arg = MakeReference(name)
+		switch {
+			case IsStrType(ptr.Elem()):
+				PrintSrcGoErr(typ.Pos(), "Natives can't write to string params.")
+			case IsArrayOrStruct(ptr.Elem()):
+				pre = append(pre, MakeTypedVarDecl([]*ast.Ident{name}, elem_type))
+				pre = append(pre, MakeExprStmt(MakeCall("GetNativeArray", param_num, name, MakeCall("sizeof", name))))
+				post = append(post, MakeExprStmt(MakeCall("SetNativeArray", param_num, name, MakeCall("sizeof", name))))
+			default:
+				pre = append(pre, MakeVarInit(name, elem_type, MakeCall("GetNativeCellRef", param_num)))
+				post = append(post, MakeExprStmt(MakeCall("SetNativeCellRef", param_num, name)))
+		}
+		return
+	}
+	
+	switch {
+		case IsStrType(t):
+			if arr, is_array := t.Underlying().(*types.Array); is_array {
+				pre = append(pre, MakeTypedVarDecl([]*ast.Ident{name}, typ))
+				pre = append(pre, MakeExprStmt(MakeCall("GetNativeString", param_num, name, MakeBasicLit(token.INT, fmt.Sprintf("%d", arr.Len())))))
+				break
+			} else if IsCharBuffer(t) {
+				/// buffers are as big as the caller says and are written back.
+				if size==nil {
+					PrintSrcGoErr(typ.Pos(), "Natives that write to a '[]char' need its size as the next param.")
+					return
+				}
+				buffer := MakeAssign(true)
+				buffer.Lhs = append(buffer.Lhs, name)
+				buffer.Rhs = append(buffer.Rhs, MakeCall("make", Arrayify(ast.NewIdent("char"), nil), size))
+				pre = append(pre, buffer)
+				pre = append(pre, MakeExprStmt(MakeCall("GetNativeString", param_num, name, size)))
+				post = append(post, MakeExprStmt(MakeCall("SetNativeString", param_num, name, size)))
+				break
+			}
+			/// strings are read into a buffer sized by their length.
+			str_len := ast.NewIdent(name.Name + "_len")
+			pre = append(pre, MakeTypedVarDecl([]*ast.Ident{str_len}, ast.NewIdent("int")))
+			pre = append(pre, MakeExprStmt(MakeCall("GetNativeStringLength", param_num, MakeReference(str_len))))
+			inc := new(ast.IncDecStmt)
+			inc.X = str_len
+			inc.Tok = token.INC
+			pre = append(pre, inc)
+			buffer := MakeAssign(true)
+			buffer.Lhs = append(buffer.Lhs, name)
+			buffer.Rhs = append(buffer.Rhs, MakeCall("make", Arrayify(ast.NewIdent("char"), nil), str_len))
+			pre = append(pre, buffer)
+			pre = append(pre, MakeExprStmt(MakeCall("GetNativeString", param_num, name, str_len)))
+		case IsArrayOrStruct(t):
+			pre = append(pre, MakeTypedVarDecl([]*ast.Ident{name}, typ))
+			pre = append(pre, MakeExprStmt(MakeCall("GetNativeArray", param_num, name, MakeCall("sizeof", name))))
+		default:
+			if _, is_slice := t.Underlying().(*types.Slice); is_slice {
+				PrintSrcGoErr(typ.Pos(), "Natives can't take slices of unknown size.")
+			}
+			pre = append(pre, MakeVarInit(name, typ, MakeCall("GetNativeCell", param_num)))
+	}
+	return
+}
+
+/// func Native_Name(plugin Handle, numParams int) any
+func MakeNativeWrapper(f *ast.FuncDecl) *ast.FuncDecl {
+	wrapper := new(ast.FuncDecl)
+	wrapper.Name = ast.NewIdent("Native_" + f.Name.Name)
+	wrapper.Type = new(ast.FuncType)
+	wrapper.Type.Params = new(ast.FieldList)
+	wrapper.Type.Params.List = append(wrapper.Type.Params.List,
+		&ast.Field{Names: []*ast.Ident{ast.NewIdent("plugin")}, Type: ast.NewIdent("Handle")},
+		&ast.Field{Names: []*ast.Ident{ast.NewIdent("numParams")}, Type: ast.NewIdent("int")})
+	wrapper.Type.Results = new(ast.FieldList)
+	wrapper.Type.Results.List = append(wrapper.Type.Results.List, &ast.Field{Type: ast.NewIdent("any")})
+	wrapper.Body = new(ast.BlockStmt)
+	
+	names, typs := GetParamNames(f)
+	var args []ast.Expr
+	var copy_backs, buffer_reads []ast.Stmt
+	for i := range names {
+		var size *ast.Ident
+		if i+1 < len(names) {
+			if t := ASTCtxt.TypeInfo.TypeOf(typs[i+1]); t != nil && types.Identical(t, types.Typ[types.Int]) {
+				size = names[i+1]
+			}
+		}
+		pre, arg, post := MakeNativeParam(i+1, names[i], typs[i], size)
+		if t := ASTCtxt.TypeInfo.TypeOf(typs[i]); t != nil && IsCharBuffer(t) {
+			/// a buffer is read once its size is.
+			buffer_reads = pre
+		} else {
+			wrapper.Body.List = append(append(wrapper.Body.List, pre...), buffer_reads...)
+			buffer_reads = nil
+		}
+		args = append(args, arg)
+		copy_backs = append(copy_backs, post...)
+	}
+	wrapper.Body.List = append(wrapper.Body.List, buffer_reads...)
+	
+	call := MakeCall(f.Name.Name, args...)
+	ret := new(ast.ReturnStmt)
+	if f.Type.Results==nil {
+		wrapper.Body.List = append(wrapper.Body.List, MakeExprStmt(call))
+		ret.Results = append(ret.Results, MakeBasicLit(token.INT, "0"))
+	} else {
+		ret_type := f.Type.Results.List[0].Type
+		if t := ASTCtxt.TypeInfo.TypeOf(ret_type); t != nil && (IsStrType(t) || IsArrayOrStruct(t)) {
+			PrintSrcGoErr(ret_type.Pos(), "Natives can only return cells, pass strings and arrays as params instead.")
+		}
+		if copy_backs==nil {
+			ret.Results = append(ret.Results, call)
+		} else {
+			result := ast.NewIdent(f.Name.Name + "_result")
+			wrapper.Body.List = append(wrapper.Body.List, MakeVarInit(result, ret_type, call))
+			ret.Results = append(ret.Results, result)
+		}
+	}
+	wrapper.Body.List = append(wrapper.Body.List, copy_backs...)
+	wrapper.Body.List = append(wrapper.Body.List, ret)
+	return wrapper
+}
+
+/// makes the forward's dispatch function and returns it with the forward's param types.
+func MakeForwardCall(f *ast.FuncDecl, fwd_var *ast.Ident) (*ast.FuncDecl, []ast.Expr) {
+	dispatch := new(ast.FuncDecl)
+	dispatch.Name = ast.NewIdent("Call_" + f.Name.Name)
+	dispatch.Type = f.Type
+	dispatch.Body = new(ast.BlockStmt)
+	
+	var result *ast.Ident
+	if f.Type.Results != nil {
+		result = ast.NewIdent("fwd_result")
+		dispatch.Body.List = append(dispatch.Body.List, MakeTypedVarDecl([]*ast.Ident{result}, f.Type.Results.List[0].Type))
+	}
+	dispatch.Body.List = append(dispatch.Body.List, MakeExprStmt(MakeCall("Call_StartForward", fwd_var)))
+	
+	var param_types []ast.Expr
+	names, typs := GetParamNames(f)
+	for i, field_name := range names {
+		/// unnamed params are given names for the dispatch function.
+		SetParamName(f, i, field_name)
+		t := ASTCtxt.TypeInfo.TypeOf(typs[i])
+		if t==nil {
+			continue
+		}
+		param_type, push := "Param_Cell", MakeCall("Call_PushCell", field_name)
+		if ptr, is_ptr := t.(*types.Pointer); is_ptr {
+			switch {
+				case IsStrType(ptr.Elem()):
+					PrintSrcGoErr(typs[i].Pos(), "Forwards can't write to string params.")
+				case IsArrayOrStruct(ptr.Elem()):
+					param_type, push = "Param_Array", MakeCall("Call_PushArrayEx", field_name, MakeCall("sizeof", field_name), ast.NewIdent("SM_PARAM_COPYBACK"))
+				case IsFloatType(ptr.Elem()):
+					param_type, push = "Param_FloatByRef", MakeCall("Call_PushFloatRef", MakeReference(field_name))
+				default:
+					param_type, push = "Param_CellByRef", MakeCall("Call_PushCellRef", MakeReference(field_name))
+			}
+		} else if IsStrType(t) {
+			param_type, push = "Param_String", MakeCall("Call_PushString", field_name)
+		} else if IsArrayOrStruct(t) {
+			param_type, push = "Param_Array", MakeCall("Call_PushArray", field_name, MakeCall("sizeof", field_name))
+		} else if IsFloatType(t) {
+			param_type, push = "Param_Float", MakeCall("Call_PushFloat", field_name)
+		} else if _, is_slice := t.Underlying().(*types.Slice); is_slice {
+			PrintSrcGoErr(typs[i].Pos(), "Forwards can't take slices of unknown size.")
+		}
+		param_types = append(param_types, ast.NewIdent(param_type))
+		dispatch.Body.List = append(dispatch.Body.List, MakeExprStmt(push))
+	}
+	
+	if result != nil {
+		dispatch.Body.List = append(dispatch.Body.List, MakeExprStmt(MakeCall("Call_Finish", MakeReference(result))))
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, result)
+		dispatch.Body.List = append(dispatch.Body.List, ret)
+	} else {
+		dispatch.Body.List = append(dispatch.Body.List, MakeExprStmt(MakeCall("Call_Finish")))
+	}
+	return dispatch, param_types
+}
+
+/// renames the i'th param, unnamed params are split into their own field.
+func SetParamName(f *ast.FuncDecl, i int, name *ast.Ident) {
+	n := 0
+	for _, field := range f.Type.Params.List {
+		if len(field.Names)==0 {
+			if n==i {
+				field.Names = append(field.Names, name)
+				return
+			}
+			n++
+			continue
+		}
+		for j := range field.Names {
+			if n==i {
+				if field.Names[j].Name != name.Name {
+					field.Names[j] = name
+				}
+				return
+			}
+			n++
+		}
+	}
+}
+
+
+/**
+ * Strings are char arrays so concatenating them needs a buffer to format into.
+ * The generator prints 's = a + b' as a Format call and 's += a' as StrCat calls,
+ * any other concatenation is moved into a buffer first.
+ * 
+ * s := "name: " + name
+ * 
+ * Becomes:
+ * var s string   /// char s[N] where N fits all the parts.
+ * s = "name: " + name
+ */
+func MutateStrings(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					CopyStrParams(d)
+					MutateBlock(d.Body, MutateStrStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/**
+ * string params are 'const char[]' of any size, so a string param the function changes is copied into a buffer.
+ * func Greet(msg string) {
+ *     msg += "!"
+ * }
+ * 
+ * Becomes:
+ * func Greet(msg_param string) {
+ *     var msg string   /// char msg[256]
+ *     msg = msg_param
+ *     msg += "!"
+ * }
+ */
+func CopyStrParams(f *ast.FuncDecl) {
+	var copies []ast.Stmt
+	for _, field := range f.Type.Params.List {
+		for _, name := range field.Names {
+			param := ASTCtxt.TypeInfo.Defs[name]
+			if param==nil || !IsVarModified(f.Body, param) {
+				continue
+			} else if basic, is_basic := param.Type().(*types.Basic); !is_basic || basic.Info() & types.IsString==0 {
+				continue
 			}
+			local := MakeTypedIdent(name.Name, param.Type())
+			name.Name += "_param"
+			assign := MakeAssign(false)
+			assign.Lhs = append(assign.Lhs, local)
+			assign.Rhs = append(assign.Rhs, MakeTypedIdent(name.Name, param.Type()))
+			copies = append(copies, MakeTypedVarDecl([]*ast.Ident{local}, field.Type), assign)
+		}
 	}
+	f.Body.List = append(copies, f.Body.List...)
 }
 
-/// a &^ b ==> a & ^(b) ==> a & ~(b) in sp.
-func MutateAndNotExpr(file *ast.File) {
-	ast.Inspect(file, func(n ast.Node) bool {
-		if n != nil {
-			switch x := n.(type) {
-				case *ast.BinaryExpr:
-					if x.Op==token.AND_NOT {
-						x.Op = token.AND
-						x.Y = MakeBitNotExpr(MakeParenExpr(x.Y))
-					}
-				case *ast.AssignStmt:
-					if x.Tok==token.AND_NOT_ASSIGN {
-						x.Tok = token.AND_ASSIGN
-						for i := range x.Rhs {
-							x.Rhs[i] = MakeBitNotExpr(MakeParenExpr(x.Rhs[i]))
-						}
-					}
+/// checks if an expression concatenates strings that aren't constant.
+func IsStrConcat(e ast.Expr) bool {
+	bin, is_bin := e.(*ast.BinaryExpr)
+	if !is_bin || bin.Op != token.ADD {
+		return false
+	}
+	tv, found := ASTCtxt.TypeInfo.Types[e]
+	return found && tv.Value==nil && tv.Type != nil && IsStrType(tv.Type)
+}
+
+/// a + b + c => [a, b, c]
+func GetStrConcatParts(e ast.Expr) []ast.Expr {
+	if paren, is_paren := e.(*ast.ParenExpr); is_paren && IsStrConcat(paren.X) {
+		return GetStrConcatParts(paren.X)
+	}
+	if IsStrConcat(e) {
+		bin := e.(*ast.BinaryExpr)
+		return append(GetStrConcatParts(bin.X), GetStrConcatParts(bin.Y)...)
+	}
+	return []ast.Expr{e}
+}
+
+/// the size of a buffer that can fit every part, including the null terminator.
+func GetStrConcatSize(parts []ast.Expr) int {
+	size := 1
+	for _, part := range parts {
+		size += GetStrPartSize(part)
+	}
+	return size
+}
+
+/// the most chars a value can take up when it's formatted into a string.
+func GetStrPartSize(part ast.Expr) int {
+	tv := ASTCtxt.TypeInfo.Types[part]
+	switch {
+		case tv.Value != nil && tv.Value.Kind()==constant.String:
+			return len(constant.StringVal(tv.Value))
+		case tv.Type==nil:
+			return StrBufferLen - 1
+		case IsStrType(tv.Type):
+			if arr, is_array := tv.Type.Underlying().(*types.Array); is_array {
+				return int(arr.Len()) - 1
 			}
+			return StrBufferLen - 1
+		case IsFloatType(tv.Type):
+			return 32
+	}
+	/// -2147483648
+	return 11
+}
+
+/// the size of a buffer that fits the result of Sprintf, its format has to be constant to know it.
+func GetSprintfSize(call *ast.CallExpr) int {
+	if len(call.Args)==0 {
+		return 1
+	}
+	tv := ASTCtxt.TypeInfo.Types[call.Args[0]]
+	if tv.Value==nil || tv.Value.Kind() != constant.String {
+		return StrBufferLen
+	}
+	size := len(constant.StringVal(tv.Value)) + 1
+	for _, arg := range call.Args[1:] {
+		size += GetStrPartSize(arg)
+	}
+	return size
+}
+
+/// returns the name of a call to the fmt bindings, or the real fmt package, empty if it's not one.
+func GetFmtFunc(e ast.Expr) string {
+	switch name := GetPkgFunc(e, "fmt"); name {
+		case "Sprintf", "Printf", "Println", "Errorf":
+			return name
+	}
+	return ""
+}
+
+/// returns the name of a function called from 'pkg', either the real package or its bindings in '<pkg>.go'.
+func GetPkgFunc(e ast.Expr, pkg string) string {
+	call, is_call := e.(*ast.CallExpr)
+	if !is_call {
+		return ""
+	}
+	var iden *ast.Ident
+	switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			iden = fn
+		case *ast.SelectorExpr:
+			iden = fn.Sel
+		default:
+			return ""
+	}
+	obj := ASTCtxt.TypeInfo.ObjectOf(iden)
+	if obj==nil {
+		return ""
+	} else if in_pkg := obj.Pkg() != nil && obj.Pkg().Path()==pkg; !in_pkg && filepath.Base(ASTCtxt.FSet.Position(obj.Pos()).Filename) != pkg + ".go" {
+		return ""
+	}
+	return iden.Name
+}
+
+/// the size of the buffer a concatenation or Sprintf is built into.
+func GetStrBuildSize(e ast.Expr) int {
+	if call, is_call := e.(*ast.CallExpr); is_call {
+		if GetFmtFunc(call)=="Sprintf" {
+			return GetSprintfSize(call)
 		}
-		return true
-	})
+		/// other calls give back a whole string.
+		return StrBufferLen
+	}
+	return GetStrConcatSize(GetStrConcatParts(e))
 }
 
+/// checks for expressions that build a string into a buffer, concatenations and Sprintf.
+func IsStrBuild(e ast.Expr) bool {
+	return IsStrConcat(e) || GetFmtFunc(e)=="Sprintf"
+}
 
-/// func(params){code}(args) => func _srcgo_func#(params){code} ... _srcgo_func#(args)
-func MutateFuncLit(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+/// declares a string buffer sized to fit 'concat' and assigns it.
+func MakeStrBuffer(name *ast.Ident, concat ast.Expr) []ast.Stmt {
+	ASTCtxt.StrBufferSizes[name] = MakeBasicLit(token.INT, fmt.Sprintf("%d", GetStrBuildSize(concat)))
+	assign := MakeAssign(false)
+	assign.Lhs = append(assign.Lhs, name)
+	assign.Rhs = append(assign.Rhs, concat)
+	return []ast.Stmt{MakeTypedVarDecl([]*ast.Ident{name}, ast.NewIdent("string")), assign}
+}
+
+func MutateStrStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
 	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateFuncLit)
+			bm(n, MutateStrStmts)
 		
 		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateFuncLit(owner_list, index, n.Init, bm)
+			if n.Init != nil && HasStrConcat(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStrStmts)
+				return
 			}
-			if n.Cond != nil {
-				MutateFuncLitExprs(&n.Cond)
-			}
-			if n.Post != nil {
-				MutateFuncLit(owner_list, index, n.Post, bm)
+			if HasStrConcat(n.Cond) || HasStrConcat(n.Post) {
+				PrintSrcGoErr(n.Pos(), "Concatenating strings in a for-loop condition or post statement is not supported.")
 			}
-			bm(n.Body, MutateFuncLit)
+			bm(n.Body, MutateStrStmts)
 		
 		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateFuncLit(owner_list, index, n.Init, bm)
+			if n.Init != nil && HasStrConcat(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStrStmts)
+				return
+			}
+			MutateStrExpr(&n.Cond, owner_list, n)
+			bm(n.Body, MutateStrStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && (HasStrConcat(else_if.Init) || HasStrConcat(else_if.Cond)) {
+				else_block := new(ast.BlockStmt)
+				else_block.List = append(else_block.List, else_if)
+				n.Else = else_block
 			}
-			MutateFuncLitExprs(&n.Cond)
-			bm(n.Body, MutateFuncLit)
 			if n.Else != nil {
-				MutateFuncLit(owner_list, index, n.Else, bm)
+				MutateStrStmts(owner_list, index, n.Else, bm)
 			}
 		
 		case *ast.SwitchStmt:
-			MutateFuncLit(owner_list, index, n.Init, bm)
-			MutateFuncLitExprs(&n.Tag)
-			bm(n.Body, MutateFuncLit)
+			if n.Init != nil && HasStrConcat(n.Init) {
+				bm(SplitInitStmt(owner_list, n, &n.Init), MutateStrStmts)
+				return
+			}
+			is_str_switch := n.Tag != nil && IsStrType(ASTCtxt.TypeInfo.TypeOf(n.Tag))
+			MutateStrExpr(&n.Tag, owner_list, n)
+			if is_str_switch && HasCall(n.Tag) {
+				/// string switches compare the tag once per case, calls in it have to run only once.
+				tmp := ast.NewIdent(fmt.Sprintf("switch_tag%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				i := FindStmt(*owner_list, n)
+				for j, stmt := range MakeStrBuffer(tmp, n.Tag) {
+					*owner_list = InsertStmt(*owner_list, i+j, stmt)
+				}
+				n.Tag = tmp
+			}
+			bm(n.Body, MutateStrStmts)
 		
 		case *ast.CaseClause:
 			for j := range n.List {
-				MutateFuncLitExprs(&n.List[j])
-			}
-			for i, stmt := range n.Body {
-				MutateFuncLit(&n.Body, i, stmt, bm)
+				MutateStrExpr(&n.List[j], nil, nil)
 			}
+			MutateStmtList(&n.Body, MutateStrStmts)
 		
 		case *ast.RangeStmt:
-			MutateFuncLitExprs(&n.Key)
-			MutateFuncLitExprs(&n.Value)
-			MutateFuncLitExprs(&n.X)
-			bm(n.Body, MutateFuncLit)
-		
-		case *ast.ExprStmt:
-			MutateFuncLitExprs(&n.X)
+			bm(n.Body, MutateStrStmts)
 		
 		case *ast.ReturnStmt:
 			for i := range n.Results {
-				MutateFuncLitExprs(&n.Results[i])
+				MutateStrExpr(&n.Results[i], owner_list, n)
 			}
 		
+		case *ast.ExprStmt:
+			MutateStrExpr(&n.X, owner_list, n)
+		
 		case *ast.AssignStmt:
-			for i := range n.Rhs {
-				MutateFuncLitExprs(&n.Rhs[i])
+			if len(n.Lhs)==1 && len(n.Rhs)==1 && IsStrBuild(n.Rhs[0]) {
+				/// the top concatenation is printed as Format, only its parts need to be moved.
+				MutateStrParts(&n.Rhs[0], owner_list, n)
+				if iden, is_ident := n.Lhs[0].(*ast.Ident); is_ident && n.Tok==token.DEFINE {
+					ReplaceStmt(owner_list, n, MakeStrBuffer(iden, n.Rhs[0])...)
+				} else if is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil {
+					/// 's := Sprintf(...)' was already split into a declaration and an assignment.
+					ASTCtxt.StrBufferSizes[iden] = MakeBasicLit(token.INT, fmt.Sprintf("%d", GetStrBuildSize(n.Rhs[0])))
+				}
+				return
 			}
 			for i := range n.Lhs {
-				MutateFuncLitExprs(&n.Lhs[i])
+				MutateStrExpr(&n.Lhs[i], owner_list, n)
+			}
+			for i := range n.Rhs {
+				MutateStrExpr(&n.Rhs[i], owner_list, n)
 			}
 		
 		case *ast.DeclStmt:
 			g := n.Decl.(*ast.GenDecl)
-			for _, d := range g.Specs {
-				switch g.Tok {
-					case token.CONST, token.VAR:
-						v := d.(*ast.ValueSpec)
-						for expr := range v.Values {
-							MutateFuncLitExprs(&v.Values[expr])
-						}
+			if g.Tok != token.VAR {
+				return
+			}
+			if !HasStrConcat(g) {
+				return
+			}
+			var new_stmts []ast.Stmt
+			for _, spec := range g.Specs {
+				v := spec.(*ast.ValueSpec)
+				if len(v.Names)==1 && len(v.Values)==1 && IsStrBuild(v.Values[0]) {
+					/// var s = a + b => var s string; s = a + b
+					MutateStrParts(&v.Values[0], owner_list, n)
+					new_stmts = append(new_stmts, MakeStrBuffer(v.Names[0], v.Values[0])...)
+					continue
+				}
+				for i := range v.Values {
+					MutateStrExpr(&v.Values[i], owner_list, n)
 				}
+				new_stmts = append(new_stmts, MakeDeclStmt(token.VAR, v))
 			}
+			ReplaceStmt(owner_list, n, new_stmts...)
 	}
 }
 
-func MutateFuncLitExprs(e *ast.Expr) {
-	if e==nil || *e == nil {
-		return
-	}
-	switch n := (*e).(type) {
+/// moves the strings built inside the parts of a concatenation or the args of Sprintf.
+func MutateStrParts(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	switch x := (*e).(type) {
 		case *ast.BinaryExpr:
-			MutateFuncLitExprs(&n.X)
-			MutateFuncLitExprs(&n.Y)
-		
+			if IsStrConcat(x) {
+				MutateStrParts(&x.X, owner_list, anchor)
+				MutateStrParts(&x.Y, owner_list, anchor)
+			}
+		case *ast.ParenExpr:
+			MutateStrParts(&x.X, owner_list, anchor)
 		case *ast.CallExpr:
-			MutateFuncLitExprs(&n.Fun)
-			for i := range n.Args {
-				MutateFuncLitExprs(&n.Args[i])
+			if GetFmtFunc(x)=="Sprintf" && !IsTopStrBuild(e, anchor) {
+				MutateStrExpr(e, owner_list, anchor)
+				return
+			}
+			for i := range x.Args {
+				MutateStrExpr(&x.Args[i], owner_list, anchor)
 			}
-		
-		case *ast.KeyValueExpr:
-			MutateFuncLitExprs(&n.Key)
-			MutateFuncLitExprs(&n.Value)
-		
 		case *ast.IndexExpr:
-			MutateFuncLitExprs(&n.X)
-			MutateFuncLitExprs(&n.Index)
-		
-		case *ast.UnaryExpr:
-			MutateFuncLitExprs(&n.X)
-		
-		case *ast.FuncLit:
-			tmp_func_name := ast.NewIdent(fmt.Sprintf("SrcGoTmpFunc%d", ASTCtxt.TmpFunc))
-			ASTCtxt.TmpFunc++
-			fn_decl := new(ast.FuncDecl)
-			fn_decl.Name = tmp_func_name
-			fn_decl.Type = n.Type
-			n.Type = nil
-			fn_decl.Body = n.Body
-			n.Body = nil
-			*e = tmp_func_name
-			
-			ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+			MutateStrExpr(&x.Index, owner_list, anchor)
 	}
 }
 
-/*
-func MutateMaps(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateMapExprs)
+/// checks if 'e' is the string that 'anchor' assigns, which the generator formats in place.
+func IsTopStrBuild(e *ast.Expr, anchor ast.Stmt) bool {
+	switch n := anchor.(type) {
+		case *ast.AssignStmt:
+			return len(n.Rhs)==1 && &n.Rhs[0]==e
+		case *ast.DeclStmt:
+			for _, spec := range n.Decl.(*ast.GenDecl).Specs {
+				if v, is_value := spec.(*ast.ValueSpec); is_value && len(v.Values)==1 && &v.Values[0]==e {
+					return true
 				}
-				ASTCtxt.CurrFunc = nil
-		}
+			}
 	}
+	return false
 }
 
-func MutateMapExprs(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
-		case *ast.BlockStmt:
-			bm(n, MutateMapExprs)
-		
-		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateMapExprs(owner_list, index, n.Init, bm)
-			}
-			if n.Cond != nil {
-				MutateFuncLitExprs(&n.Cond)
-			}
-			if n.Post != nil {
-				MutateMapExprs(owner_list, index, n.Post, bm)
-			}
-			bm(n.Body, MutateMapExprs)
-		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateMapExprs(owner_list, index, n.Init, bm)
-			}
-			MutateFuncLitExprs(&n.Cond)
-			bm(n.Body, MutateMapExprs)
-			if n.Else != nil {
-				MutateMapExprs(owner_list, index, n.Else, bm)
-			}
-		
-		case *ast.SwitchStmt:
-			MutateMapExprs(owner_list, index, n.Init, bm)
-			MutateMapExpr(&n.Tag)
-			bm(n.Body, MutateMapExprs)
-		
-		case *ast.CaseClause:
-			for j := range n.List {
-				MutateMapExpr(&n.List[j])
-			}
-			for i, stmt := range n.Body {
-				MutateMapExprs(&n.Body, i, stmt, bm)
-			}
-		
-		case *ast.RangeStmt:
-			MutateMapExpr(&n.Key)
-			MutateMapExpr(&n.Value)
-			MutateMapExpr(&n.X)
-			bm(n.Body, MutateMapExprs)
-		
-		case *ast.ExprStmt:
-			MutateMapExpr(&n.X)
-		
-		case *ast.ReturnStmt:
-			for i := range n.Results {
-				MutateMapExpr(&n.Results[i])
-			}
-		
-		case *ast.AssignStmt:
-			for i := range n.Rhs {
-				MutateMapExpr(&n.Rhs[i])
-			}
-			for i := range n.Lhs {
-				MutateMapExpr(&n.Lhs[i])
-			}
-		
-		case *ast.DeclStmt:
-			g := n.Decl.(*ast.GenDecl)
-			for _, d := range g.Specs {
-				switch g.Tok {
-					case token.CONST, token.VAR:
-						v := d.(*ast.ValueSpec)
-						for expr := range v.Values {
-							MutateMapExpr(&v.Values[expr])
-						}
-				}
+func HasCall(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, is_call := n.(*ast.CallExpr); is_call {
+			/// conversions don't run anything.
+			if tv, found_tv := ASTCtxt.TypeInfo.Types[call.Fun]; !found_tv || !tv.IsType() {
+				found = true
 			}
+		}
+		return !found
+	})
+	return found
+}
+
+func HasStrConcat(node ast.Node) bool {
+	if node==nil {
+		return false
 	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if e, is_expr := n.(ast.Expr); is_expr && IsStrBuild(e) {
+			found = true
+		}
+		return !found
+	})
+	return found
 }
 
-func MutateMapExpr(e *ast.Expr) {
-	if e==nil || *e == nil {
+/// moves string concatenations into buffers declared before 'anchor'.
+func MutateStrExpr(e *ast.Expr, owner_list *[]ast.Stmt, anchor ast.Stmt) {
+	if e==nil || *e==nil {
+		return
+	}
+	if IsStrBuild(*e) {
+		if owner_list==nil {
+			PrintSrcGoErr((*e).Pos(), "Building strings is not allowed here, store the string in a variable first.")
+			return
+		}
+		if call, is_call := (*e).(*ast.CallExpr); is_call {
+			for i := range call.Args {
+				MutateStrExpr(&call.Args[i], owner_list, anchor)
+			}
+		} else {
+			MutateStrParts(e, owner_list, anchor)
+		}
+		tmp := ast.NewIdent(fmt.Sprintf("str_concat%d", ASTCtxt.TmpVar))
+		ASTCtxt.TmpVar++
+		i := FindStmt(*owner_list, anchor)
+		for j, stmt := range MakeStrBuffer(tmp, *e) {
+			*owner_list = InsertStmt(*owner_list, i+j, stmt)
+		}
+		*e = tmp
 		return
 	}
 	switch n := (*e).(type) {
 		case *ast.BinaryExpr:
-			MutateMapExpr(&n.X)
-			MutateMapExpr(&n.Y)
+			MutateStrExpr(&n.X, owner_list, anchor)
+			MutateStrExpr(&n.Y, owner_list, anchor)
 		
 		case *ast.CallExpr:
-			MutateMapExpr(&n.Fun)
+			if iden, is_ident := n.Fun.(*ast.Ident); is_ident && iden.Name=="panic" && len(n.Args)==1 && GetFmtFunc(n.Args[0])=="Sprintf" {
+				/// panic formats its message in place.
+				for i, fmt_call := 0, n.Args[0].(*ast.CallExpr); i < len(fmt_call.Args); i++ {
+					MutateStrExpr(&fmt_call.Args[i], owner_list, anchor)
+				}
+				return
+			}
 			for i := range n.Args {
-				MutateMapExpr(&n.Args[i])
+				MutateStrExpr(&n.Args[i], owner_list, anchor)
 			}
 		
-		case *ast.KeyValueExpr:
-			MutateMapExpr(&n.Key)
-			MutateMapExpr(&n.Value)
-		
 		case *ast.IndexExpr:
-			//MutateMapExpr(&n.X)
-			if typ := ASTCtxt.TypeInfo.TypeOf(n.X); typ != nil {
-				case *types.Map:
-					
-			}
-			MutateMapExpr(&n.Index)
+			MutateStrExpr(&n.X, owner_list, anchor)
+			MutateStrExpr(&n.Index, owner_list, anchor)
+		
+		case *ast.ParenExpr:
+			MutateStrExpr(&n.X, owner_list, anchor)
 		
 		case *ast.UnaryExpr:
-			MutateMapExpr(&n.X)
+			MutateStrExpr(&n.X, owner_list, anchor)
+		
+		case *ast.KeyValueExpr:
+			MutateStrExpr(&n.Value, owner_list, anchor)
+		
+		case *ast.CompositeLit:
+			for i := range n.Elts {
+				MutateStrExpr(&n.Elts[i], owner_list, anchor)
+			}
 	}
 }
-*/
 
 
 func PrintAST(n ast.Node) string {
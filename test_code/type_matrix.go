@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+/**
+ * every type in every position, with what each should be generated as.
+ *
+ * type            | param               | *param        | local/global       | field              | return
+ * int             | int x               | int& x        | int x              | int x              | int
+ * [4]int          | const int x[4]      | int x[4]      | int x[4]           | int x[4]           | int[4]
+ * [4][8]int       | const int x[4][8]   | int x[4][8]   | int x[4][8]        | int x[4][8]        | int[4][8]
+ * []int           | const int[] x       | int[] x       | int x[]            | int x[]            | ArrayList
+ * [][4]int        | const int x[][4]    | int x[][4]    | int x[][4]         | int x[][4]         | ArrayList
+ * string          | const char[] x      | char[] x      | char x[256]        | char x[256]        | char[]
+ * [32]char        | const char x[32]    | char x[32]    | char x[32]         | char x[32]         | char[32]
+ * [4][32]char     | const char x[4][32] | char x[4][32] | char x[4][32]      | char x[4][32]      | char[4][32]
+ * [4]string       | const char x[4][]   | char x[4][]   | char x[4][256]     | char x[4][256]     | char[4][]
+ * []string        | const char[][] x    | char[][] x    | char x[][256]      | char x[][256]      | ArrayList
+ * Vec             | const Vec x         | Vec x         | Vec x              | Vec x              | Vec
+ * [2]Vec          | const Vec x[2]      | Vec x[2]      | Vec x[2]           | Vec x[2]           | Vec[2]
+ * Handle          | Handle x            | Handle& x     | Handle x           | Handle x           | Handle
+ * [2]Handle       | const Handle x[2]   | Handle x[2]   | Handle x[2]        | Handle x[2]        | Handle[2]
+ *
+ * slices that grow or escape are 'ArrayList x' in every position.
+ */
+
+type Vec struct {
+	X, Y float
+}
+
+type Row struct {
+	I   int
+	A   [4]int
+	B   [4][8]int
+	S   string
+	C   [32]char
+	T   [4][32]char
+	ST  [4]string
+	V   Vec
+	VA  [2]Vec
+	H   Handle
+	HA  [2]Handle
+}
+
+var (
+	gI  int
+	gA  [4]int
+	gB  [4][8]int
+	gS  string
+	gC  [32]char
+	gT  [4][32]char
+	gST [4]string
+	gV  Vec
+	gVA [2]Vec
+	gH  Handle
+	gHA [2]Handle
+)
+
+func Params(i int, a [4]int, b [4][8]int, sl []int, sl2 [][4]int, s string, c [32]char, t [4][32]char, st [4]string, strs []string, v Vec, va [2]Vec, h Handle, ha [2]Handle) {
+}
+
+func RefParams(i *int, a *[4]int, b *[4][8]int, sl *[]int, s *string, c *[32]char, t *[4][32]char, st *[4]string, v *Vec, va *[2]Vec, h *Handle) {
+}
+
+func RetInt() int {
+	return 0
+}
+
+func RetArray() [4]int {
+	var a [4]int
+	return a
+}
+
+func RetStruct() Vec {
+	var v Vec
+	return v
+}
+
+func RetHandle() Handle {
+	return 0
+}
+
+func main() {
+	var i int
+	var a [4]int
+	var b [4][8]int
+	var s string
+	var c [32]char
+	var t [4][32]char
+	var st [4]string
+	var v Vec
+	var va [2]Vec
+	var h Handle
+	var ha [2]Handle
+	Params(i, a, b, nil, nil, s, c, t, st, nil, v, va, h, ha)
+	RefParams(&i, &a, &b, nil, &s, &c, &t, &st, &v, &va, &h)
+}